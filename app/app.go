@@ -2,10 +2,21 @@ package app
 
 import (
 	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/controllers/attachmentcontroller"
 	"github.com/bayudha2/go-test-0/controllers/authcontroller"
+	"github.com/bayudha2/go-test-0/controllers/bookmarkcontroller"
 	"github.com/bayudha2/go-test-0/controllers/commentcontroller"
+	"github.com/bayudha2/go-test-0/controllers/mentioncontroller"
+	"github.com/bayudha2/go-test-0/controllers/notificationcontroller"
 	"github.com/bayudha2/go-test-0/controllers/postcontroller"
+	"github.com/bayudha2/go-test-0/controllers/preferencecontroller"
 	"github.com/bayudha2/go-test-0/controllers/productcontroller"
+	"github.com/bayudha2/go-test-0/controllers/reportcontroller"
+	"github.com/bayudha2/go-test-0/controllers/streamcontroller"
+	"github.com/bayudha2/go-test-0/controllers/tagcontroller"
+	"github.com/bayudha2/go-test-0/controllers/trashcontroller"
+	"github.com/bayudha2/go-test-0/controllers/usercontroller"
+	"github.com/bayudha2/go-test-0/controllers/webhookcontroller"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 )
@@ -37,10 +48,87 @@ func InitializeRoutes(R *mux.Router) {
 	secure.HandleFunc("/post/{id}", postcontroller.GetPost).Methods("GET")
 	secure.HandleFunc("/post/{id}", postcontroller.UpdatePost).Methods("PUT")
 	secure.HandleFunc("/post/{id}", postcontroller.DeletePost).Methods("DELETE")
+	secure.HandleFunc("/post/{id}/repost", postcontroller.RepostPost).Methods("POST")
+	secure.HandleFunc("/post/{id}/repost", postcontroller.UndoRepost).Methods("DELETE")
+	secure.HandleFunc("/post/{id}/poll/vote", postcontroller.VotePoll).Methods("POST")
+	secure.HandleFunc("/post/{id}/poll/close", postcontroller.ClosePoll).Methods("POST")
+	secure.HandleFunc("/post/{id}/comment-settings", postcontroller.UpdateCommentSettings).Methods("PUT")
+	secure.HandleFunc("/post/{id}/comments/lock", postcontroller.LockComments).Methods("POST")
+	secure.HandleFunc("/post/{id}/comments/unlock", postcontroller.UnlockComments).Methods("POST")
+	secure.HandleFunc("/post/{id}/content-warning", postcontroller.ForceContentWarning).Methods("POST")
+	secure.HandleFunc("/post/{id}/content-warning", postcontroller.LiftContentWarning).Methods("DELETE")
+	secure.HandleFunc("/post/{id}/views", postcontroller.GetPostViews).Methods("GET")
+	secure.HandleFunc("/post/{id}/revisions", postcontroller.GetPostRevisions).Methods("GET")
+	secure.HandleFunc("/post/{id}/revisions/diff", postcontroller.GetPostRevisionDiff).Methods("GET")
+	secure.HandleFunc("/post/{id}/revisions/{revision}/restore", postcontroller.RestorePostRevision).Methods("POST")
+
+	secure.HandleFunc("/attachments", attachmentcontroller.UploadAttachment).Methods("POST")
+	secure.HandleFunc("/post/{id}/attachments", attachmentcontroller.UploadAttachment).Methods("POST")
+	secure.HandleFunc("/attachment/{id}", attachmentcontroller.DeleteAttachment).Methods("DELETE")
+	R.HandleFunc("/media/{id}", attachmentcontroller.ServeMedia).Methods("GET", "HEAD")
+	R.HandleFunc("/media/{id}/{variant}", attachmentcontroller.ServeMediaVariant).Methods("GET", "HEAD")
+	secure.HandleFunc("/admin/attachments/failed", attachmentcontroller.GetFailedAttachments).Methods("GET")
+	secure.HandleFunc("/admin/attachment/{id}/retry", attachmentcontroller.RetryAttachment).Methods("POST")
 
 	secure.HandleFunc("/comment", commentcontroller.CreateComment).Methods("POST")
 	secure.HandleFunc("/comment/{id}", commentcontroller.UpdateComment).Methods("PUT")
 	secure.HandleFunc("/comment/{id}", commentcontroller.DeleteComment).Methods("DELETE")
+	secure.HandleFunc("/comment/{id}/history", commentcontroller.GetCommentHistory).Methods("GET")
+	secure.HandleFunc("/comment/{id}/hide", commentcontroller.HideComment).Methods("POST")
+	secure.HandleFunc("/comment/{id}/unhide", commentcontroller.UnhideComment).Methods("POST")
+	secure.HandleFunc("/comment/{id}/approve", commentcontroller.ApproveComment).Methods("POST")
+	secure.HandleFunc("/moderation/comments", commentcontroller.GetModerationQueue).Methods("GET")
 	R.HandleFunc("/comments", commentcontroller.GetCommentsByPost).Methods("GET")
+	R.HandleFunc("/comments/tree", commentcontroller.GetCommentTree).Methods("GET")
+	R.HandleFunc("/comments/stream", streamcontroller.StreamComments).Methods("GET")
 	R.HandleFunc("/comment/{id}", commentcontroller.GetComment).Methods("GET")
+	R.HandleFunc("/comment/{id}/replies", commentcontroller.GetReplies).Methods("GET")
+
+	secure.HandleFunc("/user/{id}/follow", usercontroller.FollowUser).Methods("POST")
+	secure.HandleFunc("/user/{id}/follow", usercontroller.UnfollowUser).Methods("DELETE")
+
+	secure.HandleFunc("/report", reportcontroller.CreateReport).Methods("POST")
+	secure.HandleFunc("/reports", reportcontroller.GetMyReports).Methods("GET")
+	secure.HandleFunc("/admin/reports", reportcontroller.GetReportQueue).Methods("GET")
+	secure.HandleFunc("/admin/report/{id}", reportcontroller.GetReport).Methods("GET")
+	secure.HandleFunc("/admin/report/{id}/assign", reportcontroller.AssignReport).Methods("POST")
+	secure.HandleFunc("/admin/report/{id}/resolve", reportcontroller.ResolveReport).Methods("POST")
+	secure.HandleFunc("/admin/report/{id}/dismiss", reportcontroller.DismissReport).Methods("POST")
+
+	secure.HandleFunc("/preferences", preferencecontroller.GetPreferences).Methods("GET")
+	secure.HandleFunc("/preferences", preferencecontroller.UpdatePreferences).Methods("PUT")
+
+	secure.HandleFunc("/mentions", mentioncontroller.GetMentions).Methods("GET")
+
+	secure.HandleFunc("/events", streamcontroller.StreamEvents).Methods("GET")
+
+	secure.HandleFunc("/webhooks", webhookcontroller.GetWebhooks).Methods("GET")
+	secure.HandleFunc("/webhook", webhookcontroller.CreateWebhook).Methods("POST")
+	secure.HandleFunc("/webhook/{id}", webhookcontroller.GetWebhook).Methods("GET")
+	secure.HandleFunc("/webhook/{id}", webhookcontroller.UpdateWebhook).Methods("PUT")
+	secure.HandleFunc("/webhook/{id}", webhookcontroller.DeleteWebhook).Methods("DELETE")
+	secure.HandleFunc("/webhook/{id}/deliveries", webhookcontroller.GetDeliveries).Methods("GET")
+	secure.HandleFunc("/webhook-delivery/{id}/redeliver", webhookcontroller.Redeliver).Methods("POST")
+
+	secure.HandleFunc("/notifications", notificationcontroller.GetNotifications).Methods("GET")
+	secure.HandleFunc("/notifications/read-all", notificationcontroller.MarkAllNotificationsRead).Methods("POST")
+	secure.HandleFunc("/notifications/preferences", notificationcontroller.GetPreferences).Methods("GET")
+	secure.HandleFunc("/notifications/preferences", notificationcontroller.UpdatePreferences).Methods("PUT")
+	secure.HandleFunc("/notification/{id}/read", notificationcontroller.MarkNotificationRead).Methods("POST")
+
+	secure.HandleFunc("/collections", bookmarkcontroller.GetCollections).Methods("GET")
+	secure.HandleFunc("/collection", bookmarkcontroller.CreateCollection).Methods("POST")
+	secure.HandleFunc("/collection/{id}", bookmarkcontroller.GetCollection).Methods("GET")
+	secure.HandleFunc("/collection/{id}", bookmarkcontroller.UpdateCollection).Methods("PUT")
+	secure.HandleFunc("/collection/{id}", bookmarkcontroller.DeleteCollection).Methods("DELETE")
+	secure.HandleFunc("/collection/{id}/items", bookmarkcontroller.AddBookmark).Methods("POST")
+	secure.HandleFunc("/collection/{id}/post/{post_id}", bookmarkcontroller.RemovePostBookmark).Methods("DELETE")
+	secure.HandleFunc("/collection/{id}/product/{product_id}", bookmarkcontroller.RemoveProductBookmark).Methods("DELETE")
+	secure.HandleFunc("/bookmarks", bookmarkcontroller.GetBookmarks).Methods("GET")
+
+	secure.HandleFunc("/trash", trashcontroller.GetTrash).Methods("GET")
+	secure.HandleFunc("/trash/post/{id}/restore", trashcontroller.RestorePost).Methods("POST")
+	secure.HandleFunc("/trash/comment/{id}/restore", trashcontroller.RestoreComment).Methods("POST")
+	R.HandleFunc("/tags/trending", tagcontroller.GetTrendingTags).Methods("GET")
+	R.HandleFunc("/tags/{tag}/posts", tagcontroller.GetPostsByTag).Methods("GET")
 }
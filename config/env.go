@@ -0,0 +1,42 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DurationFromEnv reads a duration such as "30s" from the environment,
+// returning fallback when the variable is unset or invalid.
+func DurationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+
+	return d
+}
+
+// IntFromEnv reads an integer from the environment, returning fallback when
+// the variable is unset or invalid.
+func IntFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+
+	return n
+}
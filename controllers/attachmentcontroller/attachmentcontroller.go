@@ -0,0 +1,287 @@
+package attachmentcontroller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/storage"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/bayudha2/go-test-0/utils/imaging"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// MaxUploadBytes is the largest file accepted by UploadAttachment.
+var MaxUploadBytes = int64(config.IntFromEnv("APP_UPLOAD_MAX_BYTES", 10<<20))
+
+// allowedTypes are the content types accepted for upload, as detected from
+// the file contents rather than the client supplied header.
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"video/mp4":  true,
+	"video/webm": true,
+}
+
+// UploadAttachment stores the multipart "file" field and records it with
+// the optional "alt_text" field. Uploads to /v1/attachments stay unattached
+// until a post is created with their ids; uploads to /v1/post/{id}/attachments
+// are attached to that post straight away.
+func UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	// Leave room for the multipart boundaries and the other fields.
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadBytes+64<<10)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			helper.RespondWithError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	defer file.Close()
+
+	if header.Size > MaxUploadBytes {
+		helper.RespondWithError(w, http.StatusRequestEntityTooLarge, "File is too large")
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	contentType := http.DetectContentType(sniff[:n])
+	if !allowedTypes[contentType] {
+		helper.RespondWithError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("Unsupported file type %s", contentType))
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	attachment := models.Attachment{
+		UserId:      userInfo.Userid,
+		BlobKey:     "attachments/" + uuid.New().String(),
+		ContentType: contentType,
+		Size:        header.Size,
+		AltText:     r.FormValue("alt_text"),
+	}
+
+	if id, ok := mux.Vars(r)["id"]; ok {
+		attachment.PostId = &id
+	}
+
+	if err := storage.Default.Put(r.Context(), attachment.BlobKey, file, header.Size, contentType); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := attachment.CreateAttachment(models.DB); err != nil {
+		if delErr := storage.Default.Delete(r.Context(), attachment.BlobKey); delErr != nil {
+			log.Printf("deleting blob %s: %v", attachment.BlobKey, delErr)
+		}
+
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, attachment)
+}
+
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid attachment ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	attachment := models.Attachment{ID: id, UserId: userInfo.Userid}
+	keys, err := attachment.DeleteAttachment(models.DB)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Attachment not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	for _, key := range keys {
+		if err := storage.Default.Delete(r.Context(), key); err != nil && err != storage.ErrNotFound {
+			log.Printf("deleting blob %s: %v", key, err)
+		}
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+// ServeMedia streams an attachment. Images are served from their "original"
+// variant, which has EXIF and GPS data stripped, so they are only available
+// once processed.
+func ServeMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attachment := models.Attachment{ID: vars["id"]}
+
+	if err := attachment.GetMedia(models.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Media not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if !models.ImageTypes[attachment.ContentType] {
+		serveBlob(w, r, attachment.BlobKey, attachment.ContentType, attachment.ID)
+		return
+	}
+
+	serveVariant(w, r, attachment, imaging.Original)
+}
+
+// ServeMediaVariant streams a resized variant of an image, such as
+// "thumbnail".
+func ServeMediaVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attachment := models.Attachment{ID: vars["id"]}
+
+	if err := attachment.GetMedia(models.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Media not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	serveVariant(w, r, attachment, vars["variant"])
+}
+
+func serveVariant(w http.ResponseWriter, r *http.Request, attachment models.Attachment, name string) {
+	variant, err := attachment.GetVariant(models.DB, name)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows && attachment.ProcessingStatus != models.ProcessingDone:
+			helper.RespondWithError(w, http.StatusNotFound, "Media is still being processed")
+		case err == sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Media not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	serveBlob(w, r, variant.BlobKey, variant.ContentType, attachment.ID+"/"+name)
+}
+
+// serveBlob streams a blob. Blobs never change once written, so they are
+// cacheable forever; http.ServeContent answers Range and conditional
+// requests.
+func serveBlob(w http.ResponseWriter, r *http.Request, key, contentType, etag string) {
+	object, info, err := storage.Default.Open(r.Context(), key)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			helper.RespondWithError(w, http.StatusNotFound, "Media not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	defer object.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", strconv.Quote(etag))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime, object)
+}
+
+// GetFailedAttachments lists the images the pipeline could not process, for
+// admins.
+func GetFailedAttachments(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	params := models.Params{Page: r.URL.Query().Get("page"), Limit: r.URL.Query().Get("limit")}
+	failed, err := models.GetFailedAttachments(models.DB, userInfo.Userid, params)
+	if err != nil {
+		switch err {
+		case models.ErrUnauthorized:
+			helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, failed)
+}
+
+// RetryAttachment queues a failed image for processing again.
+func RetryAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid attachment ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	attachment := models.Attachment{ID: id}
+	if err := attachment.RetryProcessing(models.DB, userInfo.Userid); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Failed attachment not found")
+		case models.ErrUnauthorized:
+			helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, attachment)
+}
@@ -0,0 +1,393 @@
+package attachmentcontroller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bayudha2/go-test-0/app"
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/controllers/attachmentcontroller"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/jobs"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/storage"
+	"github.com/bayudha2/go-test-0/utils/imaging"
+)
+
+func ensureTableExist() {
+	if _, err := models.DB.Exec(helper.TableUserCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePostCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePostRevisionCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableCommentCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableTagCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableMentionCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableAttachmentCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableProductCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableBookmarkCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePollCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableLinkPreviewCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableNotificationCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableWebhookCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+func clearTable() {
+	models.DB.Exec("DELETE FROM webhook_deliveries;")
+	models.DB.Exec("DELETE FROM webhooks;")
+	models.DB.Exec("DELETE FROM notification_preferences;")
+	models.DB.Exec("DELETE FROM notifications;")
+	models.DB.Exec("DELETE FROM post_links;")
+	models.DB.Exec("DELETE FROM link_previews;")
+	models.DB.Exec("DELETE FROM polls;")
+	models.DB.Exec("DELETE FROM bookmarks;")
+	models.DB.Exec("DELETE FROM bookmark_collections;")
+	models.DB.Exec("DELETE FROM attachment_variants;")
+	models.DB.Exec("DELETE FROM attachments;")
+	models.DB.Exec("DELETE FROM post_revisions;")
+	models.DB.Exec("DELETE FROM posts;")
+	models.DB.Exec("DELETE FROM users;")
+}
+
+func TestMain(m *testing.M) {
+	models.ConnectDatabase(
+		os.Getenv("APP_DB_USERNAME"),
+		os.Getenv("APP_DB_PASSWORD"),
+		os.Getenv("APP_DB_TEST_NAME"),
+	)
+
+	dir, err := os.MkdirTemp("", "attachments")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	storage.Default, err = storage.NewLocalStore(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pipeline = &jobs.ImagePipeline{
+		DB:          models.DB,
+		Store:       storage.Default,
+		Variants:    imaging.DefaultVariants,
+		MaxAttempts: 1,
+		Backoff:     time.Minute,
+		BatchSize:   10,
+	}
+
+	app.Initialize()
+
+	ensureTableExist()
+	code := m.Run()
+	clearTable()
+	os.RemoveAll(dir)
+
+	os.Exit(code)
+}
+
+var pipeline *jobs.ImagePipeline
+
+func token(userid, username string) string {
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken(userid, username, 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+	return fmt.Sprintf("Bearer %s", accessToken.Token)
+}
+
+func pngFile() []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	return buf.Bytes()
+}
+
+func upload(path, access string, content []byte, altText string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "gambar.png")
+	part.Write(content)
+	writer.WriteField("alt_text", altText)
+	writer.Close()
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", access)
+
+	app.R.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUploadAndServeAttachment(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	content := pngFile()
+	rec := upload("/v1/attachments", token("iniuserid0", "iniusername0"), content, "kotak kosong")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected the resp code to be 201. Got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var attachment models.Attachment
+	json.Unmarshal(rec.Body.Bytes(), &attachment)
+
+	if attachment.ContentType != "image/png" || attachment.AltText != "kotak kosong" || attachment.Size != int64(len(content)) {
+		t.Errorf("Expected a png attachment with alt text. Got %+v", attachment)
+	}
+
+	if attachment.ProcessingStatus != models.ProcessingPending {
+		t.Errorf("Expected the image to wait for processing. Got %s", attachment.ProcessingStatus)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", attachment.URL, nil)
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected unprocessed images not to be served. Got %d", rec.Code)
+	}
+
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", attachment.URL, nil)
+	req.Header.Set("Range", "bytes=0-7")
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("Expected the resp code to be 206. Got %d", rec.Code)
+	}
+
+	if !bytes.Equal(rec.Body.Bytes(), content[:8]) {
+		t.Errorf("Expected the png signature. Got %v", rec.Body.Bytes())
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", attachment.URL+"/thumbnail", nil)
+	app.R.ServeHTTP(rec, req)
+
+	thumbnail, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if thumbnail.Bounds().Dx() != 320 || thumbnail.Bounds().Dy() != 160 {
+		t.Errorf("Expected a 320x160 thumbnail. Got %v", thumbnail.Bounds())
+	}
+
+	if rec.Header().Get("Cache-Control") == "" || rec.Header().Get("ETag") == "" {
+		t.Errorf("Expected caching headers. Got %v", rec.Header())
+	}
+}
+
+func TestUploadRejectsUnsupportedType(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	rec := upload("/v1/attachments", token("iniuserid0", "iniusername0"), []byte("<script>alert(1)</script>"), "")
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected the resp code to be 415. Got %d", rec.Code)
+	}
+}
+
+func TestUploadRejectsLargeFile(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	limit := attachmentcontroller.MaxUploadBytes
+	attachmentcontroller.MaxUploadBytes = 16
+	defer func() { attachmentcontroller.MaxUploadBytes = limit }()
+
+	rec := upload("/v1/attachments", token("iniuserid0", "iniusername0"), pngFile(), "")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the resp code to be 413. Got %d", rec.Code)
+	}
+}
+
+func TestCreatePostWithAttachments(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+
+	rec := upload("/v1/attachments", token("iniuserid0", "iniusername0"), pngFile(), "")
+	var attachment models.Attachment
+	json.Unmarshal(rec.Body.Bytes(), &attachment)
+
+	payload := []byte(fmt.Sprintf(`{"description": "ada gambar", "attachment_ids": [%q]}`, attachment.ID))
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/post", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token("iniuserid1", "iniusername1"))
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected another user's upload to be rejected. Got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/post", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token("iniuserid0", "iniusername0"))
+	app.R.ServeHTTP(rec, req)
+
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if len(post.Attachments) != 1 || post.Attachments[0].ID != attachment.ID {
+		t.Fatalf("Expected the post to carry the attachment. Got %+v", post.Attachments)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/"+post.ID, nil)
+	req.Header.Set("Authorization", token("iniuserid1", "iniusername1"))
+	app.R.ServeHTTP(rec, req)
+
+	post = models.Post{}
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if len(post.Attachments) != 1 || post.Attachments[0].URL != "/media/"+attachment.ID {
+		t.Fatalf("Expected GetPost to include the attachment. Got %+v", post.Attachments)
+	}
+
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/"+post.ID, nil)
+	req.Header.Set("Authorization", token("iniuserid1", "iniusername1"))
+	app.R.ServeHTTP(rec, req)
+
+	post = models.Post{}
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	processed := post.Attachments[0]
+	if processed.Width == nil || *processed.Width != 400 || processed.Blurhash == nil {
+		t.Errorf("Expected the image metadata. Got %+v", processed)
+	}
+
+	if processed.Variants["thumbnail"].URL != "/media/"+attachment.ID+"/thumbnail" {
+		t.Errorf("Expected a thumbnail variant. Got %+v", processed.Variants)
+	}
+}
+
+func TestFailedImagesVisibleToAdmins(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+	helper.SetUserRole("iniuserid1", models.RoleAdmin)
+
+	broken := append([]byte("\x89PNG\r\n\x1a\n"), []byte("bukan gambar sungguhan")...)
+	rec := upload("/v1/attachments", token("iniuserid0", "iniusername0"), broken, "")
+	var attachment models.Attachment
+	json.Unmarshal(rec.Body.Bytes(), &attachment)
+
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/admin/attachments/failed", nil)
+	req.Header.Set("Authorization", token("iniuserid0", "iniusername0"))
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected non-admins to be rejected. Got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/admin/attachments/failed", nil)
+	req.Header.Set("Authorization", token("iniuserid1", "iniusername1"))
+	app.R.ServeHTTP(rec, req)
+
+	var failed models.PayloadFailedAttachments
+	json.Unmarshal(rec.Body.Bytes(), &failed)
+
+	if failed.TotalData != 1 || failed.Data[0].ID != attachment.ID || failed.Data[0].Error == "" {
+		t.Fatalf("Expected the broken image to be listed with its error. Got %+v", failed)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/admin/attachment/"+attachment.ID+"/retry", nil)
+	req.Header.Set("Authorization", token("iniuserid1", "iniusername1"))
+	app.R.ServeHTTP(rec, req)
+
+	var retried models.Attachment
+	json.Unmarshal(rec.Body.Bytes(), &retried)
+
+	if retried.ProcessingStatus != models.ProcessingPending {
+		t.Errorf("Expected the image to be queued again. Got %s", retried.ProcessingStatus)
+	}
+}
+
+func TestOrphanedAttachmentsAreCollected(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	rec := upload("/v1/attachments", token("iniuserid0", "iniusername0"), pngFile(), "")
+	var attachment models.Attachment
+	json.Unmarshal(rec.Body.Bytes(), &attachment)
+
+	keys, err := models.ClaimOrphanedAttachments(models.DB, time.Now().Add(time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 {
+		t.Fatalf("Expected 1 orphaned attachment. Got %d", len(keys))
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", attachment.URL, nil)
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the resp code to be 404. Got %d", rec.Code)
+	}
+}
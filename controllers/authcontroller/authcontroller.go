@@ -85,6 +85,11 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.SuspendedAt != nil {
+		helper.RespondWithError(w, http.StatusForbidden, "Your account is suspended")
+		return
+	}
+
 	var accessToken config.TokenPayload
 	if err := accessToken.CreateToken(user.ID, user.Username, 15); err != nil {
 		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
@@ -162,6 +167,11 @@ func Refresh(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		if user.SuspendedAt != nil {
+			helper.RespondWithError(w, http.StatusForbidden, "Your account is suspended")
+			return
+		}
+
 		if !ok {
 			helper.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
 			return
@@ -0,0 +1,242 @@
+package bookmarkcontroller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/helper/validation"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/gorilla/mux"
+)
+
+func GetCollections(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	collections, err := models.GetCollections(models.DB, userInfo.Userid)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": collections})
+}
+
+func GetCollection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid collection ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	collection := models.Collection{ID: id, UserId: userInfo.Userid}
+	if err := collection.GetCollection(models.DB); err != nil {
+		respondWithCollectionError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, collection)
+}
+
+func CreateCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	if listErr, err := validation.Validate(&collection); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	collection.UserId = userInfo.Userid
+	if err := collection.CreateCollection(models.DB); err != nil {
+		respondWithCollectionError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, collection)
+}
+
+func UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid collection ID")
+		return
+	}
+
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	if listErr, err := validation.Validate(&collection); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	collection.ID = id
+	collection.UserId = userInfo.Userid
+	if err := collection.UpdateCollection(models.DB); err != nil {
+		respondWithCollectionError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, collection)
+}
+
+func DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid collection ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	collection := models.Collection{ID: id, UserId: userInfo.Userid}
+	if err := collection.DeleteCollection(models.DB); err != nil {
+		respondWithCollectionError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+// AddBookmark adds the post_id or product_id in the body to the collection.
+func AddBookmark(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid collection ID")
+		return
+	}
+
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	var bookmark models.Bookmark
+	if err := json.NewDecoder(r.Body).Decode(&bookmark); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	bookmark.CollectionId = id
+	if err := bookmark.AddBookmark(models.DB, userInfo.Userid); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Collection or item not found")
+		case models.ErrBookmarkItem:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, bookmark)
+}
+
+func RemovePostBookmark(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["post_id"]
+	removeBookmark(w, r, models.Bookmark{CollectionId: vars["id"], PostId: &postID})
+}
+
+func RemoveProductBookmark(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID := vars["product_id"]
+	removeBookmark(w, r, models.Bookmark{CollectionId: vars["id"], ProductId: &productID})
+}
+
+func removeBookmark(w http.ResponseWriter, r *http.Request, bookmark models.Bookmark) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	if err := bookmark.RemoveBookmark(models.DB, userInfo.Userid); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Bookmark not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+// GetBookmarks lists the user's bookmarks, optionally of one collection_id,
+// a page at a time: pass the next_cursor of a page as cursor to get the next.
+func GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	cursor, err := models.ParseCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	bookmarks, err := models.GetBookmarks(models.DB, userInfo.Userid, r.URL.Query().Get("collection_id"), cursor, limit)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, bookmarks)
+}
+
+func respondWithCollectionError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		helper.RespondWithError(w, http.StatusNotFound, "Collection not found")
+	case models.ErrCollectionExists:
+		helper.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
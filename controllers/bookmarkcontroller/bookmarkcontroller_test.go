@@ -0,0 +1,208 @@
+package bookmarkcontroller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/bayudha2/go-test-0/app"
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+)
+
+func ensureTableExist() {
+	if _, err := models.DB.Exec(helper.TableUserCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePostCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableAttachmentCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableProductCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableBookmarkCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePollCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableLinkPreviewCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableNotificationCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableWebhookCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+func clearTable() {
+	models.DB.Exec("DELETE FROM webhook_deliveries;")
+	models.DB.Exec("DELETE FROM webhooks;")
+	models.DB.Exec("DELETE FROM notification_preferences;")
+	models.DB.Exec("DELETE FROM notifications;")
+	models.DB.Exec("DELETE FROM post_links;")
+	models.DB.Exec("DELETE FROM link_previews;")
+	models.DB.Exec("DELETE FROM polls;")
+	models.DB.Exec("DELETE FROM bookmarks;")
+	models.DB.Exec("DELETE FROM bookmark_collections;")
+	models.DB.Exec("DELETE FROM products;")
+	models.DB.Exec("DELETE FROM posts;")
+	models.DB.Exec("DELETE FROM users;")
+}
+
+func TestMain(m *testing.M) {
+	models.ConnectDatabase(
+		os.Getenv("APP_DB_USERNAME"),
+		os.Getenv("APP_DB_PASSWORD"),
+		os.Getenv("APP_DB_TEST_NAME"),
+	)
+
+	app.Initialize()
+
+	ensureTableExist()
+	code := m.Run()
+	clearTable()
+
+	os.Exit(code)
+}
+
+func request(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+	app.R.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCollections(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+
+	rec := request("POST", "/v1/collection", "iniuserid0", []byte(`{"name": "Bacaan"}`))
+	if rec.Code != 201 {
+		t.Fatalf("Expected the resp code to be 201. Got %d", rec.Code)
+	}
+
+	var collection models.Collection
+	json.Unmarshal(rec.Body.Bytes(), &collection)
+
+	if rec := request("POST", "/v1/collection", "iniuserid0", []byte(`{"name": "bacaan"}`)); rec.Code != 409 {
+		t.Errorf("Expected a duplicate name to conflict. Got %d", rec.Code)
+	}
+
+	if rec := request("GET", "/v1/collection/"+collection.ID, "iniuserid1", nil); rec.Code != 404 {
+		t.Errorf("Expected another user's collection to be hidden. Got %d", rec.Code)
+	}
+
+	rec = request("PUT", "/v1/collection/"+collection.ID, "iniuserid0", []byte(`{"name": "Nanti"}`))
+	json.Unmarshal(rec.Body.Bytes(), &collection)
+
+	if collection.Name != "Nanti" {
+		t.Errorf("Expected the collection to be renamed. Got '%s'", collection.Name)
+	}
+
+	if rec := request("DELETE", "/v1/collection/"+collection.ID, "iniuserid0", nil); rec.Code != 200 {
+		t.Errorf("Expected the collection to be deleted. Got %d", rec.Code)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+	helper.AddPost(3, "iniuserid1")
+	productID := helper.AddProducts(1)
+
+	rec := request("POST", "/v1/collection", "iniuserid0", []byte(`{"name": "Bacaan"}`))
+	var collection models.Collection
+	json.Unmarshal(rec.Body.Bytes(), &collection)
+
+	items := []string{
+		`{"post_id": "inipostid0"}`,
+		`{"post_id": "inipostid1"}`,
+		`{"post_id": "inipostid2"}`,
+		fmt.Sprintf(`{"product_id": "%s"}`, productID),
+	}
+	for _, item := range items {
+		if rec := request("POST", "/v1/collection/"+collection.ID+"/items", "iniuserid0", []byte(item)); rec.Code != 201 {
+			t.Fatalf("Expected the resp code to be 201. Got %d", rec.Code)
+		}
+	}
+
+	if rec := request("POST", "/v1/collection/"+collection.ID+"/items", "iniuserid0", []byte(`{}`)); rec.Code != 400 {
+		t.Errorf("Expected a bookmark without an item to be rejected. Got %d", rec.Code)
+	}
+
+	rec = request("GET", "/v1/post/inipostid0", "iniuserid0", nil)
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if !post.BookmarkedByMe {
+		t.Errorf("Expected the post to be bookmarked by the viewer")
+	}
+
+	rec = request("GET", "/v1/product/"+productID, "iniuserid1", nil)
+	var product models.Product
+	json.Unmarshal(rec.Body.Bytes(), &product)
+
+	if product.BookmarkedByMe {
+		t.Errorf("Expected the product not to be bookmarked by another user")
+	}
+
+	request("DELETE", "/v1/post/inipostid1", "iniuserid1", nil)
+
+	var seen []models.Bookmark
+	cursor := ""
+	for {
+		rec := request("GET", "/v1/bookmarks?limit=2&cursor="+cursor, "iniuserid0", nil)
+		var page models.PayloadBookmarks
+		json.Unmarshal(rec.Body.Bytes(), &page)
+
+		seen = append(seen, page.Data...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if len(seen) != 3 {
+		t.Fatalf("Expected 3 visible bookmarks. Got %d", len(seen))
+	}
+
+	for _, b := range seen {
+		if b.PostId != nil && *b.PostId == "inipostid1" {
+			t.Errorf("Expected the bookmark of a deleted post to be hidden")
+		}
+		if b.Post == nil && b.Product == nil {
+			t.Errorf("Expected bookmark %s to embed its item", b.ID)
+		}
+	}
+
+	if rec := request("DELETE", "/v1/collection/"+collection.ID+"/product/"+productID, "iniuserid0", nil); rec.Code != 200 {
+		t.Errorf("Expected the bookmark to be removed. Got %d", rec.Code)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/bayudha2/go-test-0/config"
 	"github.com/bayudha2/go-test-0/helper"
@@ -39,7 +41,16 @@ func CreateComment(w http.ResponseWriter, r *http.Request) {
 	commentInput.UserId = userInfo.Userid
 	err := commentInput.CreateComment(models.DB)
 	if err != nil {
-		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+		case models.ErrParentComment:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case models.ErrCommentsLocked:
+			helper.RespondWithError(w, http.StatusForbidden, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
@@ -84,6 +95,8 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 		switch err {
 		case sql.ErrNoRows:
 			helper.RespondWithError(w, http.StatusNotFound, "Not Found!")
+		case models.ErrEditWindow:
+			helper.RespondWithError(w, http.StatusForbidden, err.Error())
 		default:
 			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		}
@@ -93,6 +106,37 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 	helper.RespondWithJSON(w, http.StatusOK, commentInput)
 }
 
+// GetCommentHistory lists the versions of a comment for its author, the
+// post's author and moderators.
+func GetCommentHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Id")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	comment := models.Comment{ID: id}
+	revisions, err := comment.GetHistory(models.DB, userInfo.Userid)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Comment not found!")
+		case models.ErrUnauthorized:
+			helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": revisions})
+}
+
 func DeleteComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -124,24 +168,121 @@ func DeleteComment(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// GetCommentsByPost lists the top-level comments of the post in post_id,
+// paged with page and limit or with cursor, and sorted by sort.
 func GetCommentsByPost(w http.ResponseWriter, r *http.Request) {
 	var comment models.Comment
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&comment); err != nil {
-		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+	comment.PostId = r.URL.Query().Get("post_id")
+	if comment.PostId == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Post id required!")
 		return
 	}
 
-	if comment.PostId == "" {
-		helper.RespondWithError(w, http.StatusBadRequest, "Post id required!")
+	query, err := commentQuery(r)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	defer r.Body.Close()
+	comments, err := comment.GetAllCommentByPost(models.DB, query)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, comments)
+}
+
+// GetReplies lists the direct replies to the comment, paged and sorted like
+// GetCommentsByPost.
+func GetReplies(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request id")
+		return
+	}
+
+	query, err := commentQuery(r)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	comment := models.Comment{ID: id}
+	replies, err := comment.GetReplies(models.DB, query)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, replies)
+}
 
-	comments, err := comment.GetAllCommentByPost(models.DB)
+func commentQuery(r *http.Request) (models.CommentQuery, error) {
+	cursor, err := models.ParseCursor(r.URL.Query().Get("cursor"))
 	if err != nil {
+		return models.CommentQuery{}, err
+	}
+
+	return models.CommentQuery{
+		ViewerID: utils.ViewerID(r),
+		Sort:     strings.ToLower(r.URL.Query().Get("sort")),
+		Cursor:   cursor,
+		Params: models.Params{
+			Page:  r.URL.Query().Get("page"),
+			Limit: r.URL.Query().Get("limit"),
+		},
+	}, nil
+}
+
+func respondWithListError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		helper.RespondWithError(w, http.StatusNotFound, "Comment not found!")
+	case models.ErrInvalidSort, models.ErrCursorSort:
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
 		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// GetCommentTree responds with the comments of the post in post_id as a
+// thread, nested down to max_depth levels of replies. sort is a comma
+// separated list of newest, oldest or top, one per level.
+func GetCommentTree(w http.ResponseWriter, r *http.Request) {
+	postID := r.URL.Query().Get("post_id")
+	if postID == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Post id required!")
+		return
+	}
+
+	maxDepth := models.DefaultThreadDepth
+	if value := r.URL.Query().Get("max_depth"); value != "" {
+		var err error
+		if maxDepth, err = strconv.Atoi(value); err != nil {
+			helper.RespondWithError(w, http.StatusBadRequest, models.ErrInvalidDepth.Error())
+			return
+		}
+	}
+
+	sorts, err := models.ParseCommentSorts(r.URL.Query().Get("sort"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	comments, err := models.GetCommentTree(models.DB, postID, utils.ViewerID(r), maxDepth, sorts)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+		case models.ErrInvalidDepth:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
@@ -160,7 +301,7 @@ func GetComment(w http.ResponseWriter, r *http.Request) {
 	var comment models.Comment
 	comment.ID = id
 
-	err := comment.GetComment(models.DB)
+	err := comment.GetComment(models.DB, utils.ViewerID(r))
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -173,3 +314,74 @@ func GetComment(w http.ResponseWriter, r *http.Request) {
 
 	helper.RespondWithJSON(w, http.StatusOK, comment)
 }
+
+// HideComment hides the comment from everyone but its author, the post's
+// author and moderators.
+func HideComment(w http.ResponseWriter, r *http.Request) {
+	moderateComment(w, r, (*models.Comment).HideComment)
+}
+
+func UnhideComment(w http.ResponseWriter, r *http.Request) {
+	moderateComment(w, r, (*models.Comment).UnhideComment)
+}
+
+// ApproveComment publishes a comment waiting for approval.
+func ApproveComment(w http.ResponseWriter, r *http.Request) {
+	moderateComment(w, r, (*models.Comment).ApproveComment)
+}
+
+func moderateComment(w http.ResponseWriter, r *http.Request, action func(*models.Comment, *sql.DB, string) error) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Id")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	comment := models.Comment{ID: id}
+	if err := action(&comment, models.DB, userInfo.Userid); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Comment not found!")
+		case models.ErrUnauthorized:
+			helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		case models.ErrCommentStatus:
+			helper.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, comment)
+}
+
+// GetModerationQueue lists the pending, or with status=hidden the hidden,
+// comments on the user's posts, optionally only those on post_id.
+func GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	var params = models.Params{
+		Page:  r.URL.Query().Get("page"),
+		Limit: r.URL.Query().Get("limit"),
+	}
+
+	comments, err := models.GetModerationQueue(models.DB, userInfo.Userid, r.URL.Query().Get("post_id"),
+		r.URL.Query().Get("status"), params)
+	if err != nil {
+		switch err {
+		case models.ErrCommentStatus:
+			helper.RespondWithError(w, http.StatusBadRequest, "status must be pending or hidden")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, comments)
+}
@@ -0,0 +1,522 @@
+package commentcontroller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bayudha2/go-test-0/app"
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+)
+
+func ensureTableExist() {
+	if _, err := models.DB.Exec(helper.TableUserCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePostCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableCommentCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableCommentRevisionCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableTagCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableMentionCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableNotificationCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableWebhookCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+func clearTable() {
+	models.DB.Exec("DELETE FROM webhook_deliveries;")
+	models.DB.Exec("DELETE FROM webhooks;")
+	models.DB.Exec("DELETE FROM notification_preferences;")
+	models.DB.Exec("DELETE FROM notifications;")
+	models.DB.Exec("DELETE FROM mentions;")
+	models.DB.Exec("DELETE FROM comment_revisions;")
+	models.DB.Exec("DELETE FROM comment_tags;")
+	models.DB.Exec("DELETE FROM comments;")
+	models.DB.Exec("DELETE FROM posts;")
+	models.DB.Exec("DELETE FROM users;")
+}
+
+func TestMain(m *testing.M) {
+	models.ConnectDatabase(
+		os.Getenv("APP_DB_USERNAME"),
+		os.Getenv("APP_DB_PASSWORD"),
+		os.Getenv("APP_DB_TEST_NAME"),
+	)
+
+	app.Initialize()
+
+	ensureTableExist()
+	code := m.Run()
+	clearTable()
+
+	os.Exit(code)
+}
+
+func request(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+	if userid != "" {
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+	}
+	app.R.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCommentTree(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+	helper.AddPost(1, "iniuserid0")
+
+	parent := func(id string) *string { return &id }
+	helper.AddComment("c1", "inipostid0", "iniuserid0", nil)
+	helper.AddComment("c2", "inipostid0", "iniuserid1", nil)
+	helper.AddComment("c2a", "inipostid0", "iniuserid0", parent("c2"))
+	helper.AddComment("c2b", "inipostid0", "iniuserid1", parent("c2"))
+	helper.AddComment("c2a1", "inipostid0", "iniuserid1", parent("c2a"))
+	helper.AddComment("c2a1x", "inipostid0", "iniuserid0", parent("c2a1"))
+	models.DB.Exec("UPDATE comments SET created_at = NOW() - INTERVAL '1 minute' WHERE id = 'c2b'")
+
+	if rec := request("GET", "/comments/tree?post_id=inipostid0&sort=best", "", nil); rec.Code != 400 {
+		t.Errorf("Expected an unknown sort to be rejected. Got %d", rec.Code)
+	}
+
+	rec := request("GET", "/comments/tree?post_id=inipostid0&max_depth=2&sort=top,oldest", "", nil)
+	var m models.PayloadComments
+	json.Unmarshal(rec.Body.Bytes(), &m)
+
+	if rec.Code != 200 || m.TotalData != 5 || len(m.Data) != 2 {
+		t.Fatalf("Expected 5 comments under 2 top-level ones. Got %d %+v", rec.Code, m)
+	}
+
+	c2 := m.Data[0]
+	if c2.ID != "c2" || c2.ReplyCount != 2 || len(c2.Replies) != 2 {
+		t.Fatalf("Expected the comment with the most replies first. Got %+v", c2)
+	}
+
+	if c2.Replies[0].ID != "c2b" || c2.Replies[1].ID != "c2a" {
+		t.Errorf("Expected replies oldest first. Got %s, %s", c2.Replies[0].ID, c2.Replies[1].ID)
+	}
+
+	deepest := c2.Replies[1].Replies[0]
+	if deepest.ID != "c2a1" || deepest.ReplyCount != 1 || len(deepest.Replies) != 0 {
+		t.Errorf("Expected replies below max_depth to be counted but left out. Got %+v", deepest)
+	}
+}
+
+func TestGetCommentsPaging(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+	helper.AddPost(1, "iniuserid0")
+
+	parent := func(id string) *string { return &id }
+	for i, id := range []string{"c0", "c1", "c2", "c3", "c4"} {
+		helper.AddComment(id, "inipostid0", "iniuserid0", nil)
+		models.DB.Exec("UPDATE comments SET created_at = NOW() - $1 * INTERVAL '1 minute' WHERE id = $2", 10-i, id)
+	}
+	helper.AddComment("r0", "inipostid0", "iniuserid0", parent("c3"))
+	helper.AddComment("r1", "inipostid0", "iniuserid0", parent("c3"))
+	helper.AddComment("r2", "inipostid0", "iniuserid0", parent("c1"))
+
+	list := func(path string) models.PayloadComments {
+		rec := request("GET", path, "", nil)
+		if rec.Code != 200 {
+			t.Fatalf("Expected the resp code to be 200 for %s. Got %d", path, rec.Code)
+		}
+
+		var m models.PayloadComments
+		json.Unmarshal(rec.Body.Bytes(), &m)
+		return m
+	}
+
+	ids := func(m models.PayloadComments) string {
+		var s string
+		for _, c := range m.Data {
+			s += c.ID + " "
+		}
+		return s
+	}
+
+	if rec := request("GET", "/comments", "", nil); rec.Code != 400 {
+		t.Errorf("Expected post_id to be required. Got %d", rec.Code)
+	}
+
+	m := list("/comments?post_id=inipostid0&limit=2&sort=newest")
+	if m.TotalData != 5 || ids(m) != "c4 c3 " || m.NextCursor == "" {
+		t.Fatalf("Expected the newest 2 of 5 comments and a cursor. Got %d %q %q", m.TotalData, ids(m), m.NextCursor)
+	}
+
+	m = list("/comments?post_id=inipostid0&limit=2&sort=newest&cursor=" + m.NextCursor)
+	if ids(m) != "c2 c1 " {
+		t.Errorf("Expected the cursor to continue after c3. Got %q", ids(m))
+	}
+
+	if m = list("/comments?post_id=inipostid0&limit=2&page=3"); ids(m) != "c4 " || m.NextCursor != "" {
+		t.Errorf("Expected the last page oldest first without a cursor. Got %q %q", ids(m), m.NextCursor)
+	}
+
+	if m = list("/comments?post_id=inipostid0&limit=2&sort=top"); ids(m) != "c3 c1 " {
+		t.Errorf("Expected the comments with most replies first. Got %q", ids(m))
+	}
+
+	if rec := request("GET", "/comments?post_id=inipostid0&sort=top&cursor="+m.NextCursor+"x", "", nil); rec.Code != 400 {
+		t.Errorf("Expected a bad cursor to be rejected. Got %d", rec.Code)
+	}
+
+	if m = list("/comment/c3/replies?limit=1"); m.TotalData != 2 || ids(m) != "r0 " || m.NextCursor == "" {
+		t.Errorf("Expected the first of 2 replies. Got %d %q", m.TotalData, ids(m))
+	}
+
+	if rec := request("GET", "/comment/missing/replies", "", nil); rec.Code != 404 {
+		t.Errorf("Expected replies of a missing comment to be not found. Got %d", rec.Code)
+	}
+}
+
+func TestCommentModeration(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+	helper.AddPost(1, "iniuserid0")
+	helper.SetUserRole("iniuserid2", "moderator")
+	models.DB.Exec("UPDATE posts SET comment_approval = true WHERE id = 'inipostid0'")
+
+	rec := request("POST", "/v1/comment", "iniuserid1", []byte(`{"post_id": "inipostid0", "content": "first"}`))
+	var c models.Comment
+	json.Unmarshal(rec.Body.Bytes(), &c)
+	if rec.Code != 201 || c.Status != models.CommentStatusPending {
+		t.Fatalf("Expected the comment to wait for approval. Got %d %+v", rec.Code, c)
+	}
+
+	if rec := request("GET", "/comment/"+c.ID, "", nil); rec.Code != 404 {
+		t.Errorf("Expected a pending comment to be hidden from others. Got %d", rec.Code)
+	}
+
+	if rec := request("GET", "/comment/"+c.ID, "iniuserid1", nil); rec.Code != 200 {
+		t.Errorf("Expected the author to see the pending comment. Got %d", rec.Code)
+	}
+
+	rec = request("GET", "/v1/moderation/comments", "iniuserid0", nil)
+	var queue models.PayloadComments
+	json.Unmarshal(rec.Body.Bytes(), &queue)
+	if rec.Code != 200 || queue.TotalData != 1 || queue.Data[0].ID != c.ID {
+		t.Fatalf("Expected the comment in the post author's queue. Got %d %+v", rec.Code, queue)
+	}
+
+	if rec := request("POST", "/v1/comment/"+c.ID+"/approve", "iniuserid1", nil); rec.Code != 401 {
+		t.Errorf("Expected the commenter not to approve their own comment. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/comment/"+c.ID+"/approve", "iniuserid0", nil); rec.Code != 200 {
+		t.Fatalf("Expected the post author to approve the comment. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/comment/"+c.ID+"/approve", "iniuserid0", nil); rec.Code != 409 {
+		t.Errorf("Expected approving twice to conflict. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/comment/"+c.ID+"/hide", "iniuserid2", nil); rec.Code != 200 {
+		t.Fatalf("Expected a moderator to hide the comment. Got %d", rec.Code)
+	}
+
+	if m := getComments(t, "/comments?post_id=inipostid0", ""); m.TotalData != 0 {
+		t.Errorf("Expected the hidden comment to be left out for anonymous viewers. Got %d", m.TotalData)
+	}
+
+	if m := getComments(t, "/comments?post_id=inipostid0", "iniuserid1"); m.TotalData != 1 {
+		t.Errorf("Expected the author to still see the hidden comment. Got %d", m.TotalData)
+	}
+
+	if rec := request("DELETE", "/v1/comment/"+c.ID, "iniuserid0", nil); rec.Code != 200 {
+		t.Errorf("Expected the post author to delete the comment. Got %d", rec.Code)
+	}
+}
+
+func getComments(t *testing.T, path, userid string) models.PayloadComments {
+	rec := request("GET", path, userid, nil)
+	if rec.Code != 200 {
+		t.Fatalf("Expected the resp code to be 200 for %s. Got %d", path, rec.Code)
+	}
+
+	var m models.PayloadComments
+	json.Unmarshal(rec.Body.Bytes(), &m)
+	return m
+}
+
+func TestDeleteCommentTombstone(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+	helper.AddPost(1, "iniuserid0")
+
+	parent := func(id string) *string { return &id }
+	helper.AddComment("c1", "inipostid0", "iniuserid0", nil)
+	helper.AddComment("c1a", "inipostid0", "iniuserid1", parent("c1"))
+	helper.AddComment("c1a1", "inipostid0", "iniuserid1", parent("c1a"))
+
+	if rec := request("DELETE", "/v1/comment/c1", "iniuserid0", nil); rec.Code != 200 {
+		t.Fatalf("Expected a comment with replies to be deleted. Got %d", rec.Code)
+	}
+
+	rec := request("GET", "/comment/c1", "", nil)
+	var c models.Comment
+	json.Unmarshal(rec.Body.Bytes(), &c)
+	if rec.Code != 200 || !c.Deleted || c.Content != models.DeletedCommentContent || c.UserId != "" {
+		t.Errorf("Expected a tombstone without its author. Got %d %+v", rec.Code, c)
+	}
+
+	m := getComments(t, "/comments/tree?post_id=inipostid0", "")
+	if m.TotalData != 3 || !m.Data[0].Deleted || m.Data[0].Replies[0].ID != "c1a" {
+		t.Fatalf("Expected the thread to stay under the tombstone. Got %+v", m)
+	}
+
+	if rec := request("DELETE", "/v1/comment/c1a", "iniuserid1", nil); rec.Code != 200 {
+		t.Fatalf("Expected the reply to be deleted. Got %d", rec.Code)
+	}
+
+	if rec := request("DELETE", "/v1/comment/c1a1", "iniuserid1", nil); rec.Code != 200 {
+		t.Fatalf("Expected the last reply to be deleted. Got %d", rec.Code)
+	}
+
+	var left int
+	models.DB.QueryRow("SELECT COUNT(*) FROM comments").Scan(&left)
+	if left != 0 {
+		t.Errorf("Expected the tombstones to go with their last reply. Got %d comments", left)
+	}
+}
+
+func TestCommentHistory(t *testing.T) {
+	defer clearTable()
+	defer func() { models.CommentEditWindow = 0 }()
+	helper.AddUsers(3)
+	helper.AddPost(1, "iniuserid0")
+	helper.AddComment("c1", "inipostid0", "iniuserid1", nil)
+
+	for _, content := range []string{"kedua", "ketiga"} {
+		body := []byte(`{"post_id": "inipostid0", "content": "` + content + `"}`)
+		if rec := request("PUT", "/v1/comment/c1", "iniuserid1", body); rec.Code != 200 {
+			t.Fatalf("Expected the comment to be updated. Got %d", rec.Code)
+		}
+	}
+
+	rec := request("GET", "/comment/c1", "", nil)
+	var c models.Comment
+	json.Unmarshal(rec.Body.Bytes(), &c)
+	if !c.Edited || c.EditCount != 2 {
+		t.Errorf("Expected the comment to be marked as edited twice. Got %+v", c)
+	}
+
+	if rec := request("GET", "/v1/comment/c1/history", "iniuserid2", nil); rec.Code != 401 {
+		t.Errorf("Expected others to be refused the history. Got %d", rec.Code)
+	}
+
+	rec = request("GET", "/v1/comment/c1/history", "iniuserid0", nil)
+	var history struct {
+		Data []models.CommentRevision `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &history)
+	if rec.Code != 200 || len(history.Data) != 3 || history.Data[1].Content != "kedua" || history.Data[2].Revision != 3 {
+		t.Errorf("Expected the post author to read all three versions. Got %d %+v", rec.Code, history.Data)
+	}
+
+	models.CommentEditWindow = time.Nanosecond
+	if rec := request("PUT", "/v1/comment/c1", "iniuserid1", []byte(`{"post_id": "inipostid0", "content": "keempat"}`)); rec.Code != 403 {
+		t.Errorf("Expected edits after the window to be refused. Got %d", rec.Code)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(4)
+	helper.AddPost(1, "iniuserid0")
+
+	getNotifications := func(userid, query string) models.PayloadNotifications {
+		rec := request("GET", "/v1/notifications"+query, userid, nil)
+		if rec.Code != 200 {
+			t.Fatalf("Expected the resp code to be 200. Got %d", rec.Code)
+		}
+
+		var m models.PayloadNotifications
+		json.Unmarshal(rec.Body.Bytes(), &m)
+		return m
+	}
+
+	rec := request("POST", "/v1/comment", "iniuserid1", []byte(`{"post_id": "inipostid0", "content": "pertama"}`))
+	var c models.Comment
+	json.Unmarshal(rec.Body.Bytes(), &c)
+	request("POST", "/v1/comment", "iniuserid2", []byte(`{"post_id": "inipostid0", "content": "kedua"}`))
+	request("POST", "/v1/comment", "iniuserid0", []byte(`{"post_id": "inipostid0", "content": "balas", "comment_id": "`+c.ID+`"}`))
+
+	m := getNotifications("iniuserid0", "")
+	if m.TotalData != 1 || m.UnreadCount != 1 || m.Data[0].EventCount != 2 ||
+		m.Data[0].Message != "iniusername2 and iniusername1 commented on your post" {
+		t.Fatalf("Expected both comments in one notification. Got %+v", m)
+	}
+
+	m = getNotifications("iniuserid1", "")
+	if m.TotalData != 1 || m.Data[0].Type != models.NotificationReply {
+		t.Errorf("Expected the commenter to hear of the reply. Got %+v", m)
+	}
+
+	if rec := request("PUT", "/v1/notifications/preferences", "iniuserid0", []byte(`{"likes": false}`)); rec.Code != 400 {
+		t.Errorf("Expected an unknown type to be rejected. Got %d", rec.Code)
+	}
+
+	if rec := request("PUT", "/v1/notifications/preferences", "iniuserid0", []byte(`{"comment": false}`)); rec.Code != 200 {
+		t.Errorf("Expected the preference to be saved. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/notification/"+m.Data[0].ID+"/read", "iniuserid0", nil); rec.Code != 404 {
+		t.Errorf("Expected others' notifications to be left alone. Got %d", rec.Code)
+	}
+
+	m = getNotifications("iniuserid0", "")
+	if rec := request("POST", "/v1/notification/"+m.Data[0].ID+"/read", "iniuserid0", nil); rec.Code != 200 {
+		t.Errorf("Expected the notification to be marked read. Got %d", rec.Code)
+	}
+
+	request("POST", "/v1/comment", "iniuserid3", []byte(`{"post_id": "inipostid0", "content": "ketiga"}`))
+	if m := getNotifications("iniuserid0", "?unread=true"); m.TotalData != 0 {
+		t.Errorf("Expected no notification for a type turned off. Got %+v", m)
+	}
+
+	if rec := request("POST", "/v1/notifications/read-all", "iniuserid1", nil); rec.Code != 200 {
+		t.Errorf("Expected all notifications to be marked read. Got %d", rec.Code)
+	}
+
+	if m := getNotifications("iniuserid1", ""); m.TotalData != 1 || m.UnreadCount != 0 || !m.Data[0].Read {
+		t.Errorf("Expected the reply to stay listed as read. Got %+v", m)
+	}
+}
+
+func TestCommentsOnDraft(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+	helper.AddPost(1, "iniuserid0")
+	helper.AddComment("c1", "inipostid0", "iniuserid0", nil)
+	models.DB.Exec("UPDATE posts SET status = 'draft' WHERE id = 'inipostid0'")
+
+	payload := []byte(`{"post_id": "inipostid0", "content": "first"}`)
+	if rec := request("POST", "/v1/comment", "iniuserid1", payload); rec.Code != 404 {
+		t.Errorf("Expected commenting on someone else's draft to be not found. Got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/comments?post_id=inipostid0", "/comments/tree?post_id=inipostid0", "/comment/c1", "/comment/c1/replies"} {
+		if rec := request("GET", path, "iniuserid1", nil); rec.Code != 404 {
+			t.Errorf("Expected %s of a draft to be not found. Got %d", path, rec.Code)
+		}
+	}
+
+	if rec := request("POST", "/v1/comment", "iniuserid0", payload); rec.Code != 201 {
+		t.Errorf("Expected the author to comment on their draft. Got %d", rec.Code)
+	}
+
+	if m := getComments(t, "/comments?post_id=inipostid0", "iniuserid0"); m.TotalData != 2 {
+		t.Errorf("Expected the author to see the comments of their draft. Got %d", m.TotalData)
+	}
+}
+
+func TestMentionsInModeratedComments(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+	helper.AddPost(1, "iniuserid0")
+	models.DB.Exec("UPDATE posts SET comment_approval = true WHERE id = 'inipostid0'")
+
+	rec := request("POST", "/v1/comment", "iniuserid1", []byte(`{"post_id": "inipostid0", "content": "hai @iniusername2"}`))
+	var c models.Comment
+	json.Unmarshal(rec.Body.Bytes(), &c)
+	if rec.Code != 201 || c.Status != models.CommentStatusPending {
+		t.Fatalf("Expected the comment to wait for approval. Got %d %+v", rec.Code, c)
+	}
+
+	mentions := func() int {
+		var m models.PayloadMentions
+		json.Unmarshal(request("GET", "/v1/mentions", "iniuserid2", nil).Body.Bytes(), &m)
+		return m.TotalData
+	}
+
+	if n := mentions(); n != 0 {
+		t.Errorf("Expected a mention in a pending comment to be left out. Got %d", n)
+	}
+
+	request("POST", "/v1/comment/"+c.ID+"/approve", "iniuserid0", nil)
+	if n := mentions(); n != 1 {
+		t.Errorf("Expected the mention once the comment is approved. Got %d", n)
+	}
+
+	request("POST", "/v1/comment/"+c.ID+"/hide", "iniuserid0", nil)
+	if n := mentions(); n != 0 {
+		t.Errorf("Expected a mention in a hidden comment to be left out. Got %d", n)
+	}
+}
+
+func TestMentionNotificationsInModeratedComments(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+	helper.AddPost(1, "iniuserid0")
+	models.DB.Exec("UPDATE posts SET comment_approval = true WHERE id = 'inipostid0'")
+
+	rec := request("POST", "/v1/comment", "iniuserid1", []byte(`{"post_id": "inipostid0", "content": "hai @iniusername2"}`))
+	var c models.Comment
+	json.Unmarshal(rec.Body.Bytes(), &c)
+	if rec.Code != 201 || c.Status != models.CommentStatusPending {
+		t.Fatalf("Expected the comment to wait for approval. Got %d %+v", rec.Code, c)
+	}
+
+	mentions := func(userid string) []models.Notification {
+		var m models.PayloadNotifications
+		json.Unmarshal(request("GET", "/v1/notifications", userid, nil).Body.Bytes(), &m)
+
+		var got []models.Notification
+		for _, n := range m.Data {
+			if n.Type == models.NotificationMention {
+				got = append(got, n)
+			}
+		}
+		return got
+	}
+
+	if got := mentions("iniuserid2"); len(got) != 0 {
+		t.Errorf("Expected no mention notification for a pending comment. Got %+v", got)
+	}
+
+	request("POST", "/v1/comment/"+c.ID+"/approve", "iniuserid0", nil)
+	got := mentions("iniuserid2")
+	if len(got) != 1 || got[0].CommentId == nil || *got[0].CommentId != c.ID {
+		t.Errorf("Expected a mention notification once the comment is approved. Got %+v", got)
+	}
+
+	request("POST", "/v1/comment/"+c.ID+"/hide", "iniuserid0", nil)
+	request("PUT", "/v1/comment/"+c.ID, "iniuserid1", []byte(`{"content": "hai @iniusername2 @iniusername0"}`))
+	if got := mentions("iniuserid0"); len(got) != 0 {
+		t.Errorf("Expected no mention notification from editing a hidden comment. Got %+v", got)
+	}
+}
@@ -0,0 +1,28 @@
+package mentioncontroller
+
+import (
+	"net/http"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+)
+
+func GetMentions(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	var params = models.Params{
+		Page:  r.URL.Query().Get("page"),
+		Limit: r.URL.Query().Get("limit"),
+	}
+
+	mentions, err := models.GetMentions(models.DB, userInfo.Userid, params)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, mentions)
+}
@@ -0,0 +1,114 @@
+package notificationcontroller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetNotifications lists the user's notifications, only the unread ones
+// with ?unread=true.
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	params := models.Params{Page: r.URL.Query().Get("page"), Limit: r.URL.Query().Get("limit")}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := models.GetNotifications(models.DB, userInfo.Userid, unreadOnly, params)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, notifications)
+}
+
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	if err := models.MarkNotificationRead(models.DB, userInfo.Userid, id); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Notification not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	n, err := models.MarkAllNotificationsRead(models.DB, userInfo.Userid)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]int64{"marked": n})
+}
+
+// GetPreferences shows which notification types the user receives.
+func GetPreferences(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	prefs, err := models.GetNotificationPreferences(models.DB, userInfo.Userid)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, prefs)
+}
+
+// UpdatePreferences turns notification types on or off, e.g.
+// {"comment": false}.
+func UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	var prefs map[string]bool
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	if err := models.SaveNotificationPreferences(models.DB, userInfo.Userid, prefs); err != nil {
+		switch err {
+		case models.ErrNotificationType:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	GetPreferences(w, r)
+}
@@ -3,9 +3,11 @@ package postcontroller
 import (
 	"database/sql"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bayudha2/go-test-0/config"
 	"github.com/bayudha2/go-test-0/helper"
@@ -41,13 +43,327 @@ func CreatePost(w http.ResponseWriter, r *http.Request) {
 	postInput.UserId = userInfo.Userid
 	err := postInput.CreatePost(models.DB)
 	if err != nil {
-		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+		case models.ErrAlreadyReposted:
+			helper.RespondWithError(w, http.StatusConflict, err.Error())
+		case models.ErrInvalidPublishAt, models.ErrInvalidAttachment, models.ErrInvalidPoll, models.ErrInvalidClosesAt:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
 	helper.RespondWithJSON(w, http.StatusCreated, postInput)
 }
 
+// RepostPost shares the post, as a repost or, when the body has a
+// description, as a quote.
+func RepostPost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var post models.Post
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&post); err != nil && err != io.EOF {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	repost := models.Post{
+		UserId:        userInfo.Userid,
+		Description:   strings.TrimSpace(post.Description),
+		RepostOfId:    &id,
+		AttachmentIds: post.AttachmentIds,
+	}
+
+	if err := repost.CreatePost(models.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+		case models.ErrAlreadyReposted:
+			helper.RespondWithError(w, http.StatusConflict, err.Error())
+		case models.ErrInvalidAttachment:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, repost)
+}
+
+// UndoRepost removes the user's repost of the post.
+func UndoRepost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	if err := models.UndoRepost(models.DB, userInfo.Userid, id); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Repost not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+// VotePoll records the user's vote in the post's poll and responds with the
+// updated poll.
+func VotePoll(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	var vote struct {
+		OptionIds []string `json:"option_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&vote); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	if err := post.Vote(models.DB, userInfo.Userid, vote.OptionIds); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Poll not found")
+		case models.ErrInvalidVote:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case models.ErrPollClosed, models.ErrAlreadyVoted:
+			helper.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, post.Poll)
+}
+
+// UpdateCommentSettings lets the post's author require approval for new
+// comments.
+func UpdateCommentSettings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	var input struct {
+		ApprovalRequired *bool `json:"approval_required" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	if listErr, err := validation.Validate(&input); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id, UserId: userInfo.Userid}
+	if err := post.SetCommentApproval(models.DB, *input.ApprovalRequired); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, post)
+}
+
+// ForceContentWarning lets a moderator flag someone else's post as
+// sensitive, with the label in the optional body.
+func ForceContentWarning(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var input struct {
+		ContentWarning *string `json:"content_warning" validate:"omitempty,max=100"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil && err != io.EOF {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	if listErr, err := validation.Validate(&input); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id, ContentWarning: input.ContentWarning}
+	respondWithPostResult(w, &post, post.ForceContentWarning(models.DB, userInfo.Userid))
+}
+
+// LiftContentWarning lets a moderator remove the warning of a post.
+func LiftContentWarning(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	respondWithPostResult(w, &post, post.LiftContentWarning(models.DB, userInfo.Userid))
+}
+
+// LockComments stops new comments on the post. The optional body picks
+// who may still comment: {"mode": "followers"} lets the author's followers
+// in, the default "everyone" mode only the author and moderators.
+func LockComments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var input struct {
+		Mode string `json:"mode" validate:"omitempty,oneof=everyone followers"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil && err != io.EOF {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	if listErr, err := validation.Validate(&input); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	if input.Mode == "" {
+		input.Mode = models.CommentLockEveryone
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	respondWithPostResult(w, &post, post.LockComments(models.DB, userInfo.Userid, input.Mode))
+}
+
+func UnlockComments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	respondWithPostResult(w, &post, post.UnlockComments(models.DB, userInfo.Userid))
+}
+
+func respondWithPostResult(w http.ResponseWriter, post *models.Post, err error) {
+	switch err {
+	case nil:
+		helper.RespondWithJSON(w, http.StatusOK, post)
+	case sql.ErrNoRows:
+		helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+	case models.ErrUnauthorized:
+		helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	default:
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// ClosePoll lets the author close the post's poll before its closing time.
+func ClosePoll(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id, UserId: userInfo.Userid}
+	if err := post.ClosePoll(models.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Open poll not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, post.Poll)
+}
+
 func UpdatePost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -69,6 +385,8 @@ func UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// What a post shares is fixed once it is created.
+	post.RepostOfId = nil
 	if listErr, err := validation.Validate(&post); err != nil {
 		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
 		return
@@ -86,6 +404,8 @@ func UpdatePost(w http.ResponseWriter, r *http.Request) {
 		switch err {
 		case sql.ErrNoRows:
 			helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized request!")
+		case models.ErrInvalidPublishAt, models.ErrRepostNotEditable:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
 		default:
 			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		}
@@ -131,6 +451,7 @@ func GetPosts(w http.ResponseWriter, r *http.Request) {
 	order := r.URL.Query().Get("order")
 	by := r.URL.Query().Get("by")
 	search := r.URL.Query().Get("search")
+	status := r.URL.Query().Get("status")
 
 	if page == "" {
 		page = strconv.Itoa(1)
@@ -150,6 +471,7 @@ func GetPosts(w http.ResponseWriter, r *http.Request) {
 		Order:  order,
 		By:     by,
 		Search: search,
+		Status: status,
 	}
 
 	var post models.Post
@@ -175,10 +497,13 @@ func GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
 	var post models.Post
 	post.ID = id
 
-	if err := post.GetPost(models.DB); err != nil {
+	if err := post.GetPost(models.DB, userInfo.Userid); err != nil {
 		switch err {
 		case sql.ErrNoRows:
 			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
@@ -188,5 +513,141 @@ func GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if post.UserId != userInfo.Userid {
+		models.Views.Record(post.ID, userInfo.Userid, time.Now())
+	}
+
 	helper.RespondWithJSON(w, http.StatusOK, post)
 }
+
+// GetPostViews responds with the view counts of the post, in total and for
+// each of the last days (30 unless given, at most 365).
+func GetPostViews(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		var err error
+		if days, err = strconv.Atoi(d); err != nil || days < 1 || days > 365 {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid days")
+			return
+		}
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	stats, err := post.GetViewStats(models.DB, userInfo.Userid, days)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+		case models.ErrUnauthorized:
+			helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, stats)
+}
+
+func GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	revisions, err := post.GetRevisions(models.DB, userInfo.Userid)
+	if err != nil {
+		respondWithRevisionError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": revisions})
+}
+
+func GetPostRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid from revision")
+		return
+	}
+
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid to revision")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	diff, err := post.DiffRevisions(models.DB, userInfo.Userid, from, to)
+	if err != nil {
+		respondWithRevisionError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, diff)
+}
+
+func RestorePostRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid revision")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id}
+	if err := post.RestoreRevision(models.DB, revision, userInfo.Userid); err != nil {
+		respondWithRevisionError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, post)
+}
+
+func respondWithRevisionError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		helper.RespondWithError(w, http.StatusNotFound, "Post not found")
+	case models.ErrRevisionNotFound:
+		helper.RespondWithError(w, http.StatusNotFound, err.Error())
+	case models.ErrUnauthorized:
+		helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	default:
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
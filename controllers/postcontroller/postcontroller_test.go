@@ -2,18 +2,24 @@ package postcontroller_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bayudha2/go-test-0/app"
 	"github.com/bayudha2/go-test-0/config"
 	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/jobs"
 	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/unfurl"
 )
 
 func ensureTableExist() {
@@ -24,9 +30,86 @@ func ensureTableExist() {
 	if _, err := models.DB.Exec(helper.TablePostCreationQuery); err != nil {
 		log.Fatal(err.Error())
 	}
+
+	if _, err := models.DB.Exec(helper.TablePostIndexingQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePostRevisionCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableCommentCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableTagCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableMentionCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableAttachmentCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableProductCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableBookmarkCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePollCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableLinkPreviewCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePostViewCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePreferenceCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableFollowCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableNotificationCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableWebhookCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
 }
 
 func clearTable() {
+	models.DB.Exec("DELETE FROM webhook_deliveries;")
+	models.DB.Exec("DELETE FROM webhooks;")
+	models.DB.Exec("DELETE FROM notification_preferences;")
+	models.DB.Exec("DELETE FROM notifications;")
+	models.DB.Exec("DELETE FROM user_follows;")
+	models.DB.Exec("DELETE FROM user_preferences;")
+	models.DB.Exec("DELETE FROM post_views;")
+	models.DB.Exec("DELETE FROM post_links;")
+	models.DB.Exec("DELETE FROM link_previews;")
+	models.DB.Exec("DELETE FROM polls;")
+	models.DB.Exec("DELETE FROM bookmarks;")
+	models.DB.Exec("DELETE FROM bookmark_collections;")
+	models.DB.Exec("DELETE FROM attachments;")
+	models.DB.Exec("DELETE FROM mentions;")
+	models.DB.Exec("DELETE FROM post_tags;")
+	models.DB.Exec("DELETE FROM tags;")
+	models.DB.Exec("DELETE FROM comments;")
 	models.DB.Exec("TRUNCATE users;")
 	models.DB.Exec("DELETE FROM users;")
 	models.DB.Exec("TRUNCATE posts;")
@@ -101,6 +184,35 @@ func TestCreatePostSuccess(t *testing.T) {
 	}
 }
 
+func TestCreatePostRendersMarkdown(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	var jsonPayload = []byte(`{
+		"description": "ini **tebal** dan [tautan](javascript:alert(1)) <script>alert(1)</script>"
+	}`)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/post", bytes.NewBuffer(jsonPayload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	expected := "<p>ini <strong>tebal</strong> dan tautan &lt;script&gt;alert(1)&lt;/script&gt;</p>\n"
+	if post.DescriptionHTML != expected {
+		t.Errorf("Expected the description_html to be %q. Got %q", expected, post.DescriptionHTML)
+	}
+}
+
 func TestGetAllPost(t *testing.T) {
 	defer clearTable()
 	helper.AddUsers(1)
@@ -259,3 +371,924 @@ func TestDeletePostSuccess(t *testing.T) {
 		t.Errorf("Expected the resp code to be 200. Got %d", rec.Code)
 	}
 }
+
+func TestSearchPosts(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+	helper.AddPost(3, "iniuserid0")
+	models.DB.Exec(`INSERT INTO posts(id, user_id, description) VALUES($1, $2, $3)`,
+		"inipostkopi", "iniuserid0", "pagi ini minum kopi susu di warung")
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token")
+	}
+
+	var access = fmt.Sprintf("Bearer %s", accessToken.Token)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", `/v1/posts?search=%22kopi+susu%22`, nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", access)
+
+	app.R.ServeHTTP(rec, req)
+
+	var m models.PayloadPosts
+	json.Unmarshal(rec.Body.Bytes(), &m)
+
+	if m.TotalData != 1 || len(m.Data) != 1 {
+		t.Fatalf("Expected exactly one matching post. Got %d", m.TotalData)
+	}
+
+	if m.Data[0].ID != "inipostkopi" {
+		t.Errorf("Expected the matching post to be 'inipostkopi'. Got %s", m.Data[0].ID)
+	}
+
+	if !strings.Contains(m.Data[0].Snippet, "<mark>kopi</mark>") {
+		t.Errorf("Expected the snippet to highlight the match. Got %s", m.Data[0].Snippet)
+	}
+}
+
+func TestSearchPostsPrefix(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+	helper.AddPost(3, "iniuserid0")
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token")
+	}
+
+	var access = fmt.Sprintf("Bearer %s", accessToken.Token)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/posts?search=pos*&limit=2", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", access)
+
+	app.R.ServeHTTP(rec, req)
+
+	var m models.PayloadPosts
+	json.Unmarshal(rec.Body.Bytes(), &m)
+
+	if m.TotalData != 3 {
+		t.Errorf("Expected total resp data to be 3. Got %d", m.TotalData)
+	}
+
+	if len(m.Data) != 2 {
+		t.Errorf("Expected the page to hold 2 posts. Got %d", len(m.Data))
+	}
+}
+
+func TestGetPostsByTag(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	var access = fmt.Sprintf("Bearer %s", accessToken.Token)
+	for _, desc := range []string{"ngopi dulu #Kopi", "#kopi bareng @iniusername1 dan @orangasing", "tanpa tag"} {
+		payload := []byte(fmt.Sprintf(`{"description": "%s"}`, desc))
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/post", bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", access)
+
+		app.R.ServeHTTP(rec, req)
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/tags/kopi/posts", nil)
+	app.R.ServeHTTP(rec, req)
+
+	var m models.PayloadPosts
+	json.Unmarshal(rec.Body.Bytes(), &m)
+
+	if m.TotalData != 2 {
+		t.Errorf("Expected 2 posts tagged #kopi. Got %d", m.TotalData)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/tags/trending", nil)
+	app.R.ServeHTTP(rec, req)
+
+	var trending map[string][]models.Tag
+	json.Unmarshal(rec.Body.Bytes(), &trending)
+
+	if len(trending["data"]) != 1 || trending["data"][0].Name != "kopi" || trending["data"][0].Uses != 2 {
+		t.Errorf("Expected #kopi to trend with 2 uses. Got %v", trending["data"])
+	}
+}
+
+func TestGetMentions(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	payload := []byte(`{"description": "halo @iniusername1, @iniusername1 dan @tidakada"}`)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/post", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	var mentionedToken config.TokenPayload
+	if err := mentionedToken.CreateToken("iniuserid1", "iniusername1", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/mentions", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", mentionedToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	var m models.PayloadMentions
+	json.Unmarshal(rec.Body.Bytes(), &m)
+
+	if m.TotalData != 1 || m.Data[0].AuthorUsername != "iniusername0" {
+		t.Errorf("Expected one mention by iniusername0. Got %v", m.Data)
+	}
+}
+
+func TestDraftPostHiddenFromOthers(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(2)
+
+	var ownerToken config.TokenPayload
+	if err := ownerToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	payload := []byte(`{"description": "masih draft", "status": "draft"}`)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/post", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ownerToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	var created models.Post
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	if created.Status != models.PostStatusDraft {
+		t.Fatalf("Expected the post to be a draft. Got %s", created.Status)
+	}
+
+	var otherToken config.TokenPayload
+	if err := otherToken.CreateToken("iniuserid1", "iniusername1", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/"+created.ID, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", otherToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("Expected a draft to be hidden from other users. Got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/"+created.ID, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ownerToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 200 {
+		t.Errorf("Expected the author to see the draft. Got %d", rec.Code)
+	}
+}
+
+func TestScheduledPostRejectsPastTime(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	payload := []byte(`{"description": "telat", "status": "scheduled", "publish_at": "2020-01-01T00:00:00Z"}`)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/post", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 400 {
+		t.Errorf("Expected the resp code to be 400. Got %d", rec.Code)
+	}
+}
+
+func TestPublishDuePosts(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+	models.DB.Exec(`INSERT INTO posts(id, user_id, description, status, publish_at)
+		VALUES('inipostdue', 'iniuserid0', 'sudah waktunya', 'scheduled', NOW() - INTERVAL '1 minute'),
+			('inipostlater', 'iniuserid0', 'belum waktunya', 'scheduled', NOW() + INTERVAL '1 hour')`)
+
+	ids, err := models.PublishDuePosts(models.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 1 || ids[0] != "inipostdue" {
+		t.Errorf("Expected only 'inipostdue' to be published. Got %v", ids)
+	}
+
+	ids, _ = models.PublishDuePosts(models.DB)
+	if len(ids) != 0 {
+		t.Errorf("Expected a post to be published only once. Got %v", ids)
+	}
+
+	// Tags written days before the post went live trend from its publish time.
+	models.DB.Exec(`INSERT INTO tags(id, name) VALUES('initagjadwal', 'jadwal')`)
+	models.DB.Exec(`INSERT INTO post_tags(post_id, tag_id, created_at)
+		VALUES('inipostdue', 'initagjadwal', NOW() - INTERVAL '3 days')`)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/tags/trending", nil)
+	app.R.ServeHTTP(rec, req)
+
+	var trending map[string][]models.Tag
+	json.Unmarshal(rec.Body.Bytes(), &trending)
+	if len(trending["data"]) != 1 || trending["data"][0].Name != "jadwal" {
+		t.Errorf("Expected #jadwal to trend once its post is published. Got %v", trending["data"])
+	}
+}
+
+func TestPostRevisions(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+	helper.SetUserRole("iniuserid2", models.RoleAdmin)
+
+	var ownerToken config.TokenPayload
+	if err := ownerToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	var owner = fmt.Sprintf("Bearer %s", ownerToken.Token)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/post", bytes.NewBuffer([]byte(`{"description": "kopi hitam manis"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", owner)
+	app.R.ServeHTTP(rec, req)
+
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/v1/post/"+post.ID, bytes.NewBuffer([]byte(`{"description": "kopi susu manis"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", owner)
+	app.R.ServeHTTP(rec, req)
+
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if !post.Edited || post.EditCount != 1 {
+		t.Errorf("Expected the post to be marked as edited once. Got %v", post)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/"+post.ID+"/revisions/diff?from=1&to=2", nil)
+	req.Header.Set("Authorization", owner)
+	app.R.ServeHTTP(rec, req)
+
+	var diff models.RevisionDiff
+	json.Unmarshal(rec.Body.Bytes(), &diff)
+	if len(diff.Changes) != 4 || diff.Changes[1].Text != "hitam" || diff.Changes[2].Text != "susu" {
+		t.Errorf("Expected 'hitam' to be replaced by 'susu'. Got %v", diff.Changes)
+	}
+
+	var otherToken config.TokenPayload
+	if err := otherToken.CreateToken("iniuserid1", "iniusername1", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/"+post.ID+"/revisions", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", otherToken.Token))
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 401 {
+		t.Errorf("Expected other users to be refused the history. Got %d", rec.Code)
+	}
+
+	var adminToken config.TokenPayload
+	if err := adminToken.CreateToken("iniuserid2", "iniusername2", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/post/"+post.ID+"/revisions/1/restore", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", adminToken.Token))
+	app.R.ServeHTTP(rec, req)
+
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if post.Description != "kopi hitam manis" || post.EditCount != 2 {
+		t.Errorf("Expected the admin to restore the first revision. Got %v", post)
+	}
+}
+
+func TestDeletedPostMovesToTrash(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+	helper.AddPost(2, "iniuserid0")
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token")
+	}
+
+	var access = fmt.Sprintf("Bearer %s", accessToken.Token)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/v1/post/inipostid1", nil)
+	req.Header.Set("Authorization", access)
+	app.R.ServeHTTP(rec, req)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/inipostid1", nil)
+	req.Header.Set("Authorization", access)
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("Expected a deleted post to be hidden. Got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/trash", nil)
+	req.Header.Set("Authorization", access)
+	app.R.ServeHTTP(rec, req)
+
+	var trash models.Trash
+	json.Unmarshal(rec.Body.Bytes(), &trash)
+
+	if len(trash.Posts) != 1 || trash.Posts[0].ID != "inipostid1" {
+		t.Fatalf("Expected the deleted post in the trash. Got %v", trash.Posts)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/trash/post/inipostid1/restore", nil)
+	req.Header.Set("Authorization", access)
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 200 {
+		t.Errorf("Expected the resp code to be 200. Got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/post/inipostid1", nil)
+	req.Header.Set("Authorization", access)
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 200 {
+		t.Errorf("Expected the restored post to be visible. Got %d", rec.Code)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+	helper.AddPost(2, "iniuserid0")
+
+	parent := "inicommentid0"
+	helper.AddComment("inicommentid0", "inipostid0", "iniuserid0", nil)
+	helper.AddComment("inicommentid1", "inipostid0", "iniuserid0", &parent)
+	helper.AddComment("inicommentid2", "inipostid1", "iniuserid0", nil)
+
+	models.DB.Exec(`UPDATE posts SET deleted_at = NOW() - INTERVAL '100 days' WHERE id = 'inipostid0'`)
+	models.DB.Exec(`UPDATE comments SET deleted_at = NOW() - INTERVAL '100 days' WHERE id = 'inicommentid2'`)
+
+	if _, err := models.PurgeTrash(models.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	var posts, comments int
+	models.DB.QueryRow("SELECT COUNT(*) FROM posts").Scan(&posts)
+	models.DB.QueryRow("SELECT COUNT(*) FROM comments").Scan(&comments)
+
+	if posts != 1 || comments != 0 {
+		t.Errorf("Expected 1 post and no comments after the purge. Got %d and %d", posts, comments)
+	}
+}
+
+func TestRepostAndQuote(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+	helper.AddPost(1, "iniuserid0")
+
+	request := func(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+		app.R.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("POST", "/v1/post/inipostid0/repost", "iniuserid1", nil)
+	if rec.Code != 201 {
+		t.Fatalf("Expected the resp code to be 201. Got %d", rec.Code)
+	}
+
+	var repost models.Post
+	json.Unmarshal(rec.Body.Bytes(), &repost)
+
+	if repost.Kind != models.PostKindRepost || repost.Original == nil || repost.Original.ID != "inipostid0" {
+		t.Fatalf("Expected a repost embedding the original. Got %+v", repost)
+	}
+
+	if rec := request("POST", "/v1/post/"+repost.ID+"/repost", "iniuserid1", nil); rec.Code != 409 {
+		t.Errorf("Expected reposting your own repost to conflict. Got %d", rec.Code)
+	}
+
+	rec = request("POST", "/v1/post/"+repost.ID+"/repost", "iniuserid2", []byte(`{"description": "lihat ini"}`))
+	var quote models.Post
+	json.Unmarshal(rec.Body.Bytes(), &quote)
+
+	if quote.Kind != models.PostKindQuote || quote.RepostOfId == nil || *quote.RepostOfId != "inipostid0" {
+		t.Errorf("Expected a quote of the original post. Got %+v", quote)
+	}
+
+	rec = request("GET", "/v1/post/inipostid0", "iniuserid2", nil)
+	var original models.Post
+	json.Unmarshal(rec.Body.Bytes(), &original)
+
+	if original.RepostCount != 1 || original.QuoteCount != 1 {
+		t.Errorf("Expected 1 repost and 1 quote. Got %d and %d", original.RepostCount, original.QuoteCount)
+	}
+
+	payload := []byte(`{"repost_of_id": "inipostid0"}`)
+	rec = request("POST", "/v1/post", "iniuserid2", payload)
+	var created models.Post
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	if rec.Code != 201 || created.Kind != models.PostKindRepost {
+		t.Errorf("Expected a post with only repost_of_id to be a repost. Got %d %+v", rec.Code, created)
+	}
+
+	if rec := request("POST", "/v1/post", "iniuserid2", payload); rec.Code != 409 {
+		t.Errorf("Expected a second repost to conflict. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/post", "iniuserid2", []byte(`{"status": "draft"}`)); rec.Code != 400 {
+		t.Errorf("Expected a post without a description to be rejected. Got %d", rec.Code)
+	}
+
+	request("DELETE", "/v1/post/inipostid0", "iniuserid0", nil)
+
+	if rec := request("GET", "/v1/post/"+repost.ID, "iniuserid2", nil); rec.Code != 404 {
+		t.Errorf("Expected a repost of a deleted post to be hidden. Got %d", rec.Code)
+	}
+
+	rec = request("GET", "/v1/post/"+repost.ID, "iniuserid1", nil)
+	repost = models.Post{}
+	json.Unmarshal(rec.Body.Bytes(), &repost)
+
+	if !repost.OriginalUnavailable || repost.Original != nil {
+		t.Errorf("Expected the author to see the repost without its original. Got %+v", repost)
+	}
+
+	rec = request("GET", "/v1/post/"+quote.ID, "iniuserid1", nil)
+	quote = models.Post{}
+	json.Unmarshal(rec.Body.Bytes(), &quote)
+
+	if rec.Code != 200 || !quote.OriginalUnavailable {
+		t.Errorf("Expected the quote to stay visible without its original. Got %d %+v", rec.Code, quote)
+	}
+
+	if rec := request("DELETE", "/v1/post/inipostid0/repost", "iniuserid1", nil); rec.Code != 200 {
+		t.Errorf("Expected the repost to be undone. Got %d", rec.Code)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+
+	request := func(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+		app.R.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("POST", "/v1/post", "iniuserid0", []byte(`{
+		"description": "pilih satu",
+		"poll": {"options": [{"label": "A"}]}
+	}`))
+	if rec.Code != 400 {
+		t.Errorf("Expected a poll with one option to be rejected. Got %d", rec.Code)
+	}
+
+	rec = request("POST", "/v1/post", "iniuserid0", []byte(`{
+		"description": "pilih satu",
+		"poll": {"options": [{"label": "A"}, {"label": "B"}], "hide_results": true}
+	}`))
+	if rec.Code != 201 {
+		t.Fatalf("Expected the resp code to be 201. Got %d", rec.Code)
+	}
+
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if post.Poll == nil || len(post.Poll.Options) != 2 {
+		t.Fatalf("Expected the post to have a poll with 2 options. Got %+v", post.Poll)
+	}
+
+	optionA, optionB := post.Poll.Options[0].ID, post.Poll.Options[1].ID
+
+	rec = request("GET", "/v1/post/"+post.ID, "iniuserid1", nil)
+	post = models.Post{}
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if !post.Poll.ResultsHidden || post.Poll.Options[0].Votes != nil {
+		t.Errorf("Expected the results to be hidden before voting. Got %+v", post.Poll)
+	}
+
+	body := []byte(fmt.Sprintf(`{"option_ids": ["%s", "%s"]}`, optionA, optionB))
+	if rec := request("POST", "/v1/post/"+post.ID+"/poll/vote", "iniuserid1", body); rec.Code != 400 {
+		t.Errorf("Expected two choices in a single choice poll to be rejected. Got %d", rec.Code)
+	}
+
+	body = []byte(fmt.Sprintf(`{"option_ids": ["%s"]}`, optionA))
+	rec = request("POST", "/v1/post/"+post.ID+"/poll/vote", "iniuserid1", body)
+	var poll models.Poll
+	json.Unmarshal(rec.Body.Bytes(), &poll)
+
+	if rec.Code != 200 || poll.ResultsHidden || *poll.Options[0].Votes != 1 || *poll.TotalVoters != 1 {
+		t.Errorf("Expected the vote to be counted and the results shown. Got %d %+v", rec.Code, poll)
+	}
+
+	if rec := request("POST", "/v1/post/"+post.ID+"/poll/vote", "iniuserid1", body); rec.Code != 409 {
+		t.Errorf("Expected a second vote to conflict. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/post/"+post.ID+"/poll/close", "iniuserid1", nil); rec.Code != 404 {
+		t.Errorf("Expected only the author to close the poll. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/post/"+post.ID+"/poll/close", "iniuserid0", nil); rec.Code != 200 {
+		t.Errorf("Expected the poll to be closed. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/post/"+post.ID+"/poll/vote", "iniuserid2", body); rec.Code != 409 {
+		t.Errorf("Expected a vote on a closed poll to be rejected. Got %d", rec.Code)
+	}
+}
+
+func TestLinkPreview(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head>
+			<meta property="og:title" content="Resep Kopi">
+			<meta property="og:description" content="Cara menyeduh kopi">
+			<meta property="og:image" content="/kopi.jpg">
+		</head><body></body></html>`)
+	}))
+	defer page.Close()
+
+	request := func(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+		app.R.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := []byte(fmt.Sprintf(`{"description": "baca %s/resep."}`, page.URL))
+	rec := request("POST", "/v1/post", "iniuserid0", body)
+	if rec.Code != 201 {
+		t.Fatalf("Expected the resp code to be 201. Got %d", rec.Code)
+	}
+
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if len(post.LinkPreviews) != 0 {
+		t.Errorf("Expected no preview before the link is fetched. Got %+v", post.LinkPreviews)
+	}
+
+	fetcher := unfurl.NewFetcher()
+	fetcher.Allow = func(ip net.IP) bool { return ip.IsLoopback() }
+	unfurler := &jobs.LinkUnfurler{DB: models.DB, Fetcher: fetcher, MaxAttempts: 1, BatchSize: 10}
+	if err := unfurler.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = request("GET", "/v1/post/"+post.ID, "iniuserid0", nil)
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if len(post.LinkPreviews) != 1 {
+		t.Fatalf("Expected 1 link preview. Got %+v", post.LinkPreviews)
+	}
+
+	lp := post.LinkPreviews[0]
+	if lp.URL != page.URL+"/resep" || lp.Title != "Resep Kopi" || lp.ImageURL == nil || *lp.ImageURL != page.URL+"/kopi.jpg" {
+		t.Errorf("Expected the preview of the linked page. Got %+v", lp)
+	}
+}
+
+func TestPostViews(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+	helper.AddPost(1, "iniuserid0")
+	// Views recorded by the other tests are not part of this one.
+	models.Views = models.NewViewCounter(time.Minute)
+
+	request := func(method, path, userid string) *httptest.ResponseRecorder {
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+		app.R.ServeHTTP(rec, req)
+		return rec
+	}
+
+	request("GET", "/v1/post/inipostid0", "iniuserid0")
+	request("GET", "/v1/post/inipostid0", "iniuserid1")
+	request("GET", "/v1/post/inipostid0", "iniuserid1")
+	request("GET", "/v1/post/inipostid0", "iniuserid2")
+
+	if err := models.Views.Flush(models.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	request("GET", "/v1/post/inipostid0", "iniuserid2")
+
+	if rec := request("GET", "/v1/post/inipostid0/views", "iniuserid1"); rec.Code != 401 {
+		t.Errorf("Expected only the author to see the views. Got %d", rec.Code)
+	}
+
+	rec := request("GET", "/v1/post/inipostid0/views?days=7", "iniuserid0")
+	var stats models.PostViewStats
+	json.Unmarshal(rec.Body.Bytes(), &stats)
+
+	if rec.Code != 200 || stats.Total != 2 || len(stats.Daily) != 7 || stats.Daily[6].Views != 2 {
+		t.Errorf("Expected 2 views today, not counting the author or repeated views. Got %d %+v", rec.Code, stats)
+	}
+}
+
+func TestContentWarning(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(3)
+	helper.SetUserRole("iniuserid2", models.RoleModerator)
+
+	request := func(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		if userid != "" {
+			var accessToken config.TokenPayload
+			if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+				log.Fatal("can't procced when creating token.")
+			}
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+		}
+		app.R.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("POST", "/v1/post", "iniuserid0", []byte(`{"description": "akhir film #spoiler", "content_warning": " spoiler "}`))
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	if rec.Code != 201 || !post.Sensitive || post.ContentWarning == nil || *post.ContentWarning != "spoiler" || post.ContentFilter != "" {
+		t.Fatalf("Expected a sensitive post shown as is to its author. Got %d %+v", rec.Code, post)
+	}
+
+	rec = request("GET", "/v1/post/"+post.ID, "iniuserid1", nil)
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if post.ContentFilter != models.SensitiveBlur {
+		t.Errorf("Expected sensitive posts to be blurred by default. Got %q", post.ContentFilter)
+	}
+
+	if rec := request("PUT", "/v1/preferences", "iniuserid1", []byte(`{"sensitive_content": "sembunyi"}`)); rec.Code != 400 {
+		t.Errorf("Expected an unknown preference to be rejected. Got %d", rec.Code)
+	}
+
+	if rec := request("PUT", "/v1/preferences", "iniuserid1", []byte(`{"sensitive_content": "hide"}`)); rec.Code != 200 {
+		t.Errorf("Expected the preferences to be saved. Got %d", rec.Code)
+	}
+
+	var m models.PayloadPosts
+	json.Unmarshal(request("GET", "/tags/spoiler/posts", "iniuserid1", nil).Body.Bytes(), &m)
+	if m.TotalData != 0 || len(m.Data) != 0 {
+		t.Errorf("Expected the sensitive post to be left out. Got %d", m.TotalData)
+	}
+
+	m = models.PayloadPosts{}
+	json.Unmarshal(request("GET", "/tags/spoiler/posts", "", nil).Body.Bytes(), &m)
+	if m.TotalData != 1 || len(m.Data) != 1 || m.Data[0].ContentFilter != models.SensitiveBlur {
+		t.Errorf("Expected the sensitive post to be blurred for anonymous viewers. Got %+v", m)
+	}
+
+	rec = request("POST", "/v1/post", "iniuserid0", []byte(`{"description": "berita hari ini"}`))
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	body := []byte(`{"content_warning": "kekerasan"}`)
+	if rec := request("POST", "/v1/post/"+post.ID+"/content-warning", "iniuserid1", body); rec.Code != 401 {
+		t.Errorf("Expected only moderators to force a warning. Got %d", rec.Code)
+	}
+
+	rec = request("POST", "/v1/post/"+post.ID+"/content-warning", "iniuserid2", body)
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if rec.Code != 200 || !post.Sensitive || !post.WarningForced {
+		t.Errorf("Expected the moderator to force the warning. Got %d %+v", rec.Code, post)
+	}
+
+	rec = request("PUT", "/v1/post/"+post.ID, "iniuserid0", []byte(`{"description": "berita hari ini", "sensitive": false}`))
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if !post.Sensitive || post.ContentWarning == nil || *post.ContentWarning != "kekerasan" {
+		t.Errorf("Expected the author not to remove a forced warning. Got %+v", post)
+	}
+
+	rec = request("DELETE", "/v1/post/"+post.ID+"/content-warning", "iniuserid2", nil)
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if rec.Code != 200 || post.Sensitive || post.WarningForced {
+		t.Errorf("Expected the moderator to lift the warning. Got %d %+v", rec.Code, post)
+	}
+}
+
+func TestLockComments(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(4)
+	helper.AddPost(1, "iniuserid0")
+	helper.SetUserRole("iniuserid3", models.RoleModerator)
+	helper.AddComment("c1", "inipostid0", "iniuserid1", nil)
+
+	request := func(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+		app.R.ServeHTTP(rec, req)
+		return rec
+	}
+
+	comment := func(userid string) int {
+		body := []byte(`{"post_id": "inipostid0", "comment_id": "c1", "content": "balasan"}`)
+		return request("POST", "/v1/comment", userid, body).Code
+	}
+
+	if rec := request("POST", "/v1/post/inipostid0/comments/lock", "iniuserid1", nil); rec.Code != 401 {
+		t.Errorf("Expected only the author or a moderator to lock comments. Got %d", rec.Code)
+	}
+
+	rec := request("POST", "/v1/post/inipostid0/comments/lock", "iniuserid0", []byte(`{"mode": "followers"}`))
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if rec.Code != 200 || !post.CommentsLocked || *post.CommentLockMode != models.CommentLockFollowers {
+		t.Fatalf("Expected comments locked to followers. Got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if code := comment("iniuserid1"); code != 403 {
+		t.Errorf("Expected a reply from a non-follower to be refused. Got %d", code)
+	}
+
+	request("POST", "/v1/user/iniuserid0/follow", "iniuserid1", nil)
+	if code := comment("iniuserid1"); code != 201 {
+		t.Errorf("Expected a follower to reply. Got %d", code)
+	}
+
+	if rec := request("POST", "/v1/post/inipostid0/comments/lock", "iniuserid3", nil); rec.Code != 200 {
+		t.Fatalf("Expected a moderator to lock comments for everyone. Got %d", rec.Code)
+	}
+
+	if code := comment("iniuserid1"); code != 403 {
+		t.Errorf("Expected a follower to be refused once locked for everyone. Got %d", code)
+	}
+
+	if code := comment("iniuserid0"); code != 201 {
+		t.Errorf("Expected the author to still comment. Got %d", code)
+	}
+
+	rec = request("GET", "/v1/post/inipostid0", "iniuserid2", nil)
+	post = models.Post{}
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if !post.CommentsLocked || !post.CommentLockForced {
+		t.Errorf("Expected the post to show a moderator's lock. Got %s", rec.Body.String())
+	}
+
+	if rec := request("POST", "/v1/post/inipostid0/comments/unlock", "iniuserid0", nil); rec.Code != 401 {
+		t.Errorf("Expected the author not to lift a moderator's lock. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/post/inipostid0/comments/unlock", "iniuserid3", nil); rec.Code != 200 {
+		t.Errorf("Expected the moderator to unlock comments. Got %d", rec.Code)
+	}
+
+	if code := comment("iniuserid2"); code != 201 {
+		t.Errorf("Expected anyone to comment once unlocked. Got %d", code)
+	}
+}
+
+func TestPostWebhookEvents(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	request := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+		app.R.ServeHTTP(rec, req)
+		return rec
+	}
+
+	events := func() []string {
+		rows, err := models.DB.Query("SELECT event FROM webhook_deliveries ORDER BY created_at, event")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		got := []string{}
+		for rows.Next() {
+			var event string
+			rows.Scan(&event)
+			got = append(got, event)
+		}
+		models.DB.Exec("DELETE FROM webhook_deliveries")
+		return got
+	}
+
+	rec := request("POST", "/v1/webhook", []byte(`{"url": "https://example.com/hooks",
+		"events": ["post.created", "post.updated", "post.deleted"]}`))
+	if rec.Code != 201 {
+		t.Fatalf("Expected the webhook to be created. Got %d", rec.Code)
+	}
+
+	rec = request("POST", "/v1/post", []byte(`{"description": "masih draft", "status": "draft"}`))
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+
+	request("PUT", "/v1/post/"+post.ID, []byte(`{"description": "masih draft juga"}`))
+	if got := events(); len(got) != 0 {
+		t.Errorf("Expected drafts not to be announced. Got %v", got)
+	}
+
+	request("PUT", "/v1/post/"+post.ID, []byte(`{"description": "sudah terbit", "status": "published"}`))
+	request("PUT", "/v1/post/"+post.ID, []byte(`{"description": "sudah terbit lagi"}`))
+	if got := events(); len(got) != 2 || got[0] != models.WebhookPostCreated || got[1] != models.WebhookPostUpdated {
+		t.Errorf("Expected the post to be created when published, then updated. Got %v", got)
+	}
+
+	models.DB.Exec(`INSERT INTO posts(id, user_id, description, status, publish_at)
+		VALUES('inipostdue', 'iniuserid0', 'sudah waktunya', 'scheduled', NOW() - INTERVAL '1 minute')`)
+	models.PublishDuePosts(models.DB)
+	if got := events(); len(got) != 1 || got[0] != models.WebhookPostCreated {
+		t.Errorf("Expected a scheduled post to be created when it goes live. Got %v", got)
+	}
+
+	request("DELETE", "/v1/post/"+post.ID, nil)
+	if got := events(); len(got) != 1 || got[0] != models.WebhookPostDeleted {
+		t.Errorf("Expected the post to be deleted. Got %v", got)
+	}
+
+	request("POST", "/v1/trash/post/"+post.ID+"/restore", nil)
+	if got := events(); len(got) != 1 || got[0] != models.WebhookPostCreated {
+		t.Errorf("Expected a restored post to be created again. Got %v", got)
+	}
+
+	request("POST", "/v1/post/"+post.ID+"/revisions/1/restore", nil)
+	if got := events(); len(got) != 1 || got[0] != models.WebhookPostUpdated {
+		t.Errorf("Expected restoring a revision to update the post. Got %v", got)
+	}
+}
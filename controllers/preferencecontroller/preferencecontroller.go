@@ -0,0 +1,56 @@
+package preferencecontroller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/helper/validation"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+)
+
+func GetPreferences(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	prefs, err := models.GetPreferences(models.DB, userInfo.Userid)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, prefs)
+}
+
+// UpdatePreferences replaces the preferences of the user.
+func UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	var prefs models.Preferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	if listErr, err := validation.Validate(&prefs); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	if err := prefs.SavePreferences(models.DB, userInfo.Userid); err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, prefs)
+}
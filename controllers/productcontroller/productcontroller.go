@@ -6,9 +6,11 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/bayudha2/go-test-0/config"
 	"github.com/bayudha2/go-test-0/helper"
 	"github.com/bayudha2/go-test-0/helper/validation"
 	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
 	"github.com/gorilla/mux"
 )
 
@@ -20,8 +22,11 @@ func GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
 	p := models.Product{ID: id}
-	if err := p.GetProduct(models.DB); err != nil {
+	if err := p.GetProduct(models.DB, userInfo.Userid); err != nil {
 		switch err {
 		case sql.ErrNoRows:
 			helper.RespondWithError(w, http.StatusNotFound, "Product not found")
@@ -23,9 +23,24 @@ func ensureTableExist() {
 	if _, err := models.DB.Exec(helper.TableProductIndexingQuery); err != nil {
 		log.Fatal(err)
 	}
+	if _, err := models.DB.Exec(helper.TableUserCreationQuery); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := models.DB.Exec(helper.TablePostCreationQuery); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := models.DB.Exec(helper.TableBookmarkCreationQuery); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := models.DB.Exec(helper.TableWebhookCreationQuery); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func clearTable() {
+	models.DB.Exec("DELETE FROM webhook_deliveries;")
+	models.DB.Exec("DELETE FROM webhooks;")
+	models.DB.Exec("DELETE FROM bookmarks;")
 	models.DB.Exec("TRUNCATE products;")
 	models.DB.Exec("DELETE FROM products;")
 }
@@ -287,3 +302,78 @@ func TestDeleteSpesificProduct(t *testing.T) {
 		t.Errorf("Expected the resp code to be 200. Got %d", rec.Code)
 	}
 }
+
+func TestProductWebhookDeliveries(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(1)
+
+	var accessToken config.TokenPayload
+	if err := accessToken.CreateToken("iniuserid0", "iniusername0", 15); err != nil {
+		log.Fatal("can't procced when creating token.")
+	}
+	var access = fmt.Sprintf("Bearer %s", accessToken.Token)
+
+	var payload = []byte(`{
+		"url": "https://example.com/hooks",
+		"events": ["product.created", "product.deleted"]
+	}`)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/webhook", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", access)
+
+	app.R.ServeHTTP(rec, req)
+
+	var webhook map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &webhook)
+
+	if rec.Code != 201 || webhook["secret"] == nil {
+		t.Fatalf("Expected the webhook to be created with a secret. Got %d %v", rec.Code, webhook)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/product", bytes.NewBuffer([]byte(`{"name": "iniproduk0", "price": 21}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", access)
+
+	app.R.ServeHTTP(rec, req)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/v1/webhook/%s/deliveries", webhook["id"]), nil)
+	req.Header.Set("Authorization", access)
+
+	app.R.ServeHTTP(rec, req)
+
+	var deliveries models.PayloadWebhookDeliveries
+	json.Unmarshal(rec.Body.Bytes(), &deliveries)
+
+	if rec.Code != 200 || len(deliveries.Data) != 1 || deliveries.Data[0].Event != models.WebhookProductCreated {
+		t.Fatalf("Expected one product.created delivery. Got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if deliveries.Data[0].Status != models.WebhookDeliveryPending {
+		t.Errorf("Expected the delivery to be pending. Got %s", deliveries.Data[0].Status)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/v1/webhook-delivery/%s/redeliver", deliveries.Data[0].ID), nil)
+	req.Header.Set("Authorization", access)
+
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 201 {
+		t.Errorf("Expected the delivery to be sent again. Got %d", rec.Code)
+	}
+
+	accessToken.CreateToken("iniuserid1", "iniusername1", 15)
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/v1/webhook/%s", webhook["id"]), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+
+	app.R.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("Expected other users not to see the webhook. Got %d", rec.Code)
+	}
+}
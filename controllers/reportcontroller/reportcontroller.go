@@ -0,0 +1,209 @@
+package reportcontroller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/helper/validation"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/gorilla/mux"
+)
+
+// CreateReport flags a post, comment or user for the admins.
+func CreateReport(w http.ResponseWriter, r *http.Request) {
+	var report models.Report
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	if listErr, err := validation.Validate(&report); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	report.ReporterID = userInfo.Userid
+	if err := report.CreateReport(models.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Reported "+report.TargetType+" not found")
+		case models.ErrReportSelf:
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case models.ErrDuplicateReport:
+			helper.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, report)
+}
+
+// GetMyReports lists the reports the user filed and their outcomes.
+func GetMyReports(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	params := models.Params{Page: r.URL.Query().Get("page"), Limit: r.URL.Query().Get("limit")}
+	reports, err := models.GetReportsByReporter(models.DB, userInfo.Userid, params)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, reports)
+}
+
+// GetReportQueue lists reports for admins, filtered by status and assignee.
+func GetReportQueue(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	params := models.Params{
+		Page:   r.URL.Query().Get("page"),
+		Limit:  r.URL.Query().Get("limit"),
+		Status: r.URL.Query().Get("status"),
+	}
+
+	switch params.Status {
+	case "", models.ReportStatusOpen, models.ReportStatusResolved, models.ReportStatusDismissed:
+	default:
+		helper.RespondWithError(w, http.StatusBadRequest, "status must be open, resolved or dismissed")
+		return
+	}
+
+	reports, err := models.GetReportQueue(models.DB, userInfo.Userid, r.URL.Query().Get("assignee_id"), params)
+	if err != nil {
+		switch err {
+		case models.ErrUnauthorized:
+			helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, reports)
+}
+
+// GetReport shows a report with its audit trail, for admins.
+func GetReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	report := models.Report{ID: id}
+	if err := report.GetReport(models.DB, userInfo.Userid); err != nil {
+		respondWithReportError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, report)
+}
+
+func AssignReport(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		AssigneeID string `json:"assignee_id" validate:"required"`
+	}
+	actOnReport(w, r, &input, func(report *models.Report, adminID string) error {
+		return report.AssignReport(models.DB, adminID, input.AssigneeID)
+	})
+}
+
+// ResolveReport closes a report, hiding the reported content or suspending
+// its author when asked to.
+func ResolveReport(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Action string `json:"action" validate:"required,oneof=none hide suspend"`
+		Note   string `json:"note" validate:"max=1000"`
+	}
+	actOnReport(w, r, &input, func(report *models.Report, adminID string) error {
+		return report.ResolveReport(models.DB, adminID, input.Action, input.Note)
+	})
+}
+
+func DismissReport(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Note string `json:"note" validate:"max=1000"`
+	}
+	actOnReport(w, r, &input, func(report *models.Report, adminID string) error {
+		return report.DismissReport(models.DB, adminID, input.Note)
+	})
+}
+
+// actOnReport decodes the body into input and runs an admin action on the
+// report in the path.
+func actOnReport(w http.ResponseWriter, r *http.Request, input interface{}, action func(*models.Report, string) error) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	// An empty body is fine for actions without required fields.
+	if err := json.NewDecoder(r.Body).Decode(input); err != nil && err != io.EOF {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	defer r.Body.Close()
+
+	if listErr, err := validation.Validate(input); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	report := models.Report{ID: id}
+	if err := action(&report, userInfo.Userid); err != nil {
+		respondWithReportError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, report)
+}
+
+func respondWithReportError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		helper.RespondWithError(w, http.StatusNotFound, "Report not found")
+	case models.ErrUnauthorized:
+		helper.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	case models.ErrReportClosed:
+		helper.RespondWithError(w, http.StatusConflict, err.Error())
+	case models.ErrReportAction, models.ErrInvalidAssignee:
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
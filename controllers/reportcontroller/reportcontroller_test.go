@@ -0,0 +1,197 @@
+package reportcontroller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/bayudha2/go-test-0/app"
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+)
+
+func ensureTableExist() {
+	if _, err := models.DB.Exec(helper.TableUserCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableSessionCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TablePostCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableCommentCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableReportCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableNotificationCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := models.DB.Exec(helper.TableWebhookCreationQuery); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+func clearTable() {
+	models.DB.Exec("DELETE FROM webhook_deliveries;")
+	models.DB.Exec("DELETE FROM webhooks;")
+	models.DB.Exec("DELETE FROM notification_preferences;")
+	models.DB.Exec("DELETE FROM notifications;")
+	models.DB.Exec("DELETE FROM report_events;")
+	models.DB.Exec("DELETE FROM reports;")
+	models.DB.Exec("DELETE FROM comments;")
+	models.DB.Exec("DELETE FROM posts;")
+	models.DB.Exec("DELETE FROM sessions;")
+	models.DB.Exec("DELETE FROM users;")
+}
+
+func TestMain(m *testing.M) {
+	models.ConnectDatabase(
+		os.Getenv("APP_DB_USERNAME"),
+		os.Getenv("APP_DB_PASSWORD"),
+		os.Getenv("APP_DB_TEST_NAME"),
+	)
+
+	app.Initialize()
+
+	ensureTableExist()
+	code := m.Run()
+	clearTable()
+
+	os.Exit(code)
+}
+
+func request(method, path, userid string, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+	if userid != "" {
+		var accessToken config.TokenPayload
+		if err := accessToken.CreateToken(userid, "iniusername", 15); err != nil {
+			log.Fatal("can't procced when creating token.")
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+	}
+	app.R.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReportWorkflow(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(4)
+	helper.AddPost(1, "iniuserid0")
+	helper.SetUserRole("iniuserid3", "admin")
+
+	body := []byte(`{"target_type": "post", "target_id": "inipostid0", "reason": "spam"}`)
+	rec := request("POST", "/v1/report", "iniuserid1", body)
+	var report models.Report
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	if rec.Code != 201 || report.Status != models.ReportStatusOpen {
+		t.Fatalf("Expected the report to be filed. Got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := request("POST", "/v1/report", "iniuserid1", body); rec.Code != 409 {
+		t.Errorf("Expected a second open report on the same post to conflict. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/report", "iniuserid0", body); rec.Code != 400 {
+		t.Errorf("Expected reporting your own post to be rejected. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/report", "iniuserid1", []byte(`{"target_type": "post", "target_id": "missing", "reason": "spam"}`)); rec.Code != 404 {
+		t.Errorf("Expected reporting a missing post to be not found. Got %d", rec.Code)
+	}
+
+	if rec := request("GET", "/v1/admin/reports", "iniuserid1", nil); rec.Code != 401 {
+		t.Errorf("Expected the queue to be for admins only. Got %d", rec.Code)
+	}
+
+	rec = request("GET", "/v1/admin/reports", "iniuserid3", nil)
+	var queue models.PayloadReports
+	json.Unmarshal(rec.Body.Bytes(), &queue)
+	if rec.Code != 200 || queue.TotalData != 1 {
+		t.Fatalf("Expected one open report in the queue. Got %d %+v", rec.Code, queue)
+	}
+
+	if rec := request("POST", "/v1/admin/report/"+report.ID+"/assign", "iniuserid3", []byte(`{"assignee_id": "iniuserid1"}`)); rec.Code != 400 {
+		t.Errorf("Expected assigning to a non-admin to be rejected. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/admin/report/"+report.ID+"/assign", "iniuserid3", []byte(`{"assignee_id": "iniuserid3"}`)); rec.Code != 200 {
+		t.Errorf("Expected the report to be assigned. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/admin/report/"+report.ID+"/resolve", "iniuserid3", []byte(`{"action": "hide", "note": "spam link"}`)); rec.Code != 200 {
+		t.Fatalf("Expected the report to be resolved. Got %d %s", rec.Code, rec.Body.String())
+	}
+
+	var deletedBy string
+	models.DB.QueryRow("SELECT COALESCE(deleted_by, '') FROM posts WHERE id = 'inipostid0' AND deleted_at IS NOT NULL").Scan(&deletedBy)
+	if deletedBy != "iniuserid3" {
+		t.Errorf("Expected the post to be removed by the admin. Got %q", deletedBy)
+	}
+
+	if rec := request("POST", "/v1/admin/report/"+report.ID+"/dismiss", "iniuserid3", nil); rec.Code != 409 {
+		t.Errorf("Expected a closed report to stay closed. Got %d", rec.Code)
+	}
+
+	rec = request("GET", "/v1/admin/report/"+report.ID, "iniuserid3", nil)
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	if len(report.Events) != 3 || report.Events[2].Event != models.ReportStatusResolved {
+		t.Errorf("Expected the created, assigned and resolved events. Got %+v", report.Events)
+	}
+
+	rec = request("GET", "/v1/reports", "iniuserid1", nil)
+	var mine models.PayloadReports
+	json.Unmarshal(rec.Body.Bytes(), &mine)
+	if mine.UnseenOutcomes != 1 || mine.Data[0].ActionTaken == nil || !*mine.Data[0].ActionTaken {
+		t.Errorf("Expected the reporter to see that action was taken. Got %s", rec.Body.String())
+	}
+
+	if mine.Data[0].ResolutionNote != nil {
+		t.Errorf("Expected the resolution note to stay with the admins.")
+	}
+
+	rec = request("GET", "/v1/reports", "iniuserid1", nil)
+	mine = models.PayloadReports{}
+	json.Unmarshal(rec.Body.Bytes(), &mine)
+	if mine.UnseenOutcomes != 0 {
+		t.Errorf("Expected the outcome to be marked as seen. Got %d", mine.UnseenOutcomes)
+	}
+}
+
+func TestReportSuspendsUser(t *testing.T) {
+	defer clearTable()
+	helper.AddUsers(4)
+	helper.SetUserRole("iniuserid3", "admin")
+
+	rec := request("POST", "/v1/report", "iniuserid2", []byte(`{"target_type": "user", "target_id": "iniuserid0", "reason": "impersonation"}`))
+	var report models.Report
+	json.Unmarshal(rec.Body.Bytes(), &report)
+
+	if rec := request("POST", "/v1/admin/report/"+report.ID+"/resolve", "iniuserid3", []byte(`{"action": "hide"}`)); rec.Code != 400 {
+		t.Errorf("Expected hiding a user to be rejected. Got %d", rec.Code)
+	}
+
+	if rec := request("POST", "/v1/admin/report/"+report.ID+"/resolve", "iniuserid3", []byte(`{"action": "suspend"}`)); rec.Code != 200 {
+		t.Fatalf("Expected the user to be suspended. Got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = request("POST", "/signin", "", []byte(`{"username": "iniusername0", "password": "inipassword0"}`))
+	if rec.Code != 403 {
+		t.Errorf("Expected a suspended user not to sign in. Got %d", rec.Code)
+	}
+}
@@ -0,0 +1,277 @@
+package streamcontroller
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/realtime"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is how long a write to a client may take.
+	writeWait = 10 * time.Second
+	// Clients are pinged every pingPeriod and dropped when no pong or
+	// other message arrives within pongWait.
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize caps what a client may send in one message.
+	maxMessageSize = 4096
+	// maxSubscriptions caps the posts one connection follows.
+	maxSubscriptions = 50
+)
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// command is what clients send: {"action": "subscribe", "post_ids": [...]}
+// or the same with "unsubscribe".
+type command struct {
+	Action  string   `json:"action"`
+	PostIDs []string `json:"post_ids"`
+}
+
+// reply answers a command.
+type reply struct {
+	Type    string   `json:"type"`
+	PostIDs []string `json:"post_ids,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// StreamComments upgrades to a WebSocket that sends the comment events of
+// the posts subscribed to, either with post_id in the query or with
+// subscribe commands. Browsers, which cannot set the Authorization header
+// on WebSockets, may pass the access token as access_token instead.
+func StreamComments(w http.ResponseWriter, r *http.Request) {
+	if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	viewerID := utils.ViewerID(r)
+	if viewerID == "" {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Not Authorized!")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded.
+		return
+	}
+
+	defer conn.Close()
+
+	sub := realtime.Comments.Subscribe()
+	defer sub.Close()
+
+	replies := make(chan reply, 8)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	send := func(msg reply) bool {
+		select {
+		case replies <- msg:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if ids := r.URL.Query()["post_id"]; len(ids) > 0 {
+			if !send(subscribe(sub, viewerID, ids)) {
+				return
+			}
+		}
+
+		conn.SetReadLimit(maxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		for {
+			var cmd command
+			if err := conn.ReadJSON(&cmd); err != nil {
+				if _, ok := err.(*websocket.CloseError); !ok {
+					send(reply{Type: "error", Message: "Invalid message"})
+				}
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(pongWait))
+
+			var msg reply
+			switch cmd.Action {
+			case "subscribe":
+				msg = subscribe(sub, viewerID, cmd.PostIDs)
+			case "unsubscribe":
+				sub.Remove(cmd.PostIDs...)
+				msg = reply{Type: "unsubscribed", PostIDs: cmd.PostIDs}
+			default:
+				msg = reply{Type: "error", Message: "action must be subscribe or unsubscribe"}
+			}
+
+			if !send(msg) {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.C():
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// Dropped by the hub for not keeping up.
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "Client is too slow"))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case msg := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-r.Context().Done():
+			// The server is shutting down.
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server is shutting down"))
+			return
+		}
+	}
+}
+
+// subscribe adds the posts the viewer can see, up to maxSubscriptions.
+func subscribe(sub *realtime.Subscriber, viewerID string, postIDs []string) reply {
+	if len(postIDs) == 0 {
+		return reply{Type: "error", Message: "post_ids is required"}
+	}
+
+	if sub.Topics()+len(postIDs) > maxSubscriptions {
+		return reply{Type: "error", Message: "Too many subscriptions"}
+	}
+
+	for _, id := range postIDs {
+		post := models.Post{ID: id}
+		if err := post.GetPost(models.DB, viewerID); err != nil {
+			if err == sql.ErrNoRows {
+				return reply{Type: "error", PostIDs: []string{id}, Message: "Post not found"}
+			}
+			return reply{Type: "error", Message: err.Error()}
+		}
+	}
+
+	sub.Add(postIDs...)
+	return reply{Type: "subscribed", PostIDs: postIDs}
+}
+
+// keepAlive is how often an idle event stream gets a comment, so proxies
+// do not time it out.
+const keepAlive = 15 * time.Second
+
+// StreamEvents sends the user's activity as Server-Sent Events: "comment"
+// for new comments on their posts and "reply" for replies to their
+// comments. Clients resuming with Last-Event-ID get what they missed, or a
+// "reset" event when it is no longer kept and they should reload instead.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	var lastID int64
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		// For clients that cannot set headers, like EventSource polyfills.
+		last = r.URL.Query().Get("last_event_id")
+	}
+	if last != "" {
+		id, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || id < 0 {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		lastID = id
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	// A client without a last event starts fresh, with nothing to catch up
+	// on.
+	var sub *realtime.Subscriber
+	var missed []realtime.Entry
+	complete := true
+	if last == "" {
+		sub = realtime.Activity.Subscribe(userInfo.Userid)
+	} else {
+		sub, missed, complete = realtime.Activity.Resume(userInfo.Userid, lastID)
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Keeps nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range missed {
+		w.Write(e.Data)
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.C():
+			if !ok {
+				// Dropped by the stream for not keeping up; the client
+				// reconnects and resumes from its last event.
+				return
+			}
+			if _, err := w.Write(msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			// The client went away or the server is shutting down.
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package tagcontroller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/gorilla/mux"
+)
+
+const maxTrendingWindow = 7 * 24 * time.Hour
+
+func GetPostsByTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tag := vars["tag"]
+	if tag == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid tag")
+		return
+	}
+
+	var params = models.Params{
+		Page:  r.URL.Query().Get("page"),
+		Limit: r.URL.Query().Get("limit"),
+	}
+
+	posts, err := models.GetPostsByTag(models.DB, tag, utils.ViewerID(r), params)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, posts)
+}
+
+func GetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid window")
+			return
+		}
+		window = parsed
+	}
+
+	halfLife := window / 4
+	if value := r.URL.Query().Get("half_life"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			helper.RespondWithError(w, http.StatusBadRequest, "Invalid half_life")
+			return
+		}
+		halfLife = parsed
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	tags, err := models.GetTrendingTags(models.DB, window, halfLife, limit)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": tags})
+}
@@ -0,0 +1,76 @@
+package trashcontroller
+
+import (
+	"net/http"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/gorilla/mux"
+)
+
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	trash, err := models.GetTrash(models.DB, userInfo.Userid)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, trash)
+}
+
+func RestorePost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	post := models.Post{ID: id, UserId: userInfo.Userid}
+	if err := post.RestorePost(models.DB); err != nil {
+		switch err {
+		case models.ErrNotInTrash:
+			helper.RespondWithError(w, http.StatusNotFound, err.Error())
+		case models.ErrAlreadyReposted:
+			helper.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, post)
+}
+
+func RestoreComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Id")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	comment := models.Comment{ID: id, UserId: userInfo.Userid}
+	if err := comment.RestoreComment(models.DB); err != nil {
+		switch err {
+		case models.ErrNotInTrash:
+			helper.RespondWithError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, comment)
+}
@@ -0,0 +1,189 @@
+package webhookcontroller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/helper"
+	"github.com/bayudha2/go-test-0/helper/validation"
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/utils"
+	"github.com/gorilla/mux"
+)
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	webhooks, err := models.GetWebhooks(models.DB, userInfo.Userid)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": webhooks})
+}
+
+// CreateWebhook subscribes a URL to events. The response carries the
+// secret deliveries are signed with, which is not shown again.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhook models.Webhook
+	if !decodeWebhook(w, r, &webhook) {
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	webhook.UserId = userInfo.Userid
+	if err := webhook.CreateWebhook(models.DB); err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, webhook)
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	webhook := models.Webhook{ID: id, UserId: userInfo.Userid}
+	if err := webhook.GetWebhook(models.DB); err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, webhook)
+}
+
+// UpdateWebhook replaces the URL, events and active state of the webhook,
+// and its secret when one is given.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	webhook := models.Webhook{Active: true}
+	if !decodeWebhook(w, r, &webhook) {
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	webhook.ID = id
+	webhook.UserId = userInfo.Userid
+	if err := webhook.UpdateWebhook(models.DB); err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, webhook)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	webhook := models.Webhook{ID: id, UserId: userInfo.Userid}
+	if err := webhook.DeleteWebhook(models.DB); err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+// GetDeliveries lists the deliveries of the webhook, newest first.
+func GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	params := models.Params{Page: r.URL.Query().Get("page"), Limit: r.URL.Query().Get("limit")}
+	deliveries, err := models.GetWebhookDeliveries(models.DB, userInfo.Userid, id, params)
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// Redeliver sends the event of a delivery again, as a new delivery.
+func Redeliver(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	var userInfo config.JWTClaim
+	utils.ParseToken(&userInfo, r)
+
+	delivery := models.WebhookDelivery{ID: id}
+	if err := delivery.RedeliverWebhookDelivery(models.DB, userInfo.Userid); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			helper.RespondWithError(w, http.StatusNotFound, "Delivery not found")
+		default:
+			helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, delivery)
+}
+
+func decodeWebhook(w http.ResponseWriter, r *http.Request, webhook *models.Webhook) bool {
+	if r.Body == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(webhook); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return false
+	}
+
+	defer r.Body.Close()
+
+	if listErr, err := validation.Validate(webhook); err != nil {
+		helper.RespondWithMultiError(w, http.StatusBadRequest, listErr)
+		return false
+	}
+	return true
+}
+
+func respondWithWebhookError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		helper.RespondWithError(w, http.StatusNotFound, "Webhook not found")
+	case models.ErrWebhookEvent:
+		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
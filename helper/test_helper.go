@@ -16,7 +16,10 @@ const TableUserCreationQuery = `
 		"username" varchar(50) UNIQUE NOT NULL,
 		"password" varchar(255) NOT NULL,
 		"email" varchar(255) NOT NULL,
+		"role" varchar(10) NOT NULL DEFAULT 'user',
 		"created_at" timestamptz NOT NULL DEFAULT now(),
+		"suspended_at" timestamptz,
+		"suspended_by" varchar(36),
 		PRIMARY KEY ("id")
 );`
 
@@ -51,9 +54,420 @@ const TablePostCreationQuery = `
 		"id" varchar(36) UNIQUE NOT NULL,
 		"user_id" varchar(36) NOT NULL,
 		"description" text NOT NULL,
+		"search_config" regconfig NOT NULL DEFAULT 'simple',
+		"search_vector" tsvector GENERATED ALWAYS AS (to_tsvector("search_config", "description")) STORED,
+		"status" varchar(10) NOT NULL DEFAULT 'published' CHECK ("status" IN ('draft', 'scheduled', 'published')),
+		"publish_at" timestamptz DEFAULT NOW(),
+		"edit_count" integer NOT NULL DEFAULT 0,
+		"kind" varchar(10) NOT NULL DEFAULT 'post' CHECK ("kind" IN ('post', 'repost', 'quote')),
+		"repost_of_id" varchar(36),
+		"sensitive" boolean NOT NULL DEFAULT false,
+		"content_warning" varchar(100),
+		"warning_forced_by" varchar(36),
+		"comment_approval" boolean NOT NULL DEFAULT false,
+		"comments_locked" varchar(10) CHECK ("comments_locked" IN ('everyone', 'followers')),
+		"comments_locked_by" varchar(36),
 		"created_at" timestamptz NOT NULL DEFAULT NOW(),
 		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		"deleted_at" timestamptz,
+		"deleted_by" varchar(36),
 		CONSTRAINT "posts_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id"),
+		CONSTRAINT "posts_warning_forced_by_fkey" FOREIGN KEY ("warning_forced_by") REFERENCES "public"."users"("id") ON DELETE SET NULL,
+		CONSTRAINT "posts_repost_of_id_fkey" FOREIGN KEY ("repost_of_id") REFERENCES "public"."posts"("id") ON DELETE SET NULL,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS posts_user_repost_idx ON "public"."posts" ("user_id", "repost_of_id")
+		WHERE "kind" = 'repost' AND "deleted_at" IS NULL;
+`
+
+const TablePostIndexingQuery = `
+	CREATE INDEX IF NOT EXISTS posts_search_vector_idx ON "public"."posts" USING GIN ("search_vector")
+`
+
+const TablePostRevisionCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."post_revisions" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"post_id" varchar(36) NOT NULL,
+		"revision" integer NOT NULL,
+		"description" text NOT NULL,
+		"editor_id" varchar(36) NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "post_revisions_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		CONSTRAINT "post_revisions_editor_id_fkey" FOREIGN KEY ("editor_id") REFERENCES "public"."users"("id"),
+		UNIQUE ("post_id", "revision"),
+		PRIMARY KEY ("id")
+	);
+`
+
+const TableCommentCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."comments" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"post_id" varchar(36) NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"content" text NOT NULL,
+		"parent_id" varchar(36),
+		"status" varchar(10) NOT NULL DEFAULT 'visible'
+			CONSTRAINT "comments_status_check" CHECK ("status" IN ('visible', 'pending', 'hidden')),
+		"moderated_by" varchar(36),
+		"moderated_at" timestamptz,
+		"deleted_by" varchar(36),
+		"edit_count" integer NOT NULL DEFAULT 0,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		"deleted_at" timestamptz,
+		CONSTRAINT "comments_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id"),
+		CONSTRAINT "comments_parent_id_fkey" FOREIGN KEY ("parent_id") REFERENCES "public"."comments"("id"),
+		CONSTRAINT "comments_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id"),
+		CONSTRAINT "comments_moderated_by_fkey" FOREIGN KEY ("moderated_by") REFERENCES "public"."users"("id") ON DELETE SET NULL,
+		CONSTRAINT "comments_deleted_by_fkey" FOREIGN KEY ("deleted_by") REFERENCES "public"."users"("id") ON DELETE SET NULL,
+		PRIMARY KEY ("id")
+	);
+`
+
+const TableCommentRevisionCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."comment_revisions" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"comment_id" varchar(36) NOT NULL,
+		"revision" integer NOT NULL,
+		"content" text NOT NULL,
+		"editor_id" varchar(36) NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "comment_revisions_comment_id_fkey" FOREIGN KEY ("comment_id") REFERENCES "public"."comments"("id") ON DELETE CASCADE,
+		CONSTRAINT "comment_revisions_editor_id_fkey" FOREIGN KEY ("editor_id") REFERENCES "public"."users"("id"),
+		UNIQUE ("comment_id", "revision"),
+		PRIMARY KEY ("id")
+	);
+`
+
+const TableTagCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."tags" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"name" varchar(50) UNIQUE NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		PRIMARY KEY ("id")
+	);
+
+	CREATE TABLE IF NOT EXISTS "public"."post_tags" (
+		"post_id" varchar(36) NOT NULL,
+		"tag_id" varchar(36) NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "post_tags_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		CONSTRAINT "post_tags_tag_id_fkey" FOREIGN KEY ("tag_id") REFERENCES "public"."tags"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("post_id", "tag_id")
+	);
+
+	CREATE TABLE IF NOT EXISTS "public"."comment_tags" (
+		"comment_id" varchar(36) NOT NULL,
+		"tag_id" varchar(36) NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "comment_tags_comment_id_fkey" FOREIGN KEY ("comment_id") REFERENCES "public"."comments"("id") ON DELETE CASCADE,
+		CONSTRAINT "comment_tags_tag_id_fkey" FOREIGN KEY ("tag_id") REFERENCES "public"."tags"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("comment_id", "tag_id")
+	);
+`
+
+const TableMentionCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."mentions" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"author_id" varchar(36) NOT NULL,
+		"post_id" varchar(36) NOT NULL,
+		"comment_id" varchar(36),
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "mentions_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		CONSTRAINT "mentions_author_id_fkey" FOREIGN KEY ("author_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		CONSTRAINT "mentions_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		CONSTRAINT "mentions_comment_id_fkey" FOREIGN KEY ("comment_id") REFERENCES "public"."comments"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS mentions_post_user_idx ON "public"."mentions" ("post_id", "user_id") WHERE "comment_id" IS NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS mentions_comment_user_idx ON "public"."mentions" ("comment_id", "user_id");
+`
+
+const TableAttachmentCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."attachments" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"post_id" varchar(36),
+		"blob_key" varchar(255) UNIQUE NOT NULL,
+		"content_type" varchar(100) NOT NULL,
+		"size" bigint NOT NULL,
+		"alt_text" text NOT NULL DEFAULT '',
+		"width" integer,
+		"height" integer,
+		"blurhash" varchar(64),
+		"processing_status" varchar(20) NOT NULL DEFAULT 'skipped',
+		"processing_attempts" integer NOT NULL DEFAULT 0,
+		"processing_error" text,
+		"next_attempt_at" timestamptz NOT NULL DEFAULT NOW(),
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "attachments_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id"),
+		CONSTRAINT "attachments_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE SET NULL,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE TABLE IF NOT EXISTS "public"."attachment_variants" (
+		"attachment_id" varchar(36) NOT NULL,
+		"name" varchar(50) NOT NULL,
+		"blob_key" varchar(255) UNIQUE NOT NULL,
+		"content_type" varchar(100) NOT NULL,
+		"width" integer NOT NULL,
+		"height" integer NOT NULL,
+		"size" bigint NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "attachment_variants_attachment_id_fkey" FOREIGN KEY ("attachment_id") REFERENCES "public"."attachments"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("attachment_id", "name")
+	);
+`
+
+const TableBookmarkCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."bookmark_collections" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"name" varchar(100) NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "bookmark_collections_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS bookmark_collections_user_id_name_idx ON "public"."bookmark_collections" ("user_id", lower("name"));
+
+	CREATE TABLE IF NOT EXISTS "public"."bookmarks" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"collection_id" varchar(36) NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"post_id" varchar(36),
+		"product_id" varchar(36),
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "bookmarks_item_check" CHECK (("post_id" IS NULL) <> ("product_id" IS NULL)),
+		CONSTRAINT "bookmarks_collection_id_fkey" FOREIGN KEY ("collection_id") REFERENCES "public"."bookmark_collections"("id") ON DELETE CASCADE,
+		CONSTRAINT "bookmarks_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		CONSTRAINT "bookmarks_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		CONSTRAINT "bookmarks_product_id_fkey" FOREIGN KEY ("product_id") REFERENCES "public"."products"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS bookmarks_collection_post_idx ON "public"."bookmarks" ("collection_id", "post_id") WHERE "post_id" IS NOT NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS bookmarks_collection_product_idx ON "public"."bookmarks" ("collection_id", "product_id") WHERE "product_id" IS NOT NULL;
+`
+
+const TablePollCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."polls" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"post_id" varchar(36) UNIQUE NOT NULL,
+		"multiple" boolean NOT NULL DEFAULT false,
+		"hide_results" boolean NOT NULL DEFAULT false,
+		"closes_at" timestamptz,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "polls_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE TABLE IF NOT EXISTS "public"."poll_options" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"poll_id" varchar(36) NOT NULL,
+		"position" integer NOT NULL,
+		"label" varchar(100) NOT NULL,
+		CONSTRAINT "poll_options_poll_id_fkey" FOREIGN KEY ("poll_id") REFERENCES "public"."polls"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS poll_options_poll_id_position_idx ON "public"."poll_options" ("poll_id", "position");
+
+	CREATE TABLE IF NOT EXISTS "public"."poll_voters" (
+		"poll_id" varchar(36) NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "poll_voters_poll_id_fkey" FOREIGN KEY ("poll_id") REFERENCES "public"."polls"("id") ON DELETE CASCADE,
+		CONSTRAINT "poll_voters_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("poll_id", "user_id")
+	);
+
+	CREATE TABLE IF NOT EXISTS "public"."poll_votes" (
+		"poll_id" varchar(36) NOT NULL,
+		"option_id" varchar(36) NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		CONSTRAINT "poll_votes_voter_fkey" FOREIGN KEY ("poll_id", "user_id") REFERENCES "public"."poll_voters"("poll_id", "user_id") ON DELETE CASCADE,
+		CONSTRAINT "poll_votes_option_id_fkey" FOREIGN KEY ("option_id") REFERENCES "public"."poll_options"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("option_id", "user_id")
+	);
+`
+
+const TableLinkPreviewCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."link_previews" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"url" varchar(2048) UNIQUE NOT NULL,
+		"final_url" varchar(2048),
+		"title" varchar(300) NOT NULL DEFAULT '',
+		"description" varchar(1000) NOT NULL DEFAULT '',
+		"image_url" varchar(2048),
+		"site_name" varchar(300) NOT NULL DEFAULT '',
+		"status" varchar(16) NOT NULL DEFAULT 'pending',
+		"attempts" integer NOT NULL DEFAULT 0,
+		"error" text,
+		"next_attempt_at" timestamptz NOT NULL DEFAULT NOW(),
+		"fetched_at" timestamptz,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "link_previews_status_check" CHECK ("status" IN ('pending', 'processing', 'done', 'failed')),
+		PRIMARY KEY ("id")
+	);
+
+	CREATE TABLE IF NOT EXISTS "public"."post_links" (
+		"post_id" varchar(36) NOT NULL,
+		"preview_id" varchar(36) NOT NULL,
+		"position" integer NOT NULL,
+		CONSTRAINT "post_links_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		CONSTRAINT "post_links_preview_id_fkey" FOREIGN KEY ("preview_id") REFERENCES "public"."link_previews"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("post_id", "preview_id")
+	);
+`
+
+const TablePostViewCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."post_views" (
+		"post_id" varchar(36) NOT NULL,
+		"day" date NOT NULL,
+		"views" bigint NOT NULL DEFAULT 0,
+		CONSTRAINT "post_views_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("post_id", "day")
+	);
+`
+
+const TablePreferenceCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."user_preferences" (
+		"user_id" varchar(36) NOT NULL,
+		"sensitive_content" varchar(8) NOT NULL DEFAULT 'blur'
+			CONSTRAINT "user_preferences_sensitive_content_check" CHECK ("sensitive_content" IN ('show', 'blur', 'hide')),
+		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "user_preferences_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("user_id")
+	);
+`
+
+const TableReportCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."reports" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"reporter_id" varchar(36) NOT NULL,
+		"target_type" varchar(10) NOT NULL
+			CONSTRAINT "reports_target_type_check" CHECK ("target_type" IN ('post', 'comment', 'user')),
+		"target_id" varchar(36) NOT NULL,
+		"reason" varchar(20) NOT NULL
+			CONSTRAINT "reports_reason_check" CHECK ("reason" IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'impersonation', 'other')),
+		"details" text NOT NULL DEFAULT '',
+		"status" varchar(10) NOT NULL DEFAULT 'open'
+			CONSTRAINT "reports_status_check" CHECK ("status" IN ('open', 'resolved', 'dismissed')),
+		"assignee_id" varchar(36),
+		"action" varchar(10)
+			CONSTRAINT "reports_action_check" CHECK ("action" IN ('none', 'hide', 'suspend')),
+		"resolution_note" text,
+		"closed_by" varchar(36),
+		"closed_at" timestamptz,
+		"outcome_seen_at" timestamptz,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "reports_reporter_id_fkey" FOREIGN KEY ("reporter_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS "reports_open_reporter_target_idx" ON "public"."reports" ("reporter_id", "target_type", "target_id") WHERE "status" = 'open';
+
+	CREATE TABLE IF NOT EXISTS "public"."report_events" (
+		"id" bigserial NOT NULL,
+		"report_id" varchar(36) NOT NULL,
+		"actor_id" varchar(36),
+		"event" varchar(10) NOT NULL
+			CONSTRAINT "report_events_event_check" CHECK ("event" IN ('created', 'assigned', 'resolved', 'dismissed')),
+		"detail" text NOT NULL DEFAULT '',
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "report_events_report_id_fkey" FOREIGN KEY ("report_id") REFERENCES "public"."reports"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+`
+
+const TableFollowCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."user_follows" (
+		"follower_id" varchar(36) NOT NULL,
+		"followee_id" varchar(36) NOT NULL,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "user_follows_not_self_check" CHECK ("follower_id" <> "followee_id"),
+		CONSTRAINT "user_follows_follower_id_fkey" FOREIGN KEY ("follower_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		CONSTRAINT "user_follows_followee_id_fkey" FOREIGN KEY ("followee_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("follower_id", "followee_id")
+	);
+`
+
+const TableNotificationCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."notifications" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"type" varchar(10) NOT NULL
+			CONSTRAINT "notifications_type_check" CHECK ("type" IN ('comment', 'reply', 'mention', 'follow', 'repost', 'report')),
+		"group_key" varchar(60) NOT NULL,
+		"post_id" varchar(36),
+		"comment_id" varchar(36),
+		"report_id" varchar(36),
+		"actor_ids" varchar(36)[] NOT NULL DEFAULT '{}',
+		"event_count" integer NOT NULL DEFAULT 1,
+		"read_at" timestamptz,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "notifications_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		CONSTRAINT "notifications_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS "notifications_unread_group_idx" ON "public"."notifications" ("user_id", "group_key") WHERE "read_at" IS NULL;
+
+	CREATE TABLE IF NOT EXISTS "public"."notification_preferences" (
+		"user_id" varchar(36) NOT NULL,
+		"type" varchar(10) NOT NULL
+			CONSTRAINT "notification_preferences_type_check" CHECK ("type" IN ('comment', 'reply', 'mention', 'follow', 'repost', 'report')),
+		"enabled" boolean NOT NULL,
+		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "notification_preferences_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("user_id", "type")
+	);
+
+	CREATE SEQUENCE IF NOT EXISTS "public"."activity_events_id_seq";
+`
+
+const TableWebhookCreationQuery = `
+	CREATE TABLE IF NOT EXISTS "public"."webhooks" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"user_id" varchar(36) NOT NULL,
+		"url" varchar(2048) NOT NULL,
+		"secret" varchar(64) NOT NULL,
+		"events" varchar(40)[] NOT NULL
+			CONSTRAINT "webhooks_events_check" CHECK (cardinality("events") > 0 AND "events" <@ ARRAY['post.created', 'post.updated', 'post.deleted', 'comment.created', 'comment.updated', 'comment.deleted', 'product.created', 'product.updated', 'product.deleted']::varchar[]),
+		"active" boolean NOT NULL DEFAULT true,
+		"failure_count" integer NOT NULL DEFAULT 0,
+		"disabled_at" timestamptz,
+		"disabled_reason" text,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		"updated_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "webhooks_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE,
+		PRIMARY KEY ("id")
+	);
+
+	CREATE TABLE IF NOT EXISTS "public"."webhook_deliveries" (
+		"id" varchar(36) UNIQUE NOT NULL,
+		"webhook_id" varchar(36) NOT NULL,
+		"event_id" varchar(36) NOT NULL,
+		"event" varchar(40) NOT NULL,
+		"payload" text NOT NULL,
+		"status" varchar(16) NOT NULL DEFAULT 'pending'
+			CONSTRAINT "webhook_deliveries_status_check" CHECK ("status" IN ('pending', 'processing', 'succeeded', 'failed')),
+		"attempts" integer NOT NULL DEFAULT 0,
+		"next_attempt_at" timestamptz NOT NULL DEFAULT NOW(),
+		"response_status" integer,
+		"error" text,
+		"redelivery_of" varchar(36),
+		"delivered_at" timestamptz,
+		"created_at" timestamptz NOT NULL DEFAULT NOW(),
+		CONSTRAINT "webhook_deliveries_webhook_id_fkey" FOREIGN KEY ("webhook_id") REFERENCES "public"."webhooks"("id") ON DELETE CASCADE,
+		CONSTRAINT "webhook_deliveries_redelivery_of_fkey" FOREIGN KEY ("redelivery_of") REFERENCES "public"."webhook_deliveries"("id") ON DELETE SET NULL,
 		PRIMARY KEY ("id")
 	);
 `
@@ -93,6 +507,23 @@ func AddPost(count int, userid string) {
 	}
 }
 
+func SetUserRole(userid string, role string) {
+	models.DB.Exec("UPDATE users SET role=$1 WHERE id=$2", role, userid)
+}
+
+func AddComment(id string, postid string, userid string, parentid *string) {
+	models.DB.Exec(`INSERT INTO comments(id, post_id, user_id, content, parent_id, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7)`,
+		id,
+		postid,
+		userid,
+		"ini komentar "+id,
+		parentid,
+		time.Now(),
+		time.Now(),
+	)
+}
+
 func AddProducts(count int) string {
 	if count < 1 {
 		count = 1
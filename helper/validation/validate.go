@@ -14,7 +14,7 @@ func Validate(p interface{}) ([]string, error) {
 		if errsObject, ok := err.(validator.ValidationErrors); ok {
 			for _, err := range errsObject {
 				switch err.Tag() {
-				case "required":
+				case "required", "required_without":
 					errors = append(errors, fmt.Sprintf("%s is required", err.Field()))
 				case "min":
 					errors = append(errors, fmt.Sprintf("%s value must greater than %s", err.Field(), err.Param()))
@@ -22,6 +22,8 @@ func Validate(p interface{}) ([]string, error) {
 					errors = append(errors, fmt.Sprintf("%s value must less than %s", err.Field(), err.Param()))
 				case "email":
 					errors = append(errors, fmt.Sprintf("%s must be a email format", err.Field()))
+				case "oneof":
+					errors = append(errors, fmt.Sprintf("%s must be one of %s", err.Field(), err.Param()))
 				}
 			}
 		}
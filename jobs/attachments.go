@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/storage"
+)
+
+// CollectOrphanedAttachments deletes uploads that were not attached to a post
+// within ttl, along with the uploads of purged posts.
+func CollectOrphanedAttachments(db *sql.DB, store storage.BlobStore, ttl time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		keys, err := models.ClaimOrphanedAttachments(db, time.Now().Add(-ttl), 100)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := store.Delete(ctx, key); err != nil && err != storage.ErrNotFound {
+				log.Printf("deleting orphaned blob %s: %v", key, err)
+			}
+		}
+
+		if len(keys) > 0 {
+			log.Printf("removed %d orphaned attachments", len(keys))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/storage"
+	"github.com/bayudha2/go-test-0/utils/imaging"
+)
+
+// ImagePipeline renders the variants of uploaded images.
+type ImagePipeline struct {
+	DB       *sql.DB
+	Store    storage.BlobStore
+	Variants []imaging.Variant
+	// MaxAttempts is how often an image is tried before it is marked
+	// failed. Retries wait Backoff, doubled after every attempt.
+	MaxAttempts int
+	Backoff     time.Duration
+	// BatchSize is the number of images claimed per run.
+	BatchSize int
+}
+
+// Run processes one batch of pending images.
+func (p *ImagePipeline) Run(ctx context.Context) error {
+	images, err := models.ClaimImages(p.DB, p.BatchSize, p.MaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	for i := range images {
+		if ctx.Err() != nil {
+			// The lease expires and another run picks the rest up.
+			return nil
+		}
+
+		image := &images[i]
+		if err := p.process(ctx, image); err != nil {
+			log.Printf("processing attachment %s: %v", image.ID, err)
+			maxAttempts := p.MaxAttempts
+			if errors.Is(err, imaging.ErrImageTooLarge) {
+				// Retrying would fail the same way.
+				maxAttempts = 0
+			}
+			if err := image.FailProcessing(p.DB, err.Error(), maxAttempts, p.Backoff); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *ImagePipeline) process(ctx context.Context, image *models.Attachment) error {
+	object, _, err := p.Store.Open(ctx, image.BlobKey)
+	if err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	_, err = io.Copy(&data, object)
+	object.Close()
+	if err != nil {
+		return err
+	}
+
+	result, err := imaging.Process(data.Bytes(), p.Variants)
+	if err != nil {
+		return err
+	}
+
+	variants := map[string]models.AttachmentVariant{}
+	for _, out := range result.Outputs {
+		key := "variants/" + image.ID + "/" + out.Name
+		if err := p.Store.Put(ctx, key, bytes.NewReader(out.Data), int64(len(out.Data)), out.ContentType); err != nil {
+			return err
+		}
+
+		variants[out.Name] = models.AttachmentVariant{
+			BlobKey:     key,
+			ContentType: out.ContentType,
+			Width:       out.Width,
+			Height:      out.Height,
+			Size:        int64(len(out.Data)),
+		}
+	}
+
+	err = image.SaveVariants(p.DB, result.Width, result.Height, result.Blurhash, variants)
+	if err == sql.ErrNoRows {
+		// Deleted while we were working on it.
+		for _, v := range variants {
+			if err := p.Store.Delete(ctx, v.BlobKey); err != nil && err != storage.ErrNotFound {
+				log.Printf("deleting blob %s: %v", v.BlobKey, err)
+			}
+		}
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+// Package jobs holds the background work that runs next to the HTTP server.
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Runner starts jobs and waits for them to finish once their context is done.
+type Runner struct {
+	wg sync.WaitGroup
+}
+
+// Every calls fn immediately and then once per interval until ctx is done.
+// Errors are logged and the job keeps going.
+func (r *Runner) Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("job %s: %s", name, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until every started job has returned.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunnerEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls int32
+	var runner Runner
+	runner.Every(ctx, "test", 5*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("keeps running after errors")
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	runner.Wait()
+
+	if n := atomic.LoadInt32(&calls); n < 2 {
+		t.Errorf("Expected the job to run more than once. Got %d", n)
+	}
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/unfurl"
+)
+
+// LinkUnfurler fetches the previews of links in posts.
+type LinkUnfurler struct {
+	DB      *sql.DB
+	Fetcher *unfurl.Fetcher
+	// MaxAttempts is how often a link is tried before it is marked
+	// failed. Retries wait Backoff, doubled after every attempt.
+	MaxAttempts int
+	Backoff     time.Duration
+	// BatchSize is the number of links claimed per run.
+	BatchSize int
+}
+
+// Run fetches one batch of queued links.
+func (u *LinkUnfurler) Run(ctx context.Context) error {
+	previews, err := models.ClaimLinkPreviews(u.DB, u.BatchSize, u.MaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	for i := range previews {
+		if ctx.Err() != nil {
+			// The lease expires and another run picks the rest up.
+			return nil
+		}
+
+		lp := &previews[i]
+		p, err := u.Fetcher.Fetch(ctx, lp.URL)
+		if err != nil {
+			log.Printf("unfurling link %s: %v", lp.ID, err)
+			if err := lp.FailLinkPreview(u.DB, err.Error(), u.MaxAttempts, u.Backoff); err != nil {
+				return err
+			}
+			continue
+		}
+
+		lp.FinalURL = &p.URL
+		lp.Title = p.Title
+		lp.Description = p.Description
+		lp.SiteName = p.SiteName
+		if p.ImageURL != "" {
+			lp.ImageURL = &p.ImageURL
+		}
+
+		if err := lp.SaveLinkPreview(u.DB); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
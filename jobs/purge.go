@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/bayudha2/go-test-0/models"
+)
+
+// PurgeTrash removes deleted posts and comments past their retention window.
+func PurgeTrash(db *sql.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		n, err := models.PurgeTrash(db)
+		if err != nil {
+			return err
+		}
+
+		if n > 0 {
+			log.Printf("purged %d rows from trash", n)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/bayudha2/go-test-0/models"
+)
+
+// PublishScheduledPosts publishes the scheduled posts that are due.
+func PublishScheduledPosts(db *sql.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ids, err := models.PublishDuePosts(db)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			log.Printf("published %d scheduled posts", len(ids))
+		}
+		return nil
+	}
+}
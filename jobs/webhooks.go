@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/unfurl"
+)
+
+// Headers sent with every webhook delivery. The signature is the hex
+// HMAC-SHA256, keyed with the webhook's secret, of the timestamp, a dot and
+// the body, so receivers can also reject old deliveries.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+var errBlockedAddress = errors.New("webhook address is not public")
+
+// WebhookDispatcher sends queued webhook deliveries.
+type WebhookDispatcher struct {
+	DB *sql.DB
+	// Timeout limits each delivery, connecting included.
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is marked
+	// failed. Retries wait Backoff, doubled after every attempt.
+	MaxAttempts int
+	Backoff     time.Duration
+	// DisableAfter is the number of failed attempts in a row after which
+	// a webhook is disabled; 0 never disables.
+	DisableAfter int
+	// BatchSize is the number of deliveries claimed per run.
+	BatchSize int
+	// Allow reports whether deliveries may connect to ip; only public
+	// addresses by default.
+	Allow func(ip net.IP) bool
+
+	client *http.Client
+}
+
+// SignWebhook returns the signature header of a delivery of body at
+// timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run sends one batch of due deliveries.
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	deliveries, err := models.ClaimWebhookDeliveries(d.DB, d.BatchSize, d.MaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			// The lease expires and another run picks the rest up.
+			return nil
+		}
+
+		delivery := &deliveries[i]
+		status, err := d.send(ctx, delivery)
+		if err == nil {
+			if err := delivery.SucceedWebhookDelivery(d.DB, status); err != nil {
+				return err
+			}
+			continue
+		}
+
+		log.Printf("delivering webhook %s: %v", delivery.ID, err)
+		var responseStatus *int
+		if status != 0 {
+			responseStatus = &status
+		}
+		err = delivery.FailWebhookDelivery(d.DB, err.Error(), responseStatus, d.MaxAttempts, d.Backoff, d.DisableAfter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// send posts the delivery and returns the response status. Anything but a
+// 2xx response is an error.
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-test-0-webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.httpClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	// Reading a little of the body lets the connection be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *WebhookDispatcher) httpClient() *http.Client {
+	if d.client != nil {
+		return d.client
+	}
+
+	allow := d.Allow
+	if allow == nil {
+		allow = unfurl.PublicIP
+	}
+
+	dialer := &net.Dialer{
+		Timeout: d.Timeout,
+		// Control sees the address being connected to after DNS, so names
+		// resolving to internal addresses are refused too.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allow(ip) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	d.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: d.Timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// Redirects are not followed: a webhook's URL is where it wants
+		// its deliveries.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d.client
+}
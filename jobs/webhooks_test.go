@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bayudha2/go-test-0/models"
+)
+
+func TestWebhookSend(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	d := &WebhookDispatcher{Timeout: time.Second, Allow: func(net.IP) bool { return true }}
+	delivery := &models.WebhookDelivery{ID: "d1", Event: models.WebhookPostCreated, URL: server.URL + "/ok",
+		Secret: "inisecret", Payload: []byte(`{"id":"e1"}`)}
+
+	status, err := d.send(context.Background(), delivery)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Expected the delivery to be accepted. Got %d %v", status, err)
+	}
+
+	if got.Header.Get(WebhookEventHeader) != models.WebhookPostCreated || got.Header.Get(WebhookDeliveryHeader) != "d1" {
+		t.Errorf("Expected the event and delivery headers. Got %v", got.Header)
+	}
+
+	timestamp, _ := strconv.ParseInt(got.Header.Get(WebhookTimestampHeader), 10, 64)
+	if sig := got.Header.Get(WebhookSignatureHeader); sig != SignWebhook("inisecret", timestamp, body) {
+		t.Errorf("Expected the signature to match the body. Got %s", sig)
+	}
+
+	if SignWebhook("inisecret", timestamp+1, body) == SignWebhook("inisecret", timestamp, body) {
+		t.Errorf("Expected the timestamp to be signed")
+	}
+
+	delivery.URL = server.URL + "/down"
+	if status, err := d.send(context.Background(), delivery); err == nil || status != http.StatusServiceUnavailable {
+		t.Errorf("Expected an error response to fail. Got %d %v", status, err)
+	}
+
+	delivery.URL = server.URL + "/moved"
+	if status, err := d.send(context.Background(), delivery); err == nil || status != http.StatusFound {
+		t.Errorf("Expected redirects not to be followed. Got %d %v", status, err)
+	}
+}
+
+func TestWebhookSendBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected the request not to reach a loopback address")
+	}))
+	defer server.Close()
+
+	d := &WebhookDispatcher{Timeout: time.Second}
+	delivery := &models.WebhookDelivery{ID: "d1", URL: server.URL, Payload: []byte(`{}`)}
+	if status, err := d.send(context.Background(), delivery); err == nil || status != 0 {
+		t.Errorf("Expected the delivery to be refused. Got %d %v", status, err)
+	}
+}
@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/bayudha2/go-test-0/app"
+	"github.com/bayudha2/go-test-0/config"
+	"github.com/bayudha2/go-test-0/jobs"
 	"github.com/bayudha2/go-test-0/models"
+	"github.com/bayudha2/go-test-0/realtime"
+	"github.com/bayudha2/go-test-0/storage"
+	"github.com/bayudha2/go-test-0/unfurl"
+	"github.com/bayudha2/go-test-0/utils/imaging"
 )
 
 func main() {
@@ -15,6 +26,130 @@ func main() {
 		os.Getenv("APP_DB_PASSWORD"),
 		os.Getenv("APP_DB_NAME"))
 
+	if err := models.SetTextSearchConfig(os.Getenv("APP_SEARCH_CONFIG")); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := models.ResyncSearchConfig(models.DB); err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	storage.Default = store
+
+	models.TrashRetention = config.DurationFromEnv("APP_TRASH_RETENTION", models.TrashRetention)
+	models.CommentEditWindow = config.DurationFromEnv("APP_COMMENT_EDIT_WINDOW", models.CommentEditWindow)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var runner jobs.Runner
+	runner.Every(ctx, "publish scheduled posts",
+		config.DurationFromEnv("APP_SCHEDULER_INTERVAL", 30*time.Second),
+		jobs.PublishScheduledPosts(models.DB))
+	runner.Every(ctx, "purge trash",
+		config.DurationFromEnv("APP_PURGE_INTERVAL", time.Hour),
+		jobs.PurgeTrash(models.DB))
+	runner.Every(ctx, "collect orphaned attachments",
+		config.DurationFromEnv("APP_ORPHAN_GC_INTERVAL", time.Hour),
+		jobs.CollectOrphanedAttachments(models.DB, store,
+			config.DurationFromEnv("APP_ORPHAN_UPLOAD_TTL", 24*time.Hour)))
+
+	variants := imaging.DefaultVariants
+	if spec := os.Getenv("APP_IMAGE_VARIANTS"); spec != "" {
+		if variants, err = imaging.ParseVariants(spec); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	images := &jobs.ImagePipeline{
+		DB:          models.DB,
+		Store:       store,
+		Variants:    variants,
+		MaxAttempts: config.IntFromEnv("APP_IMAGE_MAX_ATTEMPTS", 5),
+		Backoff:     config.DurationFromEnv("APP_IMAGE_RETRY_BACKOFF", time.Minute),
+		BatchSize:   10,
+	}
+	runner.Every(ctx, "process images",
+		config.DurationFromEnv("APP_IMAGE_INTERVAL", 10*time.Second),
+		images.Run)
+
+	models.LinkPreviewTTL = config.DurationFromEnv("APP_LINK_PREVIEW_TTL", models.LinkPreviewTTL)
+	fetcher := unfurl.NewFetcher()
+	fetcher.Timeout = config.DurationFromEnv("APP_UNFURL_TIMEOUT", fetcher.Timeout)
+	fetcher.MaxBytes = int64(config.IntFromEnv("APP_UNFURL_MAX_BYTES", int(fetcher.MaxBytes)))
+	links := &jobs.LinkUnfurler{
+		DB:          models.DB,
+		Fetcher:     fetcher,
+		MaxAttempts: config.IntFromEnv("APP_UNFURL_MAX_ATTEMPTS", 3),
+		Backoff:     config.DurationFromEnv("APP_UNFURL_RETRY_BACKOFF", time.Minute),
+		BatchSize:   10,
+	}
+	runner.Every(ctx, "unfurl links",
+		config.DurationFromEnv("APP_UNFURL_INTERVAL", 10*time.Second),
+		links.Run)
+
+	webhooks := &jobs.WebhookDispatcher{
+		DB:           models.DB,
+		Timeout:      config.DurationFromEnv("APP_WEBHOOK_TIMEOUT", 10*time.Second),
+		MaxAttempts:  config.IntFromEnv("APP_WEBHOOK_MAX_ATTEMPTS", 8),
+		Backoff:      config.DurationFromEnv("APP_WEBHOOK_RETRY_BACKOFF", 30*time.Second),
+		DisableAfter: config.IntFromEnv("APP_WEBHOOK_DISABLE_AFTER", 20),
+		BatchSize:    20,
+	}
+	runner.Every(ctx, "deliver webhooks",
+		config.DurationFromEnv("APP_WEBHOOK_INTERVAL", 5*time.Second),
+		webhooks.Run)
+
+	models.Views.Window = config.DurationFromEnv("APP_VIEW_DEDUP_WINDOW", models.Views.Window)
+	runner.Every(ctx, "flush post views",
+		config.DurationFromEnv("APP_VIEW_FLUSH_INTERVAL", 30*time.Second),
+		func(ctx context.Context) error { return models.Views.Flush(models.DB) })
+
+	realtime.Comments.Buffer = config.IntFromEnv("APP_STREAM_BUFFER", realtime.Comments.Buffer)
+	go func() {
+		if err := realtime.ListenComments(ctx, models.DB, models.ConnectionString, realtime.Comments); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	realtime.Activity = realtime.NewStream(realtime.Comments.Buffer, config.IntFromEnv("APP_EVENTS_REPLAY", 1000))
+	go func() {
+		if err := realtime.ListenActivity(ctx, models.DB, models.ConnectionString, realtime.Activity); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
 	app.Initialize()
-	log.Fatal(http.ListenAndServe(":8010", app.R))
+	server := &http.Server{
+		Addr:    ":8010",
+		Handler: app.R,
+		// Ends the requests that stream, which Shutdown would wait on, once
+		// the server is shutting down.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println(err)
+	}
+	runner.Wait()
+
+	// Views recorded by the last requests, after the final scheduled flush.
+	if err := models.Views.Flush(models.DB); err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "public"."posts_search_vector_idx";
+
+ALTER TABLE "public"."posts"
+    DROP COLUMN IF EXISTS "search_vector",
+    DROP COLUMN IF EXISTS "search_config";
\ No newline at end of file
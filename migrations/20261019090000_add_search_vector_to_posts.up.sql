@@ -0,0 +1,5 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "search_config" regconfig NOT NULL DEFAULT 'simple',
+    ADD COLUMN "search_vector" tsvector GENERATED ALWAYS AS (to_tsvector("search_config", "description")) STORED;
+
+CREATE INDEX IF NOT EXISTS "posts_search_vector_idx" ON "public"."posts" USING GIN ("search_vector");
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."tags";
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS "public"."tags" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "name" varchar(50) UNIQUE NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."post_tags";
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "public"."post_tags" (
+    "post_id" varchar(36) NOT NULL,
+    "tag_id" varchar(36) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("post_id", "tag_id")
+);
+
+CREATE INDEX IF NOT EXISTS "post_tags_tag_id_created_at_idx" ON "public"."post_tags" ("tag_id", "created_at");
\ No newline at end of file
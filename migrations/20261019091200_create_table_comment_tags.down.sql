@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."comment_tags";
\ No newline at end of file
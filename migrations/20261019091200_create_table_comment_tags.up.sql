@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "public"."comment_tags" (
+    "comment_id" varchar(36) NOT NULL,
+    "tag_id" varchar(36) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("comment_id", "tag_id")
+);
+
+CREATE INDEX IF NOT EXISTS "comment_tags_tag_id_created_at_idx" ON "public"."comment_tags" ("tag_id", "created_at");
\ No newline at end of file
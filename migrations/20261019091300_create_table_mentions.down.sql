@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."mentions";
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "public"."mentions" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "author_id" varchar(36) NOT NULL,
+    "post_id" varchar(36) NOT NULL,
+    "comment_id" varchar(36),
+    "created_at" timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "mentions_post_user_idx" ON "public"."mentions" ("post_id", "user_id") WHERE "comment_id" IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS "mentions_comment_user_idx" ON "public"."mentions" ("comment_id", "user_id");
+CREATE INDEX IF NOT EXISTS "mentions_user_id_created_at_idx" ON "public"."mentions" ("user_id", "created_at");
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE "public"."post_tags"
+    DROP CONSTRAINT "post_tags_post_id_fkey";
+
+ALTER TABLE "public"."post_tags"
+    DROP CONSTRAINT "post_tags_tag_id_fkey";
+
+ALTER TABLE "public"."comment_tags"
+    DROP CONSTRAINT "comment_tags_comment_id_fkey";
+
+ALTER TABLE "public"."comment_tags"
+    DROP CONSTRAINT "comment_tags_tag_id_fkey";
\ No newline at end of file
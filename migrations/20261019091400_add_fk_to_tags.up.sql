@@ -0,0 +1,11 @@
+ALTER TABLE "public"."post_tags"
+    ADD CONSTRAINT "post_tags_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."post_tags"
+    ADD CONSTRAINT "post_tags_tag_id_fkey" FOREIGN KEY ("tag_id") REFERENCES "public"."tags"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."comment_tags"
+    ADD CONSTRAINT "comment_tags_comment_id_fkey" FOREIGN KEY ("comment_id") REFERENCES "public"."comments"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."comment_tags"
+    ADD CONSTRAINT "comment_tags_tag_id_fkey" FOREIGN KEY ("tag_id") REFERENCES "public"."tags"("id") ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE "public"."mentions"
+    DROP CONSTRAINT "mentions_user_id_fkey";
+
+ALTER TABLE "public"."mentions"
+    DROP CONSTRAINT "mentions_author_id_fkey";
+
+ALTER TABLE "public"."mentions"
+    DROP CONSTRAINT "mentions_post_id_fkey";
+
+ALTER TABLE "public"."mentions"
+    DROP CONSTRAINT "mentions_comment_id_fkey";
\ No newline at end of file
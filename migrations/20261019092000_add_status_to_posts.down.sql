@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "public"."posts_scheduled_publish_at_idx";
+
+ALTER TABLE "public"."posts"
+    DROP COLUMN IF EXISTS "publish_at",
+    DROP COLUMN IF EXISTS "status";
\ No newline at end of file
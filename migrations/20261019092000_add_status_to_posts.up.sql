@@ -0,0 +1,8 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "status" varchar(10) NOT NULL DEFAULT 'published'
+        CONSTRAINT "posts_status_check" CHECK ("status" IN ('draft', 'scheduled', 'published')),
+    ADD COLUMN "publish_at" timestamptz;
+
+UPDATE "public"."posts" SET "publish_at" = "created_at" WHERE "publish_at" IS NULL;
+
+CREATE INDEX IF NOT EXISTS "posts_scheduled_publish_at_idx" ON "public"."posts" ("publish_at") WHERE "status" = 'scheduled';
\ No newline at end of file
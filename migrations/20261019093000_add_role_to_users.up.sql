@@ -0,0 +1,3 @@
+ALTER TABLE "public"."users"
+    ADD COLUMN "role" varchar(10) NOT NULL DEFAULT 'user'
+        CONSTRAINT "users_role_check" CHECK ("role" IN ('user', 'moderator', 'admin'));
\ No newline at end of file
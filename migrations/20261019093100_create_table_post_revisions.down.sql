@@ -0,0 +1,4 @@
+ALTER TABLE "public"."posts"
+    DROP COLUMN IF EXISTS "edit_count";
+
+DROP TABLE IF EXISTS "public"."post_revisions";
\ No newline at end of file
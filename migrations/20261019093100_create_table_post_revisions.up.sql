@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "public"."post_revisions" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "post_id" varchar(36) NOT NULL,
+    "revision" integer NOT NULL,
+    "description" text NOT NULL,
+    "editor_id" varchar(36) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE ("post_id", "revision")
+);
+
+ALTER TABLE "public"."posts"
+    ADD COLUMN "edit_count" integer NOT NULL DEFAULT 0;
\ No newline at end of file
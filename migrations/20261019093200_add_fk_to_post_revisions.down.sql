@@ -0,0 +1,5 @@
+ALTER TABLE "public"."post_revisions"
+    DROP CONSTRAINT "post_revisions_post_id_fkey";
+
+ALTER TABLE "public"."post_revisions"
+    DROP CONSTRAINT "post_revisions_editor_id_fkey";
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "public"."post_revisions"
+    ADD CONSTRAINT "post_revisions_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."post_revisions"
+    ADD CONSTRAINT "post_revisions_editor_id_fkey" FOREIGN KEY ("editor_id") REFERENCES "public"."users"("id");
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS "public"."comments_deleted_at_idx";
+DROP INDEX IF EXISTS "public"."posts_deleted_at_idx";
+
+ALTER TABLE "public"."comments"
+    DROP COLUMN IF EXISTS "deleted_at";
+
+ALTER TABLE "public"."posts"
+    DROP COLUMN IF EXISTS "deleted_at";
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "deleted_at" timestamptz;
+
+ALTER TABLE "public"."comments"
+    ADD COLUMN "deleted_at" timestamptz;
+
+CREATE INDEX IF NOT EXISTS "posts_deleted_at_idx" ON "public"."posts" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "comments_deleted_at_idx" ON "public"."comments" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
\ No newline at end of file
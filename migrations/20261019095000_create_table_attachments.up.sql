@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "public"."attachments" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "post_id" varchar(36),
+    "blob_key" varchar(255) UNIQUE NOT NULL,
+    "content_type" varchar(100) NOT NULL,
+    "size" bigint NOT NULL,
+    "alt_text" text NOT NULL DEFAULT '',
+    "created_at" timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS attachments_post_id_idx ON "public"."attachments" ("post_id");
\ No newline at end of file
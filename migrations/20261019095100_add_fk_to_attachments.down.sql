@@ -0,0 +1,5 @@
+ALTER TABLE "public"."attachments"
+    DROP CONSTRAINT "attachments_user_id_fkey";
+
+ALTER TABLE "public"."attachments"
+    DROP CONSTRAINT "attachments_post_id_fkey";
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "public"."attachments"
+    ADD CONSTRAINT "attachments_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id");
+
+ALTER TABLE "public"."attachments"
+    ADD CONSTRAINT "attachments_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS attachments_processing_idx;
+
+ALTER TABLE "public"."attachments"
+    DROP COLUMN "width",
+    DROP COLUMN "height",
+    DROP COLUMN "blurhash",
+    DROP COLUMN "processing_status",
+    DROP COLUMN "processing_attempts",
+    DROP COLUMN "processing_error",
+    DROP COLUMN "next_attempt_at";
\ No newline at end of file
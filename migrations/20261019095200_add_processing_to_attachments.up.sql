@@ -0,0 +1,14 @@
+ALTER TABLE "public"."attachments"
+    ADD COLUMN "width" integer,
+    ADD COLUMN "height" integer,
+    ADD COLUMN "blurhash" varchar(64),
+    ADD COLUMN "processing_status" varchar(20) NOT NULL DEFAULT 'skipped',
+    ADD COLUMN "processing_attempts" integer NOT NULL DEFAULT 0,
+    ADD COLUMN "processing_error" text,
+    ADD COLUMN "next_attempt_at" timestamptz NOT NULL DEFAULT NOW();
+
+UPDATE "public"."attachments" SET "processing_status" = 'pending'
+    WHERE "content_type" IN ('image/jpeg', 'image/png', 'image/gif');
+
+CREATE INDEX IF NOT EXISTS attachments_processing_idx ON "public"."attachments" ("next_attempt_at")
+    WHERE "processing_status" IN ('pending', 'processing');
\ No newline at end of file
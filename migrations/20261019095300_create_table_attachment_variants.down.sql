@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."attachment_variants";
\ No newline at end of file
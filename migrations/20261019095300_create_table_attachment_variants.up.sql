@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "public"."attachment_variants" (
+    "attachment_id" varchar(36) NOT NULL,
+    "name" varchar(50) NOT NULL,
+    "blob_key" varchar(255) UNIQUE NOT NULL,
+    "content_type" varchar(100) NOT NULL,
+    "width" integer NOT NULL,
+    "height" integer NOT NULL,
+    "size" bigint NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("attachment_id", "name")
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."attachment_variants"
+    DROP CONSTRAINT "attachment_variants_attachment_id_fkey";
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."attachment_variants"
+    ADD CONSTRAINT "attachment_variants_attachment_id_fkey" FOREIGN KEY ("attachment_id") REFERENCES "public"."attachments"("id") ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS posts_user_repost_idx;
+
+DROP INDEX IF EXISTS posts_repost_of_id_idx;
+
+ALTER TABLE "public"."posts"
+    DROP COLUMN "kind",
+    DROP COLUMN "repost_of_id";
\ No newline at end of file
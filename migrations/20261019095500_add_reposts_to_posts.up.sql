@@ -0,0 +1,9 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "kind" varchar(10) NOT NULL DEFAULT 'post'
+        CONSTRAINT "posts_kind_check" CHECK ("kind" IN ('post', 'repost', 'quote')),
+    ADD COLUMN "repost_of_id" varchar(36);
+
+CREATE INDEX IF NOT EXISTS posts_repost_of_id_idx ON "public"."posts" ("repost_of_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS posts_user_repost_idx ON "public"."posts" ("user_id", "repost_of_id")
+    WHERE "kind" = 'repost' AND "deleted_at" IS NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    DROP CONSTRAINT "posts_repost_of_id_fkey";
\ No newline at end of file
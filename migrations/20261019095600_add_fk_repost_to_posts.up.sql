@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    ADD CONSTRAINT "posts_repost_of_id_fkey" FOREIGN KEY ("repost_of_id") REFERENCES "public"."posts"("id") ON DELETE SET NULL;
\ No newline at end of file
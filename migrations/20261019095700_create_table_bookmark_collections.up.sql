@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "public"."bookmark_collections" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    "updated_at" timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "bookmark_collections_user_id_name_idx" ON "public"."bookmark_collections" ("user_id", lower("name"));
\ No newline at end of file
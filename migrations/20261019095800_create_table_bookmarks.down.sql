@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."bookmarks";
\ No newline at end of file
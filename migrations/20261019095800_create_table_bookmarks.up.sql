@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS "public"."bookmarks" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "collection_id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "post_id" varchar(36),
+    "product_id" varchar(36),
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    CONSTRAINT "bookmarks_item_check" CHECK (("post_id" IS NULL) <> ("product_id" IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "bookmarks_collection_post_idx" ON "public"."bookmarks" ("collection_id", "post_id") WHERE "post_id" IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "bookmarks_collection_product_idx" ON "public"."bookmarks" ("collection_id", "product_id") WHERE "product_id" IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS "bookmarks_user_id_created_at_idx" ON "public"."bookmarks" ("user_id", "created_at", "id");
\ No newline at end of file
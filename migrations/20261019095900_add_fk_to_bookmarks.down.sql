@@ -0,0 +1,14 @@
+ALTER TABLE "public"."bookmarks"
+    DROP CONSTRAINT "bookmarks_product_id_fkey";
+
+ALTER TABLE "public"."bookmarks"
+    DROP CONSTRAINT "bookmarks_post_id_fkey";
+
+ALTER TABLE "public"."bookmarks"
+    DROP CONSTRAINT "bookmarks_user_id_fkey";
+
+ALTER TABLE "public"."bookmarks"
+    DROP CONSTRAINT "bookmarks_collection_id_fkey";
+
+ALTER TABLE "public"."bookmark_collections"
+    DROP CONSTRAINT "bookmark_collections_user_id_fkey";
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."polls";
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "public"."polls" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "post_id" varchar(36) UNIQUE NOT NULL,
+    "multiple" boolean NOT NULL DEFAULT false,
+    "hide_results" boolean NOT NULL DEFAULT false,
+    "closes_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
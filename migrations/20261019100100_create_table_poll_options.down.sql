@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."poll_options";
\ No newline at end of file
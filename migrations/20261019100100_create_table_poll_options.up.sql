@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "public"."poll_options" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "poll_id" varchar(36) NOT NULL,
+    "position" integer NOT NULL,
+    "label" varchar(100) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "poll_options_poll_id_position_idx" ON "public"."poll_options" ("poll_id", "position");
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS "public"."poll_voters" (
+    "poll_id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("poll_id", "user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "public"."poll_votes" (
+    "poll_id" varchar(36) NOT NULL,
+    "option_id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    PRIMARY KEY ("option_id", "user_id")
+);
+
+CREATE INDEX IF NOT EXISTS "poll_votes_poll_id_user_id_idx" ON "public"."poll_votes" ("poll_id", "user_id");
\ No newline at end of file
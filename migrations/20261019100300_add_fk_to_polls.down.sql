@@ -0,0 +1,17 @@
+ALTER TABLE "public"."poll_votes"
+    DROP CONSTRAINT "poll_votes_option_id_fkey";
+
+ALTER TABLE "public"."poll_votes"
+    DROP CONSTRAINT "poll_votes_voter_fkey";
+
+ALTER TABLE "public"."poll_voters"
+    DROP CONSTRAINT "poll_voters_user_id_fkey";
+
+ALTER TABLE "public"."poll_voters"
+    DROP CONSTRAINT "poll_voters_poll_id_fkey";
+
+ALTER TABLE "public"."poll_options"
+    DROP CONSTRAINT "poll_options_poll_id_fkey";
+
+ALTER TABLE "public"."polls"
+    DROP CONSTRAINT "polls_post_id_fkey";
\ No newline at end of file
@@ -0,0 +1,17 @@
+ALTER TABLE "public"."polls"
+    ADD CONSTRAINT "polls_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."poll_options"
+    ADD CONSTRAINT "poll_options_poll_id_fkey" FOREIGN KEY ("poll_id") REFERENCES "public"."polls"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."poll_voters"
+    ADD CONSTRAINT "poll_voters_poll_id_fkey" FOREIGN KEY ("poll_id") REFERENCES "public"."polls"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."poll_voters"
+    ADD CONSTRAINT "poll_voters_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."poll_votes"
+    ADD CONSTRAINT "poll_votes_voter_fkey" FOREIGN KEY ("poll_id", "user_id") REFERENCES "public"."poll_voters"("poll_id", "user_id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."poll_votes"
+    ADD CONSTRAINT "poll_votes_option_id_fkey" FOREIGN KEY ("option_id") REFERENCES "public"."poll_options"("id") ON DELETE CASCADE;
\ No newline at end of file
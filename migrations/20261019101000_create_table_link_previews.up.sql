@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "public"."link_previews" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "url" varchar(2048) UNIQUE NOT NULL,
+    "final_url" varchar(2048),
+    "title" varchar(300) NOT NULL DEFAULT '',
+    "description" varchar(1000) NOT NULL DEFAULT '',
+    "image_url" varchar(2048),
+    "site_name" varchar(300) NOT NULL DEFAULT '',
+    "status" varchar(16) NOT NULL DEFAULT 'pending',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "error" text,
+    "next_attempt_at" timestamptz NOT NULL DEFAULT NOW(),
+    "fetched_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    CONSTRAINT "link_previews_status_check" CHECK ("status" IN ('pending', 'processing', 'done', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS "link_previews_status_next_attempt_at_idx" ON "public"."link_previews" ("status", "next_attempt_at");
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."post_links";
\ No newline at end of file
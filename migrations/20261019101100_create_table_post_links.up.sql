@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS "public"."post_links" (
+    "post_id" varchar(36) NOT NULL,
+    "preview_id" varchar(36) NOT NULL,
+    "position" integer NOT NULL,
+    PRIMARY KEY ("post_id", "preview_id")
+);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "public"."post_links"
+    DROP CONSTRAINT "post_links_preview_id_fkey";
+
+ALTER TABLE "public"."post_links"
+    DROP CONSTRAINT "post_links_post_id_fkey";
\ No newline at end of file
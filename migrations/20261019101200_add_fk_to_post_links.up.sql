@@ -0,0 +1,5 @@
+ALTER TABLE "public"."post_links"
+    ADD CONSTRAINT "post_links_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."post_links"
+    ADD CONSTRAINT "post_links_preview_id_fkey" FOREIGN KEY ("preview_id") REFERENCES "public"."link_previews"("id") ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."post_views";
\ No newline at end of file
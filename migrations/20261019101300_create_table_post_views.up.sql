@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS "public"."post_views" (
+    "post_id" varchar(36) NOT NULL,
+    "day" date NOT NULL,
+    "views" bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY ("post_id", "day")
+);
\ No newline at end of file
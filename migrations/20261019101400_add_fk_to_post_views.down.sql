@@ -0,0 +1,2 @@
+ALTER TABLE "public"."post_views"
+    DROP CONSTRAINT "post_views_post_id_fkey";
\ No newline at end of file
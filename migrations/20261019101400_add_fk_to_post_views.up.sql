@@ -0,0 +1,2 @@
+ALTER TABLE "public"."post_views"
+    ADD CONSTRAINT "post_views_post_id_fkey" FOREIGN KEY ("post_id") REFERENCES "public"."posts"("id") ON DELETE CASCADE;
\ No newline at end of file
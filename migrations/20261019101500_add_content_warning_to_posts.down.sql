@@ -0,0 +1,4 @@
+ALTER TABLE "public"."posts"
+    DROP COLUMN "sensitive",
+    DROP COLUMN "content_warning",
+    DROP COLUMN "warning_forced_by";
\ No newline at end of file
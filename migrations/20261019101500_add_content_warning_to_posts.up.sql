@@ -0,0 +1,4 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "sensitive" boolean NOT NULL DEFAULT false,
+    ADD COLUMN "content_warning" varchar(100),
+    ADD COLUMN "warning_forced_by" varchar(36);
\ No newline at end of file
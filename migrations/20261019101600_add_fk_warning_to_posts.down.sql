@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    DROP CONSTRAINT "posts_warning_forced_by_fkey";
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    ADD CONSTRAINT "posts_warning_forced_by_fkey" FOREIGN KEY ("warning_forced_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
\ No newline at end of file
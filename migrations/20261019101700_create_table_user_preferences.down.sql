@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."user_preferences";
\ No newline at end of file
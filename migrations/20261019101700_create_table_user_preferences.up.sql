@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS "public"."user_preferences" (
+    "user_id" varchar(36) NOT NULL,
+    "sensitive_content" varchar(8) NOT NULL DEFAULT 'blur'
+        CONSTRAINT "user_preferences_sensitive_content_check" CHECK ("sensitive_content" IN ('show', 'blur', 'hide')),
+    "updated_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("user_id")
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."user_preferences"
+    DROP CONSTRAINT "user_preferences_user_id_fkey";
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "public"."comments_parent_id_idx";
+DROP INDEX IF EXISTS "public"."comments_post_id_parent_id_idx";
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS "comments_post_id_parent_id_idx" ON "public"."comments" ("post_id", "parent_id");
+CREATE INDEX IF NOT EXISTS "comments_parent_id_idx" ON "public"."comments" ("parent_id") WHERE "parent_id" IS NOT NULL;
\ No newline at end of file
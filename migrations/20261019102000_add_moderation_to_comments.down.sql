@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "public"."comments_post_id_status_idx";
+
+ALTER TABLE "public"."comments"
+    DROP COLUMN "status",
+    DROP COLUMN "moderated_by",
+    DROP COLUMN "moderated_at",
+    DROP COLUMN "deleted_by";
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE "public"."comments"
+    ADD COLUMN "status" varchar(10) NOT NULL DEFAULT 'visible'
+        CONSTRAINT "comments_status_check" CHECK ("status" IN ('visible', 'pending', 'hidden')),
+    ADD COLUMN "moderated_by" varchar(36),
+    ADD COLUMN "moderated_at" timestamptz,
+    ADD COLUMN "deleted_by" varchar(36);
+
+CREATE INDEX IF NOT EXISTS "comments_post_id_status_idx" ON "public"."comments" ("post_id", "status") WHERE "status" <> 'visible';
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "public"."comments"
+    DROP CONSTRAINT "comments_deleted_by_fkey";
+
+ALTER TABLE "public"."comments"
+    DROP CONSTRAINT "comments_moderated_by_fkey";
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "public"."comments"
+    ADD CONSTRAINT "comments_moderated_by_fkey" FOREIGN KEY ("moderated_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
+
+ALTER TABLE "public"."comments"
+    ADD CONSTRAINT "comments_deleted_by_fkey" FOREIGN KEY ("deleted_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    DROP COLUMN "comment_approval";
\ No newline at end of file
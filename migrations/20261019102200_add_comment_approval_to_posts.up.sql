@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "comment_approval" boolean NOT NULL DEFAULT false;
\ No newline at end of file
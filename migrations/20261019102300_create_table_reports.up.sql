@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS "public"."reports" (
+    "id" varchar(36) UNIQUE NOT NULL,
+    "reporter_id" varchar(36) NOT NULL,
+    "target_type" varchar(10) NOT NULL
+        CONSTRAINT "reports_target_type_check" CHECK ("target_type" IN ('post', 'comment', 'user')),
+    "target_id" varchar(36) NOT NULL,
+    "reason" varchar(20) NOT NULL
+        CONSTRAINT "reports_reason_check" CHECK ("reason" IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'impersonation', 'other')),
+    "details" text NOT NULL DEFAULT '',
+    "status" varchar(10) NOT NULL DEFAULT 'open'
+        CONSTRAINT "reports_status_check" CHECK ("status" IN ('open', 'resolved', 'dismissed')),
+    "assignee_id" varchar(36),
+    "action" varchar(10)
+        CONSTRAINT "reports_action_check" CHECK ("action" IN ('none', 'hide', 'suspend')),
+    "resolution_note" text,
+    "closed_by" varchar(36),
+    "closed_at" timestamptz,
+    "outcome_seen_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    "updated_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "reports_open_reporter_target_idx" ON "public"."reports" ("reporter_id", "target_type", "target_id") WHERE "status" = 'open';
+
+CREATE INDEX IF NOT EXISTS "reports_status_created_at_idx" ON "public"."reports" ("status", "created_at");
\ No newline at end of file
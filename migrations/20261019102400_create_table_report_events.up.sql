@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "public"."report_events" (
+    "id" bigserial NOT NULL,
+    "report_id" varchar(36) NOT NULL,
+    "actor_id" varchar(36),
+    "event" varchar(10) NOT NULL
+        CONSTRAINT "report_events_event_check" CHECK ("event" IN ('created', 'assigned', 'resolved', 'dismissed')),
+    "detail" text NOT NULL DEFAULT '',
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "report_events_report_id_idx" ON "public"."report_events" ("report_id", "id");
\ No newline at end of file
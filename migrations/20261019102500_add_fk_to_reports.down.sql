@@ -0,0 +1,14 @@
+ALTER TABLE "public"."report_events"
+    DROP CONSTRAINT "report_events_actor_id_fkey";
+
+ALTER TABLE "public"."report_events"
+    DROP CONSTRAINT "report_events_report_id_fkey";
+
+ALTER TABLE "public"."reports"
+    DROP CONSTRAINT "reports_closed_by_fkey";
+
+ALTER TABLE "public"."reports"
+    DROP CONSTRAINT "reports_assignee_id_fkey";
+
+ALTER TABLE "public"."reports"
+    DROP CONSTRAINT "reports_reporter_id_fkey";
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE "public"."users"
+    ADD COLUMN "suspended_at" timestamptz,
+    ADD COLUMN "suspended_by" varchar(36);
\ No newline at end of file
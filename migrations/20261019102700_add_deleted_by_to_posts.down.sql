@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    DROP COLUMN "deleted_by";
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "deleted_by" varchar(36);
\ No newline at end of file
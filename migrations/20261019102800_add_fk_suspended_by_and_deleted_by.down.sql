@@ -0,0 +1,5 @@
+ALTER TABLE "public"."posts"
+    DROP CONSTRAINT "posts_deleted_by_fkey";
+
+ALTER TABLE "public"."users"
+    DROP CONSTRAINT "users_suspended_by_fkey";
\ No newline at end of file
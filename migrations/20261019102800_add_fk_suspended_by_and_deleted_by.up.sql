@@ -0,0 +1,5 @@
+ALTER TABLE "public"."users"
+    ADD CONSTRAINT "users_suspended_by_fkey" FOREIGN KEY ("suspended_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
+
+ALTER TABLE "public"."posts"
+    ADD CONSTRAINT "posts_deleted_by_fkey" FOREIGN KEY ("deleted_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
\ No newline at end of file
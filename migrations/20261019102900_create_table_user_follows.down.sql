@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."user_follows";
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "public"."user_follows" (
+    "follower_id" varchar(36) NOT NULL,
+    "followee_id" varchar(36) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    CONSTRAINT "user_follows_not_self_check" CHECK ("follower_id" <> "followee_id"),
+    PRIMARY KEY ("follower_id", "followee_id")
+);
+
+CREATE INDEX IF NOT EXISTS "user_follows_followee_id_idx" ON "public"."user_follows" ("followee_id");
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "public"."user_follows"
+    DROP CONSTRAINT "user_follows_followee_id_fkey";
+
+ALTER TABLE "public"."user_follows"
+    DROP CONSTRAINT "user_follows_follower_id_fkey";
\ No newline at end of file
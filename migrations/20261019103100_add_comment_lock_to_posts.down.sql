@@ -0,0 +1,3 @@
+ALTER TABLE "public"."posts"
+    DROP COLUMN "comments_locked",
+    DROP COLUMN "comments_locked_by";
\ No newline at end of file
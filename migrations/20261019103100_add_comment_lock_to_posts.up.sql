@@ -0,0 +1,4 @@
+ALTER TABLE "public"."posts"
+    ADD COLUMN "comments_locked" varchar(10)
+        CONSTRAINT "posts_comments_locked_check" CHECK ("comments_locked" IN ('everyone', 'followers')),
+    ADD COLUMN "comments_locked_by" varchar(36);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    DROP CONSTRAINT "posts_comments_locked_by_fkey";
\ No newline at end of file
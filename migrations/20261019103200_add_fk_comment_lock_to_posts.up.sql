@@ -0,0 +1,2 @@
+ALTER TABLE "public"."posts"
+    ADD CONSTRAINT "posts_comments_locked_by_fkey" FOREIGN KEY ("comments_locked_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "public"."comments"
+    DROP COLUMN IF EXISTS "edit_count";
+
+DROP TABLE IF EXISTS "public"."comment_revisions";
\ No newline at end of file
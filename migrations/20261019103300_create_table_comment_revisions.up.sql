@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "public"."comment_revisions" (
+    "id" varchar(36) UNIQUE PRIMARY KEY NOT NULL,
+    "comment_id" varchar(36) NOT NULL,
+    "revision" integer NOT NULL,
+    "content" text NOT NULL,
+    "editor_id" varchar(36) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE ("comment_id", "revision")
+);
+
+ALTER TABLE "public"."comments"
+    ADD COLUMN "edit_count" integer NOT NULL DEFAULT 0;
\ No newline at end of file
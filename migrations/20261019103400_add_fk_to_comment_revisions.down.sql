@@ -0,0 +1,5 @@
+ALTER TABLE "public"."comment_revisions"
+    DROP CONSTRAINT "comment_revisions_editor_id_fkey";
+
+ALTER TABLE "public"."comment_revisions"
+    DROP CONSTRAINT "comment_revisions_comment_id_fkey";
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "public"."comment_revisions"
+    ADD CONSTRAINT "comment_revisions_comment_id_fkey" FOREIGN KEY ("comment_id") REFERENCES "public"."comments"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."comment_revisions"
+    ADD CONSTRAINT "comment_revisions_editor_id_fkey" FOREIGN KEY ("editor_id") REFERENCES "public"."users"("id");
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."notifications";
\ No newline at end of file
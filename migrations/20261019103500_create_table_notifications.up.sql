@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS "public"."notifications" (
+    "id" varchar(36) UNIQUE NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "type" varchar(10) NOT NULL
+        CONSTRAINT "notifications_type_check" CHECK ("type" IN ('comment', 'reply', 'mention', 'follow', 'repost', 'report')),
+    "group_key" varchar(60) NOT NULL,
+    "post_id" varchar(36),
+    "comment_id" varchar(36),
+    "report_id" varchar(36),
+    "actor_ids" varchar(36)[] NOT NULL DEFAULT '{}',
+    "event_count" integer NOT NULL DEFAULT 1,
+    "read_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    "updated_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "notifications_unread_group_idx" ON "public"."notifications" ("user_id", "group_key") WHERE "read_at" IS NULL;
+
+CREATE INDEX IF NOT EXISTS "notifications_user_id_updated_at_idx" ON "public"."notifications" ("user_id", "updated_at" DESC);
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "public"."notification_preferences" (
+    "user_id" varchar(36) NOT NULL,
+    "type" varchar(10) NOT NULL
+        CONSTRAINT "notification_preferences_type_check" CHECK ("type" IN ('comment', 'reply', 'mention', 'follow', 'repost', 'report')),
+    "enabled" boolean NOT NULL,
+    "updated_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("user_id", "type")
+);
\ No newline at end of file
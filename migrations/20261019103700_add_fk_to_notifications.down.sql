@@ -0,0 +1,14 @@
+ALTER TABLE "public"."notification_preferences"
+    DROP CONSTRAINT "notification_preferences_user_id_fkey";
+
+ALTER TABLE "public"."notifications"
+    DROP CONSTRAINT "notifications_report_id_fkey";
+
+ALTER TABLE "public"."notifications"
+    DROP CONSTRAINT "notifications_comment_id_fkey";
+
+ALTER TABLE "public"."notifications"
+    DROP CONSTRAINT "notifications_post_id_fkey";
+
+ALTER TABLE "public"."notifications"
+    DROP CONSTRAINT "notifications_user_id_fkey";
\ No newline at end of file
@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS "public"."activity_events_id_seq";
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS "public"."activity_events_id_seq";
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "public"."webhooks" (
+    "id" varchar(36) UNIQUE NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "url" varchar(2048) NOT NULL,
+    "secret" varchar(64) NOT NULL,
+    "events" varchar(40)[] NOT NULL
+        CONSTRAINT "webhooks_events_check" CHECK (cardinality("events") > 0 AND "events" <@ ARRAY['post.created', 'post.updated', 'post.deleted', 'comment.created', 'comment.updated', 'comment.deleted', 'product.created', 'product.updated', 'product.deleted']::varchar[]),
+    "active" boolean NOT NULL DEFAULT true,
+    "failure_count" integer NOT NULL DEFAULT 0,
+    "disabled_at" timestamptz,
+    "disabled_reason" text,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    "updated_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "webhooks_user_id_idx" ON "public"."webhooks" ("user_id");
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS "public"."webhook_deliveries" (
+    "id" varchar(36) UNIQUE NOT NULL,
+    "webhook_id" varchar(36) NOT NULL,
+    "event_id" varchar(36) NOT NULL,
+    "event" varchar(40) NOT NULL,
+    "payload" text NOT NULL,
+    "status" varchar(16) NOT NULL DEFAULT 'pending'
+        CONSTRAINT "webhook_deliveries_status_check" CHECK ("status" IN ('pending', 'processing', 'succeeded', 'failed')),
+    "attempts" integer NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL DEFAULT NOW(),
+    "response_status" integer,
+    "error" text,
+    "redelivery_of" varchar(36),
+    "delivered_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_status_next_attempt_at_idx" ON "public"."webhook_deliveries" ("status", "next_attempt_at");
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_webhook_id_created_at_idx" ON "public"."webhook_deliveries" ("webhook_id", "created_at" DESC);
\ No newline at end of file
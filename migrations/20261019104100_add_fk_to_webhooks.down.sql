@@ -0,0 +1,8 @@
+ALTER TABLE "public"."webhook_deliveries"
+    DROP CONSTRAINT "webhook_deliveries_redelivery_of_fkey";
+
+ALTER TABLE "public"."webhook_deliveries"
+    DROP CONSTRAINT "webhook_deliveries_webhook_id_fkey";
+
+ALTER TABLE "public"."webhooks"
+    DROP CONSTRAINT "webhooks_user_id_fkey";
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE "public"."webhooks"
+    ADD CONSTRAINT "webhooks_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."webhook_deliveries"
+    ADD CONSTRAINT "webhook_deliveries_webhook_id_fkey" FOREIGN KEY ("webhook_id") REFERENCES "public"."webhooks"("id") ON DELETE CASCADE;
+
+ALTER TABLE "public"."webhook_deliveries"
+    ADD CONSTRAINT "webhook_deliveries_redelivery_of_fkey" FOREIGN KEY ("redelivery_of") REFERENCES "public"."webhook_deliveries"("id") ON DELETE SET NULL;
\ No newline at end of file
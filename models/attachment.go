@@ -0,0 +1,313 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ErrInvalidAttachment = errors.New("attachment_ids must be your own uploads not attached to another post")
+
+// Processing states of an attachment. Images wait in pending until the
+// background pipeline has rendered their variants; other media is skipped.
+const (
+	ProcessingPending    = "pending"
+	ProcessingProcessing = "processing"
+	ProcessingDone       = "done"
+	ProcessingFailed     = "failed"
+	ProcessingSkipped    = "skipped"
+)
+
+// ImageTypes are the content types the image pipeline can decode.
+var ImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type Attachment struct {
+	ID               string                       `json:"id"`
+	UserId           string                       `json:"user_id"`
+	PostId           *string                      `json:"post_id"`
+	BlobKey          string                       `json:"-"`
+	ContentType      string                       `json:"content_type"`
+	Size             int64                        `json:"size"`
+	AltText          string                       `json:"alt_text"`
+	URL              string                       `json:"url"`
+	Width            *int                         `json:"width,omitempty"`
+	Height           *int                         `json:"height,omitempty"`
+	Blurhash         *string                      `json:"blurhash,omitempty"`
+	ProcessingStatus string                       `json:"processing_status"`
+	Variants         map[string]AttachmentVariant `json:"variants,omitempty"`
+	CreatedAt        time.Time                    `json:"created_at"`
+}
+
+type AttachmentVariant struct {
+	BlobKey     string `json:"-"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+}
+
+// FailedAttachment is an image the pipeline gave up on, as shown to admins.
+type FailedAttachment struct {
+	Attachment
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error"`
+}
+
+type PayloadFailedAttachments struct {
+	Data      []FailedAttachment `json:"data"`
+	TotalData int                `json:"total_data"`
+}
+
+// attachmentColumns are the columns read by scan, in the same order.
+const attachmentColumns = `attachments.id, attachments.user_id, attachments.post_id, attachments.blob_key,
+	attachments.content_type, attachments.size, attachments.alt_text, attachments.width, attachments.height,
+	attachments.blurhash, attachments.processing_status, attachments.created_at`
+
+func (a *Attachment) scan(row rowScanner, extra ...interface{}) error {
+	dest := []interface{}{&a.ID, &a.UserId, &a.PostId, &a.BlobKey, &a.ContentType, &a.Size, &a.AltText,
+		&a.Width, &a.Height, &a.Blurhash, &a.ProcessingStatus, &a.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	a.URL = "/media/" + a.ID
+	a.CreatedAt = a.CreatedAt.UTC().Add(time.Hour * 7)
+	return nil
+}
+
+// CreateAttachment records an uploaded blob. When PostId is set the post must
+// belong to the uploader and not be deleted, otherwise sql.ErrNoRows is
+// returned.
+func (a *Attachment) CreateAttachment(db *sql.DB) error {
+	status := ProcessingSkipped
+	if ImageTypes[a.ContentType] {
+		status = ProcessingPending
+	}
+
+	return a.scan(db.QueryRow(`INSERT INTO attachments(id, user_id, post_id, blob_key, content_type, size, alt_text,
+			processing_status, created_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+		WHERE $3::varchar IS NULL OR EXISTS (
+			SELECT 1 FROM posts WHERE id = $3 AND user_id = $2 AND deleted_at IS NULL)
+		RETURNING `+attachmentColumns,
+		uuid.New().String(), a.UserId, a.PostId, a.BlobKey, a.ContentType, a.Size, a.AltText, status, time.Now()))
+}
+
+// GetMedia loads an attachment that may be served publicly: one that is not
+// attached yet, or whose post is published and not deleted.
+func (a *Attachment) GetMedia(db *sql.DB) error {
+	return a.scan(db.QueryRow(`SELECT `+attachmentColumns+` FROM attachments
+		LEFT JOIN posts ON posts.id = attachments.post_id
+		WHERE attachments.id = $1 AND (attachments.post_id IS NULL
+			OR (posts.status = 'published' AND posts.deleted_at IS NULL))`, a.ID))
+}
+
+// GetVariant loads a rendered variant of an attachment found by GetMedia.
+func (a *Attachment) GetVariant(db *sql.DB, name string) (AttachmentVariant, error) {
+	var v AttachmentVariant
+	err := db.QueryRow(`SELECT blob_key, content_type, width, height, size FROM attachment_variants
+		WHERE attachment_id = $1 AND name = $2`, a.ID, name).Scan(&v.BlobKey, &v.ContentType, &v.Width, &v.Height, &v.Size)
+	v.URL = "/media/" + a.ID + "/" + name
+	return v, err
+}
+
+// DeleteAttachment removes the uploader's attachment and its variants, and
+// returns the blob keys for the caller to delete.
+func (a *Attachment) DeleteAttachment(db *sql.DB) ([]string, error) {
+	return deleteAttachments(db, `SELECT id FROM attachments WHERE id = $1 AND user_id = $2`, a.ID, a.UserId)
+}
+
+// deleteAttachments deletes the attachments selected by query, with their
+// variants, returning every blob key that belonged to them. It returns
+// sql.ErrNoRows when nothing was selected.
+func deleteAttachments(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(`WITH doomed AS (`+query+` FOR UPDATE SKIP LOCKED),
+		variants AS (
+			DELETE FROM attachment_variants WHERE attachment_id IN (SELECT id FROM doomed)
+			RETURNING blob_key),
+		deleted AS (
+			DELETE FROM attachments WHERE id IN (SELECT id FROM doomed)
+			RETURNING blob_key)
+		SELECT blob_key FROM deleted UNION ALL SELECT blob_key FROM variants`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return keys, nil
+}
+
+// attachToPost links the author's unattached uploads to a new post.
+func (p *Post) attachToPost(tx *sql.Tx) error {
+	p.Attachments = nil
+	if len(p.AttachmentIds) == 0 {
+		return nil
+	}
+
+	ids := map[string]bool{}
+	for _, id := range p.AttachmentIds {
+		ids[id] = true
+	}
+
+	rows, err := tx.Query(`UPDATE attachments SET post_id = $1
+		WHERE id = ANY($2) AND user_id = $3 AND post_id IS NULL
+		RETURNING `+attachmentColumns, p.ID, pq.Array(p.AttachmentIds), p.UserId)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attachment
+		if err := a.scan(rows); err != nil {
+			return err
+		}
+		p.Attachments = append(p.Attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if len(p.Attachments) != len(ids) {
+		return ErrInvalidAttachment
+	}
+
+	attachments := make([]*Attachment, len(p.Attachments))
+	for i := range p.Attachments {
+		attachments[i] = &p.Attachments[i]
+	}
+
+	return loadVariants(tx, attachments)
+}
+
+// loadAttachments fills in the attachments of each post with one query.
+func loadAttachments(db *sql.DB, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	index := map[string]int{}
+	ids := make([]string, len(posts))
+	for i, p := range posts {
+		posts[i].Attachments = nil
+		index[p.ID] = i
+		ids[i] = p.ID
+	}
+
+	rows, err := db.Query(`SELECT `+attachmentColumns+` FROM attachments
+		WHERE post_id = ANY($1) ORDER BY created_at, id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attachment
+		if err := a.scan(rows); err != nil {
+			return err
+		}
+
+		i := index[*a.PostId]
+		posts[i].Attachments = append(posts[i].Attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var attachments []*Attachment
+	for i := range posts {
+		for j := range posts[i].Attachments {
+			attachments = append(attachments, &posts[i].Attachments[j])
+		}
+	}
+
+	return loadVariants(db, attachments)
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadVariants fills in the rendered variants of each attachment.
+func loadVariants(db queryer, attachments []*Attachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	index := map[string]*Attachment{}
+	ids := make([]string, len(attachments))
+	for i, a := range attachments {
+		index[a.ID] = a
+		ids[i] = a.ID
+	}
+
+	rows, err := db.Query(`SELECT attachment_id, name, blob_key, content_type, width, height, size
+		FROM attachment_variants WHERE attachment_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		var v AttachmentVariant
+		if err := rows.Scan(&id, &name, &v.BlobKey, &v.ContentType, &v.Width, &v.Height, &v.Size); err != nil {
+			return err
+		}
+
+		a := index[id]
+		if a.Variants == nil {
+			a.Variants = map[string]AttachmentVariant{}
+		}
+		v.URL = "/media/" + id + "/" + name
+		a.Variants[name] = v
+	}
+
+	return rows.Err()
+}
+
+// ClaimOrphanedAttachments deletes up to limit attachments that were never
+// attached to a post, or whose post has been purged, and were created before
+// cutoff. It returns their blob keys, variants included, so the caller can
+// delete the blobs; a row is gone before its blob so it cannot be attached
+// meanwhile.
+func ClaimOrphanedAttachments(db *sql.DB, cutoff time.Time, limit int) ([]string, error) {
+	keys, err := deleteAttachments(db, `SELECT id FROM attachments
+		WHERE post_id IS NULL AND created_at < $1
+		ORDER BY created_at LIMIT $2`, cutoff, limit)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return keys, err
+}
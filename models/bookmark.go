@@ -0,0 +1,328 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCollectionExists = errors.New("You already have a collection with this name")
+	ErrBookmarkItem     = errors.New("Exactly one of post_id or product_id is required")
+)
+
+// Collection is a named, private list of bookmarks.
+type Collection struct {
+	ID        string    `json:"id" validate:"omitempty"`
+	UserId    string    `json:"user_id" validate:"omitempty"`
+	Name      string    `json:"name" validate:"required,max=100"`
+	ItemCount int       `json:"item_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Bookmark is an item in a collection, either a post or a product.
+type Bookmark struct {
+	ID           string    `json:"id"`
+	CollectionId string    `json:"collection_id"`
+	PostId       *string   `json:"post_id,omitempty"`
+	ProductId    *string   `json:"product_id,omitempty"`
+	Post         *Post     `json:"post,omitempty"`
+	Product      *Product  `json:"product,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type PayloadBookmarks struct {
+	Data       []Bookmark `json:"data"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// bookmarkVisible matches bookmarks whose item the bookmarking user can still
+// see. Bookmarks of deleted, unpublished or unavailable posts drop out of
+// every listing and come back if the post does.
+const bookmarkVisible = `(bookmarks.product_id IS NOT NULL OR EXISTS (
+	SELECT 1 FROM posts WHERE posts.id = bookmarks.post_id AND posts.deleted_at IS NULL
+		AND (posts.user_id = bookmarks.user_id OR (posts.status = 'published' AND ` + originalAvailable + `))))`
+
+const collectionColumns = `bookmark_collections.id, bookmark_collections.user_id, bookmark_collections.name,
+	(SELECT COUNT(*) FROM bookmarks WHERE bookmarks.collection_id = bookmark_collections.id AND ` + bookmarkVisible + `),
+	bookmark_collections.created_at, bookmark_collections.updated_at`
+
+func (c *Collection) scan(row rowScanner) error {
+	if err := row.Scan(&c.ID, &c.UserId, &c.Name, &c.ItemCount, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return err
+	}
+
+	c.CreatedAt = c.CreatedAt.UTC().Add(time.Hour * 7)
+	c.UpdatedAt = c.UpdatedAt.UTC().Add(time.Hour * 7)
+	return nil
+}
+
+func GetCollections(db *sql.DB, userID string) ([]Collection, error) {
+	rows, err := db.Query(`SELECT `+collectionColumns+` FROM bookmark_collections
+		WHERE user_id = $1 ORDER BY lower(name)`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		var c Collection
+		if err := c.scan(rows); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	return collections, rows.Err()
+}
+
+func (c *Collection) GetCollection(db *sql.DB) error {
+	return c.scan(db.QueryRow(`SELECT `+collectionColumns+` FROM bookmark_collections
+		WHERE id = $1 AND user_id = $2`, c.ID, c.UserId))
+}
+
+func (c *Collection) CreateCollection(db *sql.DB) error {
+	err := c.scan(db.QueryRow(`INSERT INTO bookmark_collections(id, user_id, name, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5)
+		RETURNING `+collectionColumns,
+		uuid.New().String(), c.UserId, c.Name, time.Now(), time.Now()))
+	if isUniqueViolation(err) {
+		return ErrCollectionExists
+	}
+	return err
+}
+
+func (c *Collection) UpdateCollection(db *sql.DB) error {
+	err := c.scan(db.QueryRow(`UPDATE bookmark_collections SET name = $1, updated_at = $2
+		WHERE id = $3 AND user_id = $4
+		RETURNING `+collectionColumns,
+		c.Name, time.Now(), c.ID, c.UserId))
+	if isUniqueViolation(err) {
+		return ErrCollectionExists
+	}
+	return err
+}
+
+// DeleteCollection deletes the collection with its bookmarks. It returns
+// sql.ErrNoRows when the user has no such collection.
+func (c *Collection) DeleteCollection(db *sql.DB) error {
+	res, err := db.Exec("DELETE FROM bookmark_collections WHERE id = $1 AND user_id = $2", c.ID, c.UserId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AddBookmark adds a post or product to the user's collection. Adding an item
+// that is already there returns the existing bookmark. It returns
+// sql.ErrNoRows when the collection is not the user's or the item cannot be
+// seen.
+func (b *Bookmark) AddBookmark(db *sql.DB, userID string) error {
+	if (b.PostId == nil) == (b.ProductId == nil) {
+		return ErrBookmarkItem
+	}
+
+	var visible bool
+	var err error
+	if b.PostId != nil {
+		post := Post{ID: *b.PostId}
+		err = post.GetPost(db, userID)
+		visible = err == nil
+	} else {
+		product := Product{ID: *b.ProductId}
+		err = product.GetProduct(db, userID)
+		visible = err == nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if !visible {
+		return sql.ErrNoRows
+	}
+
+	var createdAt time.Time
+	err = db.QueryRow(`WITH added AS (
+			INSERT INTO bookmarks(id, collection_id, user_id, post_id, product_id, created_at)
+			SELECT $1, id, user_id, $4, $5, $6 FROM bookmark_collections WHERE id = $2 AND user_id = $3
+			ON CONFLICT DO NOTHING
+			RETURNING id, created_at)
+		SELECT id, created_at FROM added
+		UNION ALL
+		SELECT id, created_at FROM bookmarks
+		WHERE collection_id = $2 AND user_id = $3
+			AND (post_id = $4 OR product_id = $5)`,
+		uuid.New().String(), b.CollectionId, userID, b.PostId, b.ProductId, time.Now()).Scan(&b.ID, &createdAt)
+	if err != nil {
+		return err
+	}
+
+	b.CreatedAt = createdAt.UTC().Add(time.Hour * 7)
+	return nil
+}
+
+// RemoveBookmark takes a post or product out of the user's collection. It
+// returns sql.ErrNoRows when it was not there.
+func (b *Bookmark) RemoveBookmark(db *sql.DB, userID string) error {
+	res, err := db.Exec(`DELETE FROM bookmarks WHERE collection_id = $1 AND user_id = $2
+		AND (post_id = $3 OR product_id = $4)`, b.CollectionId, userID, b.PostId, b.ProductId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetBookmarks lists the user's bookmarks, newest first, optionally limited to
+// one collection. Pages are read after cursor, the NextCursor of the previous
+// page.
+func GetBookmarks(db *sql.DB, userID, collectionID string, cursor *Cursor, limit int) (PayloadBookmarks, error) {
+	result := PayloadBookmarks{Data: []Bookmark{}}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var after time.Time
+	var afterID string
+	if cursor != nil {
+		after, afterID = cursor.CreatedAt, cursor.ID
+	}
+
+	rows, err := db.Query(`SELECT bookmarks.id, bookmarks.collection_id, bookmarks.post_id, bookmarks.product_id,
+			bookmarks.created_at
+		FROM bookmarks
+		WHERE bookmarks.user_id = $1 AND (NULLIF($2, '') IS NULL OR bookmarks.collection_id = $2)
+			AND ($3 = '' OR (bookmarks.created_at, bookmarks.id) < ($4, $3))
+			AND `+bookmarkVisible+`
+		ORDER BY bookmarks.created_at DESC, bookmarks.id DESC LIMIT $5`,
+		userID, collectionID, afterID, after, limit+1)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	var last Cursor
+	for rows.Next() {
+		var b Bookmark
+		if err := rows.Scan(&b.ID, &b.CollectionId, &b.PostId, &b.ProductId, &b.CreatedAt); err != nil {
+			return result, err
+		}
+
+		if len(result.Data) == limit {
+			result.NextCursor = last.String()
+			break
+		}
+
+		last = Cursor{CreatedAt: b.CreatedAt, ID: b.ID}
+		b.CreatedAt = b.CreatedAt.UTC().Add(time.Hour * 7)
+		result.Data = append(result.Data, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	return result, loadBookmarkItems(db, result.Data, userID)
+}
+
+// loadBookmarkItems fills in the post or product of each bookmark.
+func loadBookmarkItems(db *sql.DB, bookmarks []Bookmark, viewerID string) error {
+	var postIDs, productIDs []string
+	for _, b := range bookmarks {
+		if b.PostId != nil {
+			postIDs = append(postIDs, *b.PostId)
+		} else {
+			productIDs = append(productIDs, *b.ProductId)
+		}
+	}
+
+	posts := map[string]*Post{}
+	if len(postIDs) > 0 {
+		rows, err := db.Query(`SELECT `+postColumns+` FROM posts WHERE id = ANY($1)`, pq.Array(postIDs))
+		if err != nil {
+			return err
+		}
+
+		var found []Post
+		for rows.Next() {
+			var p Post
+			if err := p.scan(rows); err != nil {
+				rows.Close()
+				return err
+			}
+			p.BookmarkedByMe = true
+			found = append(found, p)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if err := loadRelations(db, found, viewerID); err != nil {
+			return err
+		}
+
+		for i := range found {
+			posts[found[i].ID] = &found[i]
+		}
+	}
+
+	products := map[string]*Product{}
+	if len(productIDs) > 0 {
+		rows, err := db.Query(`SELECT id, name, price, created_at, updated_at FROM products WHERE id = ANY($1)`,
+			pq.Array(productIDs))
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			p := &Product{BookmarkedByMe: true}
+			if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt, &p.UpdatedAt); err != nil {
+				return err
+			}
+			p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
+			p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
+			products[p.ID] = p
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for i := range bookmarks {
+		if bookmarks[i].PostId != nil {
+			bookmarks[i].Post = posts[*bookmarks[i].PostId]
+		} else {
+			bookmarks[i].Product = products[*bookmarks[i].ProductId]
+		}
+	}
+
+	return nil
+}
@@ -2,142 +2,444 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Comment struct {
-	ID        string    `json:"id" validate:"omitempty"`
-	PostId    string    `json:"post_id" validate:"required"`
-	UserId    string    `json:"user_id" validate:"omitempty"`
-	Content   string    `json:"content" validate:"required"`
-	CommentId *string   `json:"comment_id" validate:"omitempty"`
-	HasChild  bool      `json:"has_child"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID      string `json:"id" validate:"omitempty"`
+	PostId  string `json:"post_id" validate:"required"`
+	UserId  string `json:"user_id" validate:"omitempty"`
+	Content string `json:"content" validate:"required"`
+	// ContentHTML is Content rendered from Markdown and sanitized.
+	ContentHTML string  `json:"content_html"`
+	CommentId   *string `json:"comment_id" validate:"omitempty"`
+	// Status is "visible", "pending" while the post's author has not
+	// approved the comment, or "hidden" by the post's author or a moderator.
+	Status string `json:"status"`
+	// Edited is set once the comment has been changed; its earlier
+	// versions are kept in its history.
+	Edited    bool `json:"edited"`
+	EditCount int  `json:"edit_count"`
+	// ReplyCount is the number of direct replies shown, tombstones included.
+	ReplyCount int `json:"reply_count"`
+	// Deleted marks a tombstone: a deleted comment kept in its thread because
+	// it has replies, shown without its content and author.
+	Deleted bool `json:"deleted,omitempty"`
+	// Replies are filled in by GetCommentTree. RepliesTruncated is set when
+	// the tree hit its size limit before all of them were included.
+	Replies          []Comment  `json:"replies,omitempty"`
+	RepliesTruncated bool       `json:"replies_truncated,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
 }
 
 type PayloadComments struct {
 	Data      []Comment `json:"data"`
 	TotalData int       `json:"total_data"`
+	// NextCursor reads the page after this one, for the newest and
+	// oldest sorts. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
-func (p *Comment) GetComment(db *sql.DB) error {
-	err := db.QueryRow("SELECT * FROM comments WHERE id=$1", p.ID).Scan(&p.ID, &p.PostId, &p.UserId, &p.Content, &p.CommentId, &p.CreatedAt, &p.UpdatedAt)
+// CommentQuery selects a page of comments: the page after Cursor when it is
+// set, or the page in Params otherwise.
+type CommentQuery struct {
+	// ViewerID is who the comments are listed for, empty when anonymous.
+	ViewerID string
+	Sort     string
+	Cursor   *Cursor
+	Params   Params
+}
+
+var ErrCursorSort = errors.New("cursor paging is only available for the newest and oldest sorts")
+
+// commentColumns are the columns read by scan, in the same order.
+const commentColumns = `comments.id, comments.post_id, comments.user_id, comments.content, comments.parent_id,
+	` + replyCount + `, comments.status, comments.edit_count, comments.created_at, comments.updated_at,
+	comments.deleted_at`
+
+// replyCount counts the direct replies of a comment that everyone can see,
+// tombstones included.
+const replyCount = `(SELECT COUNT(*) FROM comments replies
+	WHERE replies.parent_id = comments.id AND replies.status = 'visible'
+		AND (replies.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments nested WHERE nested.parent_id = replies.id)))`
+
+// commentOnPost matches comments whose post is not deleted and is published,
+// or belongs to viewer.
+func commentOnPost(viewer string) string {
+	return `EXISTS (SELECT 1 FROM posts WHERE posts.id = comments.post_id AND posts.deleted_at IS NULL
+		AND (posts.status = 'published' OR posts.user_id = ` + viewer + `))`
+}
+
+// liveComment matches comments that are not deleted and whose post viewer
+// may see.
+func liveComment(viewer string) string {
+	return `comments.deleted_at IS NULL AND ` + commentOnPost(viewer)
+}
 
+// shownComment matches the comments that are read: live ones and deleted
+// ones that still have replies, shown as tombstones.
+func shownComment(viewer string) string {
+	return `(comments.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments child WHERE child.parent_id = comments.id))
+	AND ` + commentOnPost(viewer)
+}
+
+// DeletedCommentContent replaces the content of tombstones.
+const DeletedCommentContent = "[deleted]"
+
+// scan reads a row selected with commentColumns, followed by any extra
+// columns.
+func (p *Comment) scan(row rowScanner, extra ...interface{}) error {
+	dest := []interface{}{&p.ID, &p.PostId, &p.UserId, &p.Content, &p.CommentId, &p.ReplyCount, &p.Status,
+		&p.EditCount, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	p.Edited = p.EditCount > 0
+	p.ContentHTML = markdownCache.Render(p.Content)
 	p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
 	p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
+	if p.DeletedAt != nil {
+		deletedAt := p.DeletedAt.UTC().Add(time.Hour * 7)
+		p.DeletedAt = &deletedAt
+	}
+
+	return nil
+}
+
+// tombstone strips a deleted comment down to its place in the thread.
+func (p *Comment) tombstone() {
+	if p.DeletedAt == nil {
+		return
+	}
+
+	p.Deleted = true
+	p.UserId = ""
+	p.Content = DeletedCommentContent
+	p.ContentHTML = markdownCache.Render(DeletedCommentContent)
+}
+
+// GetComment loads the comment when viewerID may see it. A deleted comment
+// that still has replies is loaded as a tombstone.
+func (p *Comment) GetComment(db *sql.DB, viewerID string) error {
+	moderator, err := IsModerator(db, viewerID)
+	if err != nil {
+		return err
+	}
 
+	err = p.scan(db.QueryRow("SELECT "+commentColumns+" FROM comments WHERE id=$1 AND "+shownComment("$2")+
+		" AND "+commentVisibleTo("$2", "$3"), p.ID, viewerID, moderator))
 	if err != nil {
 		return err
 	}
+
+	p.tombstone()
 	return nil
 }
 
-func (p *Comment) GetAllCommentByPost(db *sql.DB) (PayloadComments, error) {
-	var result PayloadComments
-	query := fmt.Sprintf(`
-	SELECT * FROM comments
-	WHERE post_id = '%s'
-	`, p.PostId)
+// checkPostShown returns sql.ErrNoRows when the post is deleted, or is not
+// published and not viewerID's own, so its comments are not listed.
+func checkPostShown(db *sql.DB, postID, viewerID string) error {
+	var exists bool
+	return db.QueryRow(`SELECT true FROM posts
+		WHERE id = $1 AND deleted_at IS NULL AND (status = 'published' OR user_id = $2)`, postID, viewerID).
+		Scan(&exists)
+}
+
+// GetAllCommentByPost lists a page of the top-level comments of the post.
+// Replies are counted in ReplyCount and listed by GetReplies. It returns
+// sql.ErrNoRows when q.ViewerID may not see the post.
+func (p *Comment) GetAllCommentByPost(db *sql.DB, q CommentQuery) (PayloadComments, error) {
+	if err := checkPostShown(db, p.PostId, q.ViewerID); err != nil {
+		return PayloadComments{}, err
+	}
+
+	return listComments(db, "comments.post_id = $1 AND comments.parent_id IS NULL", p.PostId, q)
+}
 
-	rows, err := db.Query(query)
+// GetReplies lists a page of the direct replies to the comment. It returns
+// sql.ErrNoRows when the comment does not exist or is deleted.
+func (p *Comment) GetReplies(db *sql.DB, q CommentQuery) (PayloadComments, error) {
+	if err := p.GetComment(db, q.ViewerID); err != nil {
+		return PayloadComments{}, err
+	}
+
+	return listComments(db, "comments.parent_id = $1", p.ID, q)
+}
+
+// listComments reads a page of the comments matching where, which compares
+// against id as $1, that q.ViewerID may see. Tombstones are included.
+func listComments(db *sql.DB, where, id string, q CommentQuery) (PayloadComments, error) {
+	result := PayloadComments{Data: []Comment{}}
+	moderator, err := IsModerator(db, q.ViewerID)
 	if err != nil {
-		log.Fatal(err.Error())
 		return result, err
 	}
 
-	count := fmt.Sprintf(`
-	SELECT COUNT(*) FROM comments WHERE post_id = '%s'`,
-		p.PostId)
+	if q.Sort == "" {
+		q.Sort = CommentSortOldest
+	}
+
+	limit, offset := q.Params.Paging()
+	var order, after string
+	switch q.Sort {
+	case CommentSortOldest:
+		order = "comments.created_at ASC, comments.id ASC"
+		after = "(comments.created_at, comments.id) > ($5, $4)"
+	case CommentSortNewest:
+		order = "comments.created_at DESC, comments.id DESC"
+		after = "(comments.created_at, comments.id) < ($5, $4)"
+	case CommentSortTop:
+		if q.Cursor != nil {
+			return result, ErrCursorSort
+		}
+		order = replyCount + " DESC, comments.created_at DESC, comments.id DESC"
+	default:
+		return result, ErrInvalidSort
+	}
+
+	where += " AND " + shownComment("$2") + " AND " + commentVisibleTo("$2", "$3")
+	filter := where
+	args := []interface{}{id, q.ViewerID, moderator}
+	if q.Cursor != nil {
+		filter += " AND " + after
+		args = append(args, q.Cursor.ID, q.Cursor.CreatedAt)
+		offset = 0
+	}
 
-	if err := db.QueryRow(count).Scan(&result.TotalData); err != nil {
+	query := fmt.Sprintf(`SELECT %s, comments.created_at FROM comments WHERE %s
+		ORDER BY %s LIMIT $%d OFFSET $%d`, commentColumns, filter, order, len(args)+1, len(args)+2)
+	rows, err := db.Query(query, append(args, limit+1, offset)...)
+	if err != nil {
 		return result, err
 	}
 
 	defer rows.Close()
 
-	var comments []Comment
+	count := "SELECT COUNT(*) FROM comments WHERE " + where
+	if err := db.QueryRow(count, args[:3]...).Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	var last Cursor
 	for rows.Next() {
 		var c Comment
-		if err := rows.Scan(&c.ID, &c.PostId, &c.UserId, &c.Content, &c.CommentId, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		var createdAt time.Time
+		if err := c.scan(rows, &createdAt); err != nil {
 			return result, err
 		}
 
-		var child string
-		err := db.QueryRow("SELECT id FROM comments WHERE parent_id = $1", c.ID).Scan(&child)
-
-		c.HasChild = true
-		if err != nil && err == sql.ErrNoRows {
-			c.HasChild = false
+		if len(result.Data) == limit {
+			if q.Sort != CommentSortTop {
+				result.NextCursor = last.String()
+			}
+			break
 		}
 
-		c.CreatedAt = c.CreatedAt.UTC().Add(time.Hour * 7)
-		c.UpdatedAt = c.UpdatedAt.UTC().Add(time.Hour * 7)
-
-		if c.CommentId != nil {
-			continue
-		}
-
-		comments = append(comments, c)
+		last = Cursor{CreatedAt: createdAt, ID: c.ID}
+		c.tombstone()
+		result.Data = append(result.Data, c)
 	}
 
-	result.Data = comments
-	return result, nil
+	return result, rows.Err()
 }
 
+// CreateComment adds the comment to a post that has not been deleted and is
+// published, or is the commenter's own. It returns sql.ErrNoRows when there
+// is no such post, and ErrCommentsLocked
+// or ErrParentComment when the comment is not allowed there. On posts that
+// require approval, comments by others than the post's author start out
+// pending.
 func (p *Comment) CreateComment(db *sql.DB) error {
-	err := db.QueryRow(`INSERT INTO comments(id, post_id, user_id, content, parent_id, created_at, updated_at)
-		VALUES($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, post_id, user_id, content, parent_id, created_at, updated_at`,
-		uuid.New().String(), p.PostId, p.UserId, p.Content, p.CommentId, time.Now(), time.Now(),
-	).Scan(&p.ID, &p.PostId, &p.UserId, &p.Content, &p.CommentId, &p.CreatedAt, &p.UpdatedAt)
+	if err := p.checkCanComment(db); err != nil {
+		return err
+	}
 
-	p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
-	p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
 
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow(`INSERT INTO comments(id, post_id, user_id, content, parent_id, status, created_at, updated_at)
+		SELECT $1, posts.id, $3, $4, $5,
+			CASE WHEN posts.comment_approval AND posts.user_id <> $3 THEN 'pending' ELSE 'visible' END, $6, $7
+		FROM posts
+		WHERE posts.id = $2 AND posts.deleted_at IS NULL AND (posts.status = 'published' OR posts.user_id = $3)
+		RETURNING `+commentColumns,
+		uuid.New().String(), p.PostId, p.UserId, p.Content, p.CommentId, time.Now(), time.Now(),
+	))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := p.syncEntities(tx); err != nil {
+		return err
+	}
+
+	if err := p.notifyComment(tx, true); err != nil {
+		return err
+	}
+
+	if p.Status == CommentStatusVisible {
+		if err := announceComment(tx, CommentCreated, p.PostId, p.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := p.emitCommentWebhook(tx, WebhookCommentCreated, p.UserId, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// UpdateComment changes the content of the author's comment, keeping the
+// previous content in its history. It returns sql.ErrNoRows when the
+// author has no such live comment and ErrEditWindow once CommentEditWindow
+// has passed since it was posted.
 func (p *Comment) UpdateComment(db *sql.DB) error {
-	err := db.QueryRow(`UPDATE comments SET content=$1, updated_at=$2
-		WHERE id=$3 AND user_id=$4
-		RETURNING id, post_id, user_id, content, parent_id, created_at, updated_at`,
-		p.Content, time.Now(), p.ID, p.UserId,
-	).Scan(&p.ID, &p.PostId, &p.UserId, &p.Content, &p.CommentId, &p.CreatedAt, &p.UpdatedAt)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
 
-	p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
-	p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
+	defer tx.Rollback()
 
+	var previous Comment
+	err = tx.QueryRow(`SELECT content, edit_count, created_at, updated_at FROM comments
+		WHERE id=$1 AND user_id=$2 AND `+liveComment("$2")+` FOR UPDATE`, p.ID, p.UserId).
+		Scan(&previous.Content, &previous.EditCount, &previous.CreatedAt, &previous.UpdatedAt)
 	if err != nil {
-		fmt.Println(err.Error())
 		return err
 	}
 
-	return nil
+	if CommentEditWindow > 0 && time.Since(previous.CreatedAt) > CommentEditWindow {
+		return ErrEditWindow
+	}
+
+	if previous.Content == p.Content {
+		return p.scan(tx.QueryRow(`SELECT `+commentColumns+` FROM comments WHERE id=$1`, p.ID))
+	}
+
+	if err := recordCommentRevision(tx, p.ID, p.UserId, previous); err != nil {
+		return err
+	}
+
+	err = p.scan(tx.QueryRow(`UPDATE comments SET content=$1, updated_at=$2, edit_count = edit_count + 1
+		WHERE id=$3
+		RETURNING `+commentColumns,
+		p.Content, time.Now(), p.ID,
+	))
+	if err != nil {
+		return err
+	}
+
+	if err := p.syncEntities(tx); err != nil {
+		return err
+	}
+
+	if p.Status == CommentStatusVisible {
+		if err := announceComment(tx, CommentUpdated, p.PostId, p.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := p.emitCommentWebhook(tx, WebhookCommentUpdated, p.UserId, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// syncEntities stores the hashtags and mentions found in the content.
+func (p *Comment) syncEntities(tx *sql.Tx) error {
+	if err := syncCommentTags(tx, p.ID, p.Content); err != nil {
+		return err
+	}
+
+	return syncMentions(tx, p.UserId, p.PostId, &p.ID, p.Content, p.Status == CommentStatusVisible)
 }
 
+// DeleteComment removes the comment. A comment with replies becomes a
+// tombstone instead, which keeps the thread together and stays in its
+// author's trash; tombstones left without replies are removed with it. The
+// post's author and moderators may delete it too; the comment's author
+// cannot restore a comment deleted that way.
 func (p *Comment) DeleteComment(db *sql.DB) error {
-	res, err := db.Exec("DELETE FROM comments WHERE id=$1 AND user_id=$2", p.ID, p.UserId)
+	moderator, err := IsModerator(db, p.UserId)
 	if err != nil {
 		return err
 	}
 
-	n, err := res.RowsAffected()
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 
-	if n == 0 {
+	defer tx.Rollback()
+
+	var postID, authorID, status string
+	var parentID *string
+	var hasReplies bool
+	err = tx.QueryRow(`SELECT post_id, user_id, status, parent_id, EXISTS (SELECT 1 FROM comments child WHERE child.parent_id = comments.id)
+		FROM comments WHERE id=$1 AND deleted_at IS NULL
+			AND (user_id=$2 OR $3 OR EXISTS (SELECT 1 FROM posts WHERE posts.id = comments.post_id AND posts.user_id = $2))
+		FOR UPDATE`, p.ID, p.UserId, moderator).Scan(&postID, &authorID, &status, &parentID, &hasReplies)
+	if err == sql.ErrNoRows {
 		return fmt.Errorf("Not found !")
 	}
+	if err != nil {
+		return err
+	}
 
+	if hasReplies {
+		_, err = tx.Exec("UPDATE comments SET deleted_at=$1, deleted_by=$3 WHERE id=$2", time.Now(), p.ID, p.UserId)
+	} else {
+		_, err = tx.Exec("DELETE FROM comments WHERE id=$1", p.ID)
+		if err == nil {
+			err = removeEmptyTombstones(tx, parentID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if status == CommentStatusVisible {
+		if err := announceComment(tx, CommentDeleted, postID, p.ID); err != nil {
+			return err
+		}
+	}
+
+	p.PostId = postID
+	err = p.emitCommentWebhook(tx, WebhookCommentDeleted, authorID, map[string]string{"id": p.ID, "post_id": postID})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// removeEmptyTombstones removes the tombstone parentID, and then its own
+// tombstone parents, once their last reply is gone.
+func removeEmptyTombstones(tx *sql.Tx, parentID *string) error {
+	for parentID != nil {
+		err := tx.QueryRow(`DELETE FROM comments WHERE id=$1 AND deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM comments child WHERE child.parent_id = comments.id)
+			RETURNING parent_id`, *parentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
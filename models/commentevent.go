@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// CommentEventChannel is the Postgres channel comment changes are announced
+// on, so every server instance can pass them on to its own clients.
+const CommentEventChannel = "comment_events"
+
+const (
+	CommentCreated = "comment.created"
+	CommentUpdated = "comment.updated"
+	// CommentDeleted is sent when a comment is deleted, becomes a tombstone
+	// or is hidden by a moderator.
+	CommentDeleted = "comment.deleted"
+)
+
+// CommentEvent announces a change to a comment that everyone can see. It
+// only carries ids: NOTIFY payloads are limited to 8000 bytes, so receivers
+// read the comment itself.
+type CommentEvent struct {
+	Type      string `json:"type"`
+	PostID    string `json:"post_id"`
+	CommentID string `json:"comment_id"`
+}
+
+// announceComment sends the event through NOTIFY. Sent within a
+// transaction, it is only delivered once the transaction commits.
+func announceComment(q execer, kind, postID, commentID string) error {
+	payload, err := json.Marshal(CommentEvent{Type: kind, PostID: postID, CommentID: commentID})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("SELECT pg_notify($1, $2)", CommentEventChannel, string(payload))
+	return err
+}
+
+// ActivityEventChannel is the Postgres channel events for one user, such as
+// comments on their posts, are announced on.
+const ActivityEventChannel = "activity_events"
+
+// ActivityEvent tells UserID about a comment. IDs come from a sequence
+// shared by every server instance, so clients can resume from the last
+// one they saw on any instance.
+type ActivityEvent struct {
+	ID        int64  `json:"id"`
+	UserID    string `json:"user_id"`
+	Type      string `json:"type"`
+	PostID    string `json:"post_id"`
+	CommentID string `json:"comment_id"`
+}
+
+// LastActivityEventID returns the latest id handed out to an event.
+func LastActivityEventID(db *sql.DB) (int64, error) {
+	var id int64
+	err := db.QueryRow(`SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM activity_events_id_seq`).Scan(&id)
+	return id, err
+}
+
+// announceActivity sends an ActivityEvent of kind, such as
+// NotificationComment, through NOTIFY.
+func announceActivity(q execer, userID, kind, postID, commentID string) error {
+	_, err := q.Exec(`SELECT pg_notify($1, json_build_object('id', nextval('activity_events_id_seq'),
+		'user_id', $2::text, 'type', $3::text, 'post_id', $4::text, 'comment_id', $5::text)::text)`,
+		ActivityEventChannel, userID, kind, postID, commentID)
+	return err
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Who may still comment on a post with locked comments, besides its author
+// and moderators.
+const (
+	CommentLockEveryone  = "everyone"
+	CommentLockFollowers = "followers"
+)
+
+var (
+	ErrCommentsLocked = errors.New("Comments on this post are locked")
+	ErrParentComment  = errors.New("Parent comment not found on this post")
+)
+
+// checkCanComment returns sql.ErrNoRows when the post does not exist or is
+// someone else's unpublished post, ErrParentComment when the comment replies
+// to a comment of another post and ErrCommentsLocked when the post's lock
+// keeps the commenter out. The lock is checked on the post, so it covers
+// replies at any depth.
+func (p *Comment) checkCanComment(db *sql.DB) error {
+	var owner string
+	var lock *string
+	err := db.QueryRow(`SELECT user_id, comments_locked FROM posts
+		WHERE id = $1 AND deleted_at IS NULL AND (status = 'published' OR user_id = $2)`, p.PostId, p.UserId).
+		Scan(&owner, &lock)
+	if err != nil {
+		return err
+	}
+
+	if p.CommentId != nil {
+		var parentPost string
+		err := db.QueryRow("SELECT post_id FROM comments WHERE id = $1 AND deleted_at IS NULL", *p.CommentId).
+			Scan(&parentPost)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if parentPost != p.PostId {
+			return ErrParentComment
+		}
+	}
+
+	if lock == nil || owner == p.UserId {
+		return nil
+	}
+
+	moderator, err := IsModerator(db, p.UserId)
+	if err != nil || moderator {
+		return err
+	}
+
+	if *lock == CommentLockFollowers {
+		following, err := IsFollowing(db, p.UserId, owner)
+		if err != nil || following {
+			return err
+		}
+	}
+
+	return ErrCommentsLocked
+}
+
+// LockComments stops new comments on the post from anyone but its author
+// and moderators, or with CommentLockFollowers from anyone who does not
+// follow its author. Existing comments stay readable.
+func (p *Post) LockComments(db *sql.DB, actorID, mode string) error {
+	return p.setCommentLock(db, actorID, &mode)
+}
+
+func (p *Post) UnlockComments(db *sql.DB, actorID string) error {
+	return p.setCommentLock(db, actorID, nil)
+}
+
+// setCommentLock lets the post's author or a moderator change the lock. A
+// lock set by a moderator can only be changed by a moderator.
+func (p *Post) setCommentLock(db *sql.DB, actorID string, mode *string) error {
+	var owner string
+	var lockedBy *string
+	err := db.QueryRow("SELECT user_id, comments_locked_by FROM posts WHERE id = $1 AND deleted_at IS NULL", p.ID).
+		Scan(&owner, &lockedBy)
+	if err != nil {
+		return err
+	}
+
+	moderator, err := IsModerator(db, actorID)
+	if err != nil {
+		return err
+	}
+
+	forced := lockedBy != nil && *lockedBy != owner
+	if !moderator && (owner != actorID || forced) {
+		return ErrUnauthorized
+	}
+
+	var by *string
+	if mode != nil {
+		by = &actorID
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow("UPDATE posts SET comments_locked = $2, comments_locked_by = $3 WHERE id = $1 RETURNING "+postColumns,
+		p.ID, mode, by))
+	if err != nil {
+		return err
+	}
+
+	published := p.Status == PostStatusPublished
+	if err := p.emitPostWebhook(tx, published, published); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.GetPost(db, actorID)
+}
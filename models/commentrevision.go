@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommentEditWindow is how long after posting a comment can be edited. Zero
+// means comments can always be edited.
+var CommentEditWindow time.Duration
+
+var ErrEditWindow = errors.New("Comment can no longer be edited")
+
+// CommentRevision is one version of a comment's content. CreatedAt is when
+// that version was written.
+type CommentRevision struct {
+	Revision  int       `json:"revision"`
+	Content   string    `json:"content"`
+	EditorId  string    `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// recordCommentRevision keeps previous, the content being replaced, as the
+// next revision of the comment. The caller must hold a row lock on it.
+func recordCommentRevision(tx *sql.Tx, commentID, editorID string, previous Comment) error {
+	_, err := tx.Exec(`INSERT INTO comment_revisions(id, comment_id, revision, content, editor_id, created_at)
+		VALUES($1, $2, $3, $4, $5, $6)`,
+		uuid.New().String(), commentID, previous.EditCount+1, previous.Content, editorID, previous.UpdatedAt)
+	return err
+}
+
+// GetHistory lists every version of the comment, oldest first, ending with
+// the current one. Its author, the post's author and moderators may read
+// it, of tombstones too.
+func (p *Comment) GetHistory(db *sql.DB, viewerID string) ([]CommentRevision, error) {
+	var owner string
+	err := p.scan(db.QueryRow(`SELECT `+commentColumns+`, posts.user_id FROM comments
+		JOIN posts ON posts.id = comments.post_id
+		WHERE comments.id = $1 AND posts.deleted_at IS NULL`, p.ID), &owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.UserId != viewerID && owner != viewerID {
+		moderator, err := IsModerator(db, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		if !moderator {
+			return nil, ErrUnauthorized
+		}
+	}
+
+	rows, err := db.Query(`SELECT revision, content, editor_id, created_at FROM comment_revisions
+		WHERE comment_id = $1 ORDER BY revision`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []CommentRevision{}
+	for rows.Next() {
+		var r CommentRevision
+		if err := rows.Scan(&r.Revision, &r.Content, &r.EditorId, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		r.CreatedAt = r.CreatedAt.UTC().Add(time.Hour * 7)
+		revisions = append(revisions, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return append(revisions, CommentRevision{
+		Revision:  p.EditCount + 1,
+		Content:   p.Content,
+		EditorId:  p.UserId,
+		CreatedAt: p.UpdatedAt,
+	}), nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{CreatedAt: time.Date(2026, 10, 19, 9, 0, 0, 123456789, time.UTC), ID: "abc"}
+
+	parsed, err := ParseCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !parsed.CreatedAt.Equal(c.CreatedAt) || parsed.ID != c.ID {
+		t.Errorf("Expected %v. Got %v", c, parsed)
+	}
+
+	if start, err := ParseCursor(""); start != nil || err != nil {
+		t.Errorf("Expected the empty cursor to start the list. Got %v, %v", start, err)
+	}
+
+	for _, invalid := range []string{"!!", "MTIz", "YWJjLGRlZg"} {
+		if _, err := ParseCursor(invalid); err != ErrInvalidCursor {
+			t.Errorf("Expected %q to be rejected. Got %v", invalid, err)
+		}
+	}
+}
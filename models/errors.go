@@ -0,0 +1,17 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrUnauthorized is returned when the user may not act on a resource. Its
+// message matches the one controllers already send for ownership checks.
+var ErrUnauthorized = errors.New("Unauthorized request!")
+
+// isUniqueViolation reports whether err comes from a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
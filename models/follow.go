@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrFollowSelf = errors.New("You cannot follow yourself")
+
+// Follow makes followerID follow followeeID. Following twice is a no-op;
+// sql.ErrNoRows means followeeID does not exist.
+func Follow(db *sql.DB, followerID, followeeID string) error {
+	if followerID == followeeID {
+		return ErrFollowSelf
+	}
+
+	res, err := db.Exec(`INSERT INTO user_follows(follower_id, followee_id, created_at)
+		SELECT $1, users.id, $3 FROM users WHERE users.id = $2
+		ON CONFLICT DO NOTHING`, followerID, followeeID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 1 {
+		return notify(db, notification{userID: followeeID, kind: NotificationFollow, groupKey: NotificationFollow,
+			actorID: followerID})
+	}
+
+	if n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", followeeID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+	return nil
+}
+
+func Unfollow(db *sql.DB, followerID, followeeID string) error {
+	_, err := db.Exec("DELETE FROM user_follows WHERE follower_id = $1 AND followee_id = $2", followerID, followeeID)
+	return err
+}
+
+// IsFollowing reports whether followerID follows followeeID.
+func IsFollowing(db *sql.DB, followerID, followeeID string) (bool, error) {
+	var following bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM user_follows WHERE follower_id = $1 AND followee_id = $2)`,
+		followerID, followeeID).Scan(&following)
+	return following, err
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// MaxPostLinks is how many links of a post get a preview.
+	MaxPostLinks  = 3
+	maxLinkLength = 2048
+)
+
+// LinkPreviewTTL is how long a fetched preview is used before a new post
+// linking to the same URL fetches it again.
+var LinkPreviewTTL = 7 * 24 * time.Hour
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// LinkPreview is the title, description and image of a linked page, read
+// from its OpenGraph or Twitter card tags.
+type LinkPreview struct {
+	ID          string  `json:"-"`
+	URL         string  `json:"url"`
+	FinalURL    *string `json:"-"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	ImageURL    *string `json:"image_url"`
+	SiteName    string  `json:"site_name"`
+}
+
+// ParseLinks returns the distinct http and https URLs in text in order of
+// first appearance, at most MaxPostLinks of them. Punctuation that ends a
+// sentence, and closing brackets without an opening one, are not part of
+// the URL.
+func ParseLinks(text string) []string {
+	var links []string
+	seen := map[string]bool{}
+
+	for _, link := range linkPattern.FindAllString(text, -1) {
+		for {
+			trimmed := strings.TrimRight(link, ".,;:!?*_~")
+			if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+			if strings.HasSuffix(trimmed, "]") && strings.Count(trimmed, "[") < strings.Count(trimmed, "]") {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+			if trimmed == link {
+				break
+			}
+			link = trimmed
+		}
+
+		if len(link) > maxLinkLength || strings.HasSuffix(link, "://") || seen[link] {
+			continue
+		}
+
+		seen[link] = true
+		links = append(links, link)
+		if len(links) == MaxPostLinks {
+			break
+		}
+	}
+
+	return links
+}
+
+// syncPostLinks makes the links of the post match those in text and queues
+// a fetch for every URL without a preview, or whose preview is older than
+// LinkPreviewTTL.
+func syncPostLinks(tx *sql.Tx, postID string, text string) error {
+	links := ParseLinks(text)
+
+	_, err := tx.Exec(`DELETE FROM post_links WHERE post_id = $1
+		AND preview_id NOT IN (SELECT id FROM link_previews WHERE url = ANY($2))`, postID, pq.Array(links))
+	if err != nil {
+		return err
+	}
+
+	for i, link := range links {
+		var previewID string
+		err := tx.QueryRow(`INSERT INTO link_previews(id, url, created_at) VALUES($1, $2, $3)
+			ON CONFLICT (url) DO UPDATE SET
+				status = CASE WHEN link_previews.status IN ('done', 'failed') AND link_previews.fetched_at < $4
+					THEN 'pending' ELSE link_previews.status END,
+				attempts = CASE WHEN link_previews.status IN ('done', 'failed') AND link_previews.fetched_at < $4
+					THEN 0 ELSE link_previews.attempts END,
+				next_attempt_at = CASE WHEN link_previews.status IN ('done', 'failed') AND link_previews.fetched_at < $4
+					THEN NOW() ELSE link_previews.next_attempt_at END
+			RETURNING id`, uuid.New().String(), link, time.Now(), time.Now().Add(-LinkPreviewTTL)).Scan(&previewID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO post_links(post_id, preview_id, position) VALUES($1, $2, $3)
+			ON CONFLICT (post_id, preview_id) DO UPDATE SET position = EXCLUDED.position`, postID, previewID, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadLinkPreviews fills in the fetched previews of each post's links.
+// Links still being fetched, or whose page had no title, are left out.
+func loadLinkPreviews(db *sql.DB, posts []Post) error {
+	var ids []string
+	for i := range posts {
+		posts[i].LinkPreviews = nil
+		ids = append(ids, posts[i].ID)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(`SELECT post_links.post_id, link_previews.url, link_previews.title,
+			link_previews.description, link_previews.image_url, link_previews.site_name
+		FROM post_links JOIN link_previews ON link_previews.id = post_links.preview_id
+		WHERE post_links.post_id = ANY($1) AND link_previews.title <> ''
+			AND (link_previews.status = 'done' OR link_previews.fetched_at IS NOT NULL)
+		ORDER BY post_links.position`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	previews := map[string][]LinkPreview{}
+	for rows.Next() {
+		var postID string
+		var lp LinkPreview
+		if err := rows.Scan(&postID, &lp.URL, &lp.Title, &lp.Description, &lp.ImageURL, &lp.SiteName); err != nil {
+			return err
+		}
+		previews[postID] = append(previews[postID], lp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].LinkPreviews = previews[posts[i].ID]
+	}
+
+	return nil
+}
+
+// ClaimLinkPreviews marks up to limit queued links as being fetched and
+// returns them, like ClaimImages does for images.
+func ClaimLinkPreviews(db *sql.DB, limit, maxAttempts int) ([]LinkPreview, error) {
+	_, err := db.Exec(`UPDATE link_previews SET status = 'failed', fetched_at = NOW(),
+			error = COALESCE(error, 'fetch timed out')
+		WHERE status = 'processing' AND next_attempt_at <= NOW() AND attempts >= $1`, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`UPDATE link_previews SET status = 'processing', attempts = attempts + 1,
+			next_attempt_at = NOW() + $3::float8 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM link_previews
+			WHERE status IN ('pending', 'processing') AND next_attempt_at <= NOW() AND attempts < $2
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+		RETURNING id, url`, limit, maxAttempts, ProcessingLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var previews []LinkPreview
+	for rows.Next() {
+		var lp LinkPreview
+		if err := rows.Scan(&lp.ID, &lp.URL); err != nil {
+			return nil, err
+		}
+		previews = append(previews, lp)
+	}
+
+	return previews, rows.Err()
+}
+
+// SaveLinkPreview stores the fetched metadata and marks the preview done.
+func (lp *LinkPreview) SaveLinkPreview(db *sql.DB) error {
+	_, err := db.Exec(`UPDATE link_previews SET final_url = $2, title = $3, description = $4, image_url = $5,
+			site_name = $6, status = 'done', error = NULL, fetched_at = NOW()
+		WHERE id = $1`, lp.ID, lp.FinalURL, lp.Title, lp.Description, lp.ImageURL, lp.SiteName)
+	return err
+}
+
+// FailLinkPreview records a failed fetch. It is retried after backoff,
+// doubled for every earlier attempt, until maxAttempts is reached. A
+// preview that was fetched before keeps its old metadata.
+func (lp *LinkPreview) FailLinkPreview(db *sql.DB, reason string, maxAttempts int, backoff time.Duration) error {
+	_, err := db.Exec(`UPDATE link_previews SET error = $2,
+			status = CASE WHEN attempts >= $3 THEN 'failed' ELSE 'pending' END,
+			fetched_at = CASE WHEN attempts >= $3 THEN NOW() ELSE fetched_at END,
+			next_attempt_at = NOW() + $4::float8 * POWER(2, attempts - 1) * INTERVAL '1 second'
+		WHERE id = $1`, lp.ID, reason, maxAttempts, backoff.Seconds())
+	return err
+}
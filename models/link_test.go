@@ -0,0 +1,15 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	got := ParseLinks("baca https://a.com/x. lalu (https://b.com/wiki/Go_(bahasa)) dan https://a.com/x, ftp://c.com http:// https://d.com/1 https://e.com")
+	expected := []string{"https://a.com/x", "https://b.com/wiki/Go_(bahasa)", "https://d.com/1"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected links %v. Got %v", expected, got)
+	}
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Mention struct {
+	ID             string    `json:"id"`
+	UserId         string    `json:"user_id"`
+	AuthorId       string    `json:"author_id"`
+	AuthorUsername string    `json:"author_username"`
+	PostId         string    `json:"post_id"`
+	CommentId      *string   `json:"comment_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type PayloadMentions struct {
+	Data      []Mention `json:"data"`
+	TotalData int       `json:"total_data"`
+}
+
+func GetMentions(db *sql.DB, userID string, params Params) (PayloadMentions, error) {
+	var result PayloadMentions
+	limit, offset := params.Paging()
+
+	rows, err := db.Query(`
+		SELECT m.id, m.user_id, m.author_id, u.username, m.post_id, m.comment_id, m.created_at
+		FROM mentions m
+		JOIN users u ON u.id = m.author_id
+		JOIN posts ON posts.id = m.post_id
+		LEFT JOIN comments ON comments.id = m.comment_id
+		WHERE m.user_id = $1 AND posts.status = 'published' AND posts.deleted_at IS NULL
+			AND comments.deleted_at IS NULL AND (m.comment_id IS NULL OR comments.status = 'visible')
+		ORDER BY m.created_at DESC LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	count := `SELECT COUNT(*) FROM mentions m
+		JOIN posts ON posts.id = m.post_id
+		LEFT JOIN comments ON comments.id = m.comment_id
+		WHERE m.user_id = $1 AND posts.status = 'published' AND posts.deleted_at IS NULL
+			AND comments.deleted_at IS NULL AND (m.comment_id IS NULL OR comments.status = 'visible')`
+	if err := db.QueryRow(count, userID).Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var m Mention
+		if err := rows.Scan(&m.ID, &m.UserId, &m.AuthorId, &m.AuthorUsername, &m.PostId, &m.CommentId, &m.CreatedAt); err != nil {
+			return result, err
+		}
+
+		m.CreatedAt = m.CreatedAt.UTC().Add(time.Hour * 7)
+		mentions = append(mentions, m)
+	}
+
+	result.Data = mentions
+	return result, nil
+}
+
+// syncMentions makes the mentions recorded for a post, or for one of its
+// comments when commentID is set, match the @usernames in text. Usernames
+// that do not belong to a user are ignored. New mentions are notified when
+// announce is set; comments that are not visible yet notify theirs once
+// approved.
+func syncMentions(tx *sql.Tx, authorID, postID string, commentID *string, text string, announce bool) error {
+	userIDs := []string{}
+
+	rows, err := tx.Query("SELECT id FROM users WHERE username = ANY($1)", pq.Array(ParseMentions(text)))
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+
+	if commentID == nil {
+		_, err = tx.Exec(`DELETE FROM mentions
+			WHERE post_id = $1 AND comment_id IS NULL AND NOT (user_id = ANY($2))`,
+			postID, pq.Array(userIDs))
+	} else {
+		_, err = tx.Exec(`DELETE FROM mentions
+			WHERE comment_id = $1 AND NOT (user_id = ANY($2))`,
+			*commentID, pq.Array(userIDs))
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		var res sql.Result
+		if commentID == nil {
+			res, err = tx.Exec(`INSERT INTO mentions(id, user_id, author_id, post_id, comment_id, created_at)
+				VALUES($1, $2, $3, $4, NULL, $5)
+				ON CONFLICT (post_id, user_id) WHERE comment_id IS NULL DO NOTHING`,
+				uuid.New().String(), userID, authorID, postID, time.Now())
+		} else {
+			res, err = tx.Exec(`INSERT INTO mentions(id, user_id, author_id, post_id, comment_id, created_at)
+				VALUES($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (comment_id, user_id) DO NOTHING`,
+				uuid.New().String(), userID, authorID, postID, *commentID, time.Now())
+		}
+		if err != nil {
+			return err
+		}
+
+		// Only new mentions are news; editing the text keeps the old ones.
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 || !announce {
+			continue
+		}
+
+		err = notify(tx, notification{userID: userID, kind: NotificationMention, groupKey: "mention:" + postID,
+			actorID: authorID, postID: &postID, commentID: commentID})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyMentions tells the users mentioned in the comment about it, for a
+// comment that was pending when its mentions were recorded.
+func (p *Comment) notifyMentions(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT user_id FROM mentions WHERE comment_id = $1", p.ID)
+	if err != nil {
+		return err
+	}
+
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		err := notify(tx, notification{userID: userID, kind: NotificationMention, groupKey: "mention:" + p.PostId,
+			actorID: p.UserId, postID: &p.PostId, commentID: &p.ID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
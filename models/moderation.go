@@ -0,0 +1,200 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	CommentStatusVisible = "visible"
+	CommentStatusPending = "pending"
+	CommentStatusHidden  = "hidden"
+)
+
+var ErrCommentStatus = errors.New("Comment cannot be moved to that status")
+
+// commentVisibleTo matches the comments a viewer may see: visible ones to
+// everyone, and pending or hidden ones only to their author, the post's
+// author and moderators. viewer and moderator are the placeholders holding
+// the viewer's id and whether the viewer is a moderator.
+func commentVisibleTo(viewer, moderator string) string {
+	return `(comments.status = 'visible' OR ` + moderator + `::boolean OR comments.user_id = ` + viewer + `
+		OR EXISTS (SELECT 1 FROM posts owned WHERE owned.id = comments.post_id AND owned.user_id = ` + viewer + `))`
+}
+
+// canModerateComments reports whether userID may moderate the comments of
+// the post: its author and moderators can.
+func canModerateComments(db *sql.DB, postID, userID string) (bool, error) {
+	var owner string
+	err := db.QueryRow("SELECT user_id FROM posts WHERE id = $1", postID).Scan(&owner)
+	if err != nil {
+		return false, err
+	}
+
+	if owner == userID {
+		return true, nil
+	}
+	return IsModerator(db, userID)
+}
+
+// HideComment hides a visible or pending comment from everyone but its
+// author, the post's author and moderators.
+func (p *Comment) HideComment(db *sql.DB, actorID string) error {
+	return p.moderate(db, actorID, CommentStatusHidden, CommentStatusVisible, CommentStatusPending)
+}
+
+// UnhideComment shows a hidden comment again.
+func (p *Comment) UnhideComment(db *sql.DB, actorID string) error {
+	return p.moderate(db, actorID, CommentStatusVisible, CommentStatusHidden)
+}
+
+// ApproveComment publishes a pending comment.
+func (p *Comment) ApproveComment(db *sql.DB, actorID string) error {
+	return p.moderate(db, actorID, CommentStatusVisible, CommentStatusPending)
+}
+
+// moderate moves the comment to status when it is in one of from. It
+// returns sql.ErrNoRows when the comment does not exist, ErrUnauthorized
+// when actorID may not moderate it and ErrCommentStatus when it is in
+// another status.
+func (p *Comment) moderate(db *sql.DB, actorID, status string, from ...string) error {
+	var current string
+	err := db.QueryRow("SELECT post_id, status FROM comments WHERE id = $1 AND "+liveComment("$2"), p.ID, actorID).
+		Scan(&p.PostId, &current)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := canModerateComments(db, p.PostId, actorID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrUnauthorized
+	}
+
+	valid := false
+	for _, s := range from {
+		valid = valid || s == current
+	}
+	if !valid {
+		return ErrCommentStatus
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow(`UPDATE comments SET status = $2, moderated_by = $3, moderated_at = $4
+		WHERE id = $1 AND status = $5
+		RETURNING `+commentColumns, p.ID, status, actorID, time.Now(), current))
+	if err == sql.ErrNoRows {
+		// Moderated by someone else in the meantime.
+		return ErrCommentStatus
+	}
+	if err != nil {
+		return err
+	}
+
+	// The post's author heard of the comment while it was pending; the
+	// author of the comment it replies to and the users it mentions only
+	// hear of it now.
+	if current == CommentStatusPending && status == CommentStatusVisible {
+		if err := p.notifyComment(tx, false); err != nil {
+			return err
+		}
+		if err := p.notifyMentions(tx); err != nil {
+			return err
+		}
+	}
+
+	// To everyone else the comment appears or goes away.
+	switch {
+	case status == CommentStatusVisible:
+		err = announceComment(tx, CommentCreated, p.PostId, p.ID)
+	case current == CommentStatusVisible:
+		err = announceComment(tx, CommentDeleted, p.PostId, p.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := p.emitCommentWebhook(tx, WebhookCommentUpdated, p.UserId, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetModerationQueue lists the comments with status, pending unless given,
+// on the posts of ownerID, optionally only those on postID, oldest first.
+func GetModerationQueue(db *sql.DB, ownerID, postID, status string, params Params) (PayloadComments, error) {
+	result := PayloadComments{Data: []Comment{}}
+	if status == "" {
+		status = CommentStatusPending
+	}
+
+	if status != CommentStatusPending && status != CommentStatusHidden {
+		return result, ErrCommentStatus
+	}
+
+	limit, offset := params.Paging()
+	where := `comments.status = $2 AND ` + liveComment("$1") + `
+		AND EXISTS (SELECT 1 FROM posts owned WHERE owned.id = comments.post_id AND owned.user_id = $1)
+		AND (NULLIF($3, '') IS NULL OR comments.post_id = $3)`
+
+	rows, err := db.Query(`SELECT `+commentColumns+` FROM comments WHERE `+where+`
+		ORDER BY comments.created_at, comments.id LIMIT $4 OFFSET $5`, ownerID, status, postID, limit, offset)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM comments WHERE `+where, ownerID, status, postID).
+		Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	for rows.Next() {
+		var c Comment
+		if err := c.scan(rows); err != nil {
+			return result, err
+		}
+		result.Data = append(result.Data, c)
+	}
+
+	return result, rows.Err()
+}
+
+// SetCommentApproval switches whether new comments on the post wait for
+// its author's approval. Comments already pending stay pending.
+func (p *Post) SetCommentApproval(db *sql.DB, required bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow(`UPDATE posts SET comment_approval = $3 WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		RETURNING `+postColumns, p.ID, p.UserId, required))
+	if err != nil {
+		return err
+	}
+
+	published := p.Status == PostStatusPublished
+	if err := p.emitPostWebhook(tx, published, published); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.GetPost(db, p.UserId)
+}
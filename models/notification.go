@@ -0,0 +1,306 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// NotificationComment tells an author about comments on their post.
+	NotificationComment = "comment"
+	// NotificationReply tells an author about replies to their comment.
+	NotificationReply   = "reply"
+	NotificationMention = "mention"
+	NotificationFollow  = "follow"
+	// NotificationRepost tells an author their post was reposted or quoted.
+	NotificationRepost = "repost"
+	// NotificationReport tells a reporter their report was closed.
+	NotificationReport = "report"
+)
+
+// NotificationTypes lists every type, in the order preferences are shown.
+var NotificationTypes = []string{NotificationComment, NotificationReply, NotificationMention,
+	NotificationFollow, NotificationRepost, NotificationReport}
+
+var ErrNotificationType = errors.New("Unknown notification type")
+
+// Notification groups the unread events of one type on the same subject,
+// such as every comment on a post since its author last read them.
+type Notification struct {
+	ID        string  `json:"id"`
+	Type      string  `json:"type"`
+	PostId    *string `json:"post_id,omitempty"`
+	CommentId *string `json:"comment_id,omitempty"`
+	ReportId  *string `json:"report_id,omitempty"`
+	// Actors are the usernames of the users behind the events, most
+	// recent first. ActorCount counts them all.
+	Actors     []string  `json:"actors"`
+	ActorCount int       `json:"actor_count"`
+	EventCount int       `json:"event_count"`
+	Message    string    `json:"message"`
+	Read       bool      `json:"read"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type PayloadNotifications struct {
+	Data        []Notification `json:"data"`
+	TotalData   int            `json:"total_data"`
+	UnreadCount int            `json:"unread_count"`
+}
+
+// maxNotificationActors is how many usernames a notification shows.
+const maxNotificationActors = 3
+
+// notification is an event to deliver to userID.
+type notification struct {
+	userID    string
+	kind      string
+	groupKey  string
+	actorID   string
+	postID    *string
+	commentID *string
+	reportID  *string
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// notify adds the event to the user's unread notification of the same
+// group, or starts a new one. Events users cause themselves and types the
+// user turned off are dropped.
+func notify(q execer, n notification) error {
+	if n.userID == "" || n.userID == n.actorID {
+		return nil
+	}
+
+	_, err := q.Exec(`INSERT INTO notifications(id, user_id, type, group_key, post_id, comment_id, report_id,
+			actor_ids, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, array_remove(ARRAY[NULLIF($8, '')::varchar], NULL), $9, $9
+		WHERE NOT EXISTS (SELECT 1 FROM notification_preferences
+			WHERE user_id = $2 AND type = $3 AND NOT enabled)
+		ON CONFLICT (user_id, group_key) WHERE read_at IS NULL DO UPDATE SET
+			actor_ids = array_cat(array_remove(notifications.actor_ids, NULLIF($8, '')::varchar), EXCLUDED.actor_ids),
+			event_count = notifications.event_count + 1,
+			comment_id = COALESCE(EXCLUDED.comment_id, notifications.comment_id),
+			updated_at = EXCLUDED.updated_at`,
+		uuid.New().String(), n.userID, n.kind, n.groupKey, n.postID, n.commentID, n.reportID, n.actorID, time.Now())
+	return err
+}
+
+// notifyComment tells the parent comment's author about a reply and, when
+// withOwner is set, the post's author about the comment. A pending comment
+// only reaches the post's author, who has to approve it.
+func (p *Comment) notifyComment(q interface {
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, withOwner bool) error {
+	var owner string
+	var parentAuthor *string
+	err := q.QueryRow(`SELECT posts.user_id, (SELECT user_id FROM comments parent WHERE parent.id = $2)
+		FROM posts WHERE posts.id = $1`, p.PostId, p.CommentId).Scan(&owner, &parentAuthor)
+	if err != nil {
+		return err
+	}
+
+	// Users are told through their notifications and, while connected,
+	// their activity stream.
+	deliver := func(userID, kind, groupKey string) error {
+		if userID == p.UserId {
+			return nil
+		}
+
+		err := notify(q, notification{userID: userID, kind: kind, groupKey: groupKey,
+			actorID: p.UserId, postID: &p.PostId, commentID: &p.ID})
+		if err != nil {
+			return err
+		}
+		return announceActivity(q, userID, kind, p.PostId, p.ID)
+	}
+
+	if p.Status == CommentStatusVisible && parentAuthor != nil {
+		if err := deliver(*parentAuthor, NotificationReply, "reply:"+*p.CommentId); err != nil {
+			return err
+		}
+	}
+
+	if !withOwner || (parentAuthor != nil && *parentAuthor == owner && p.Status == CommentStatusVisible) {
+		return nil
+	}
+
+	return deliver(owner, NotificationComment, "comment:"+p.PostId)
+}
+
+// notificationMessage describes the notification, e.g. "ana and 4 others
+// commented on your post".
+func notificationMessage(kind string, actors []string, count int) string {
+	var who string
+	switch {
+	case len(actors) == 0:
+		who = "Someone"
+	case count == 1:
+		who = actors[0]
+	case count == 2 && len(actors) > 1:
+		who = actors[0] + " and " + actors[1]
+	default:
+		who = fmt.Sprintf("%s and %d others", actors[0], count-1)
+	}
+
+	switch kind {
+	case NotificationComment:
+		return who + " commented on your post"
+	case NotificationReply:
+		return who + " replied to your comment"
+	case NotificationMention:
+		return who + " mentioned you"
+	case NotificationFollow:
+		return who + " followed you"
+	case NotificationRepost:
+		return who + " shared your post"
+	case NotificationReport:
+		return "Your report has been reviewed"
+	}
+	return ""
+}
+
+// notificationVisible leaves out notifications about posts that were
+// deleted or unpublished since.
+const notificationVisible = `(notifications.post_id IS NULL OR EXISTS (SELECT 1 FROM posts
+	WHERE posts.id = notifications.post_id AND posts.deleted_at IS NULL
+		AND (posts.status = 'published' OR posts.user_id = notifications.user_id)))`
+
+// GetNotifications lists the user's notifications, most recently updated
+// first, or only the unread ones. UnreadCount counts all unread ones.
+func GetNotifications(db *sql.DB, userID string, unreadOnly bool, params Params) (PayloadNotifications, error) {
+	result := PayloadNotifications{Data: []Notification{}}
+	limit, offset := params.Paging()
+
+	where := `notifications.user_id = $1 AND (NOT $2 OR notifications.read_at IS NULL) AND ` + notificationVisible
+	rows, err := db.Query(`SELECT notifications.id, notifications.type, notifications.post_id, notifications.comment_id,
+			notifications.report_id, notifications.event_count, notifications.read_at IS NOT NULL,
+			notifications.created_at, notifications.updated_at, cardinality(notifications.actor_ids),
+			ARRAY(SELECT users.username FROM unnest(notifications.actor_ids) WITH ORDINALITY AS a(id, n)
+				JOIN users ON users.id = a.id ORDER BY a.n DESC LIMIT $5)
+		FROM notifications WHERE `+where+`
+		ORDER BY notifications.updated_at DESC, notifications.id LIMIT $3 OFFSET $4`,
+		userID, unreadOnly, limit, offset, maxNotificationActors)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	if err := db.QueryRow(`SELECT COUNT(*), COUNT(*) FILTER (WHERE notifications.read_at IS NULL)
+		FROM notifications WHERE `+where, userID, unreadOnly).Scan(&result.TotalData, &result.UnreadCount); err != nil {
+		return result, err
+	}
+
+	for rows.Next() {
+		var n Notification
+		var actors pq.StringArray
+		if err := rows.Scan(&n.ID, &n.Type, &n.PostId, &n.CommentId, &n.ReportId, &n.EventCount, &n.Read,
+			&n.CreatedAt, &n.UpdatedAt, &n.ActorCount, &actors); err != nil {
+			return result, err
+		}
+
+		n.Actors = []string(actors)
+		if n.Actors == nil {
+			n.Actors = []string{}
+		}
+		n.Message = notificationMessage(n.Type, n.Actors, n.ActorCount)
+		n.CreatedAt = n.CreatedAt.UTC().Add(time.Hour * 7)
+		n.UpdatedAt = n.UpdatedAt.UTC().Add(time.Hour * 7)
+		result.Data = append(result.Data, n)
+	}
+
+	return result, rows.Err()
+}
+
+// MarkNotificationRead marks one of the user's notifications as read. Later
+// events of the same kind start a new notification.
+func MarkNotificationRead(db *sql.DB, userID, id string) error {
+	res, err := db.Exec(`UPDATE notifications SET read_at = COALESCE(read_at, $3)
+		WHERE id = $1 AND user_id = $2`, id, userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every notification of the user as read
+// and returns how many were unread.
+func MarkAllNotificationsRead(db *sql.DB, userID string) (int64, error) {
+	res, err := db.Exec(`UPDATE notifications SET read_at = $2 WHERE user_id = $1 AND read_at IS NULL`,
+		userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetNotificationPreferences returns, for every type, whether the user
+// wants its notifications. Every type is on until turned off.
+func GetNotificationPreferences(db *sql.DB, userID string) (map[string]bool, error) {
+	prefs := map[string]bool{}
+	for _, kind := range NotificationTypes {
+		prefs[kind] = true
+	}
+
+	rows, err := db.Query("SELECT type, enabled FROM notification_preferences WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var enabled bool
+		if err := rows.Scan(&kind, &enabled); err != nil {
+			return nil, err
+		}
+		prefs[kind] = enabled
+	}
+
+	return prefs, rows.Err()
+}
+
+// SaveNotificationPreferences turns the given types on or off. Types left
+// out keep their setting.
+func SaveNotificationPreferences(db *sql.DB, userID string, prefs map[string]bool) error {
+	var kinds []string
+	var enabled []bool
+	for kind, on := range prefs {
+		valid := false
+		for _, known := range NotificationTypes {
+			valid = valid || kind == known
+		}
+		if !valid {
+			return ErrNotificationType
+		}
+
+		kinds = append(kinds, kind)
+		enabled = append(enabled, on)
+	}
+
+	_, err := db.Exec(`INSERT INTO notification_preferences(user_id, type, enabled, updated_at)
+		SELECT $1, p.type, p.enabled, $4 FROM unnest($2::varchar[], $3::boolean[]) AS p(type, enabled)
+		ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at`,
+		userID, pq.Array(kinds), pq.Array(enabled), time.Now())
+	return err
+}
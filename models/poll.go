@@ -0,0 +1,331 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	PollMinOptions = 2
+	PollMaxOptions = 10
+)
+
+var (
+	ErrInvalidPoll     = errors.New("A poll needs 2 to 10 options")
+	ErrInvalidClosesAt = errors.New("closes_at must be a future time")
+	ErrPollClosed      = errors.New("This poll is closed")
+	ErrAlreadyVoted    = errors.New("You have already voted in this poll")
+	ErrInvalidVote     = errors.New("Invalid poll options")
+)
+
+// Poll is a survey attached to a post. Each user votes once, for one option
+// or, when Multiple is set, for several.
+type Poll struct {
+	ID          string       `json:"id"`
+	Multiple    bool         `json:"multiple"`
+	HideResults bool         `json:"hide_results"`
+	ClosesAt    *time.Time   `json:"closes_at"`
+	Closed      bool         `json:"closed"`
+	Options     []PollOption `json:"options" validate:"dive"`
+	// TotalVoters and the votes of each option are left out, with
+	// ResultsHidden set, while HideResults keeps the viewer from seeing them.
+	TotalVoters   *int     `json:"total_voters,omitempty"`
+	ResultsHidden bool     `json:"results_hidden,omitempty"`
+	MyVotes       []string `json:"my_votes"`
+}
+
+type PollOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label" validate:"required,max=100"`
+	Votes *int   `json:"votes,omitempty"`
+}
+
+// checkPoll validates the poll to be created with the post.
+func (p *Post) checkPoll() error {
+	if p.Poll == nil {
+		return nil
+	}
+
+	if len(p.Poll.Options) < PollMinOptions || len(p.Poll.Options) > PollMaxOptions {
+		return ErrInvalidPoll
+	}
+
+	if p.Poll.ClosesAt != nil && !p.Poll.ClosesAt.After(time.Now()) {
+		return ErrInvalidClosesAt
+	}
+
+	return nil
+}
+
+// createPoll stores the poll of a new post.
+func (p *Post) createPoll(tx *sql.Tx) error {
+	if p.Poll == nil {
+		return nil
+	}
+
+	if p.Kind == PostKindRepost {
+		return ErrInvalidPoll
+	}
+
+	pollID := uuid.New().String()
+	_, err := tx.Exec(`INSERT INTO polls(id, post_id, multiple, hide_results, closes_at, created_at)
+		VALUES($1, $2, $3, $4, $5, $6)`,
+		pollID, p.ID, p.Poll.Multiple, p.Poll.HideResults, p.Poll.ClosesAt, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i, option := range p.Poll.Options {
+		_, err := tx.Exec("INSERT INTO poll_options(id, poll_id, position, label) VALUES($1, $2, $3, $4)",
+			uuid.New().String(), pollID, i, option.Label)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadPolls fills in the poll of each post with its tallies as seen by
+// viewerID. Results of a poll with HideResults stay hidden until the viewer
+// has voted or the poll has closed; the post's author always sees them.
+func loadPolls(db *sql.DB, posts []Post, viewerID string) error {
+	var ids []string
+	authors := map[string]string{}
+	for i := range posts {
+		posts[i].Poll = nil
+		ids = append(ids, posts[i].ID)
+		authors[posts[i].ID] = posts[i].UserId
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(`SELECT id, post_id, multiple, hide_results, closes_at, closes_at IS NOT NULL AND closes_at <= NOW(),
+			(SELECT COUNT(*) FROM poll_voters WHERE poll_voters.poll_id = polls.id),
+			EXISTS (SELECT 1 FROM poll_voters WHERE poll_voters.poll_id = polls.id AND poll_voters.user_id = $2)
+		FROM polls WHERE post_id = ANY($1)`, pq.Array(ids), viewerID)
+	if err != nil {
+		return err
+	}
+
+	polls := map[string]*Poll{}
+	byPost := map[string]*Poll{}
+	var pollIDs []string
+	for rows.Next() {
+		var postID string
+		var total int
+		var voted bool
+		poll := &Poll{Options: []PollOption{}, MyVotes: []string{}}
+		if err := rows.Scan(&poll.ID, &postID, &poll.Multiple, &poll.HideResults, &poll.ClosesAt, &poll.Closed,
+			&total, &voted); err != nil {
+			rows.Close()
+			return err
+		}
+
+		if poll.ClosesAt != nil {
+			closesAt := poll.ClosesAt.UTC().Add(time.Hour * 7)
+			poll.ClosesAt = &closesAt
+		}
+
+		poll.ResultsHidden = poll.HideResults && !voted && !poll.Closed && authors[postID] != viewerID
+		if !poll.ResultsHidden {
+			poll.TotalVoters = &total
+		}
+
+		polls[poll.ID] = poll
+		byPost[postID] = poll
+		pollIDs = append(pollIDs, poll.ID)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(pollIDs) == 0 {
+		return nil
+	}
+
+	rows, err = db.Query(`SELECT id, poll_id, label,
+			(SELECT COUNT(*) FROM poll_votes WHERE poll_votes.option_id = poll_options.id)
+		FROM poll_options WHERE poll_id = ANY($1) ORDER BY position`, pq.Array(pollIDs))
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var option PollOption
+		var pollID string
+		var votes int
+		if err := rows.Scan(&option.ID, &pollID, &option.Label, &votes); err != nil {
+			rows.Close()
+			return err
+		}
+
+		poll := polls[pollID]
+		if !poll.ResultsHidden {
+			option.Votes = &votes
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query("SELECT poll_id, option_id FROM poll_votes WHERE poll_id = ANY($1) AND user_id = $2",
+		pq.Array(pollIDs), viewerID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var pollID, optionID string
+		if err := rows.Scan(&pollID, &optionID); err != nil {
+			return err
+		}
+		polls[pollID].MyVotes = append(polls[pollID].MyVotes, optionID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Poll = byPost[posts[i].ID]
+	}
+
+	return nil
+}
+
+// Vote records the user's vote for optionIDs in the post's poll and reloads
+// the post with the new tallies. The poll row is locked for the vote, so a
+// vote either lands before the poll closes or fails with ErrPollClosed. It
+// returns sql.ErrNoRows when the post has no poll the user can see.
+func (p *Post) Vote(db *sql.DB, userID string, optionIDs []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var pollID string
+	var multiple, closed bool
+	err = tx.QueryRow(`SELECT polls.id, polls.multiple, polls.closes_at IS NOT NULL AND polls.closes_at <= clock_timestamp()
+		FROM polls JOIN posts ON posts.id = polls.post_id
+		WHERE polls.post_id = $1 AND posts.deleted_at IS NULL AND (posts.user_id = $2 OR posts.status = 'published')
+		FOR SHARE OF polls`, p.ID, userID).Scan(&pollID, &multiple, &closed)
+	if err != nil {
+		return err
+	}
+
+	if closed {
+		return ErrPollClosed
+	}
+
+	seen := map[string]bool{}
+	choices := []string{}
+	for _, id := range optionIDs {
+		if !seen[id] {
+			seen[id] = true
+			choices = append(choices, id)
+		}
+	}
+
+	if len(choices) == 0 || (!multiple && len(choices) > 1) {
+		return ErrInvalidVote
+	}
+
+	var found int
+	err = tx.QueryRow("SELECT COUNT(*) FROM poll_options WHERE poll_id = $1 AND id = ANY($2)",
+		pollID, pq.Array(choices)).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if found != len(choices) {
+		return ErrInvalidVote
+	}
+
+	res, err := tx.Exec(`INSERT INTO poll_voters(poll_id, user_id, created_at) VALUES($1, $2, $3)
+		ON CONFLICT DO NOTHING`, pollID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrAlreadyVoted
+	}
+
+	_, err = tx.Exec(`INSERT INTO poll_votes(poll_id, option_id, user_id)
+		SELECT $1, option_id, $3 FROM unnest($2::varchar[]) AS option_id`,
+		pollID, pq.Array(choices), userID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.GetPost(db, userID)
+}
+
+// ClosePoll closes the poll of the user's post now. Votes already in progress
+// finish first. It returns sql.ErrNoRows when the user has no open poll on
+// the post.
+func (p *Post) ClosePoll(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE polls SET closes_at = NOW()
+		FROM posts
+		WHERE posts.id = polls.post_id AND polls.post_id = $1 AND posts.user_id = $2 AND posts.deleted_at IS NULL
+			AND (polls.closes_at IS NULL OR polls.closes_at > NOW())`, p.ID, p.UserId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := p.scan(tx.QueryRow(`SELECT `+postColumns+` FROM posts WHERE id = $1`, p.ID)); err != nil {
+		return err
+	}
+
+	published := p.Status == PostStatusPublished
+	if err := p.emitPostWebhook(tx, published, published); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.GetPost(db, p.UserId)
+}
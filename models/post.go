@@ -2,20 +2,81 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/bayudha2/go-test-0/utils/markdown"
 	"github.com/google/uuid"
 )
 
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+var ErrInvalidPublishAt = errors.New("publish_at must be a future time for scheduled posts")
+
+// markdownCache keeps the HTML of recently read posts and comments, so
+// listing them does not render the same text again.
+var markdownCache = markdown.NewCache(4096)
+
 type Post struct {
-	ID          string `json:"id" validate:"omitempty"`
-	UserId      string `json:"user_id" validate:"omitempty"`
-	Description string `json:"description" validate:"required"`
+	ID     string `json:"id" validate:"omitempty"`
+	UserId string `json:"user_id" validate:"omitempty"`
+	// Description may only be left out when sharing RepostOfId.
+	Description string `json:"description" validate:"required_without=RepostOfId"`
+	// DescriptionHTML is Description rendered from Markdown and sanitized.
+	DescriptionHTML string     `json:"description_html"`
+	Status          string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt       *time.Time `json:"publish_at"`
+	Edited          bool       `json:"edited"`
+	EditCount       int        `json:"edit_count"`
+	// Kind is "post", "repost" (sharing RepostOfId as is) or "quote"
+	// (sharing it with commentary in Description).
+	Kind       string  `json:"kind"`
+	RepostOfId *string `json:"repost_of_id"`
+	// Original is the shared post, left out with OriginalUnavailable set
+	// when it has been deleted or unpublished since.
+	Original            *Post `json:"original,omitempty"`
+	OriginalUnavailable bool  `json:"original_unavailable,omitempty"`
+	RepostCount         int   `json:"repost_count"`
+	QuoteCount          int   `json:"quote_count"`
+	BookmarkedByMe      bool  `json:"bookmarked_by_me"`
+	// Sensitive posts are shown behind ContentWarning, e.g. "spoiler".
+	// WarningForced is set when a moderator flagged the post, in which case
+	// its author cannot unflag it.
+	Sensitive      bool    `json:"sensitive"`
+	ContentWarning *string `json:"content_warning" validate:"omitempty,max=100"`
+	WarningForced  bool    `json:"warning_forced"`
+	// CommentApproval makes new comments by others wait for the author's
+	// approval.
+	CommentApproval bool `json:"comment_approval"`
+	// CommentsLocked stops new comments, from anyone but the author and
+	// moderators when CommentLockMode is "everyone", or from anyone who does
+	// not follow the author when it is "followers". CommentLockForced is set
+	// when a moderator locked them, in which case the author cannot unlock.
+	CommentsLocked    bool    `json:"comments_locked"`
+	CommentLockMode   *string `json:"comment_lock_mode,omitempty"`
+	CommentLockForced bool    `json:"comment_lock_forced"`
+	// ContentFilter is "blur" or "hide" when the viewer asked for sensitive
+	// posts of others to be blurred or hidden, and empty otherwise.
+	ContentFilter string `json:"content_filter,omitempty"`
+	// Poll is created with the post and cannot be changed afterwards.
+	Poll *Poll `json:"poll,omitempty"`
+	// AttachmentIds are uploads to attach when the post is created.
+	AttachmentIds []string     `json:"attachment_ids,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+	// LinkPreviews are the cards of the first links in Description, shown
+	// once they have been fetched.
+	LinkPreviews []LinkPreview `json:"link_previews,omitempty"`
 	// Likes       uint      `json:"likes"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Rank      float64    `json:"rank,omitempty"`
+	Snippet   string     `json:"snippet,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type PayloadPosts struct {
@@ -23,98 +84,445 @@ type PayloadPosts struct {
 	TotalData int    `json:"total_data"`
 }
 
-func (p *Post) CreatePost(db *sql.DB) error {
-	err := db.QueryRow(`INSERT INTO posts(id, user_id, description, created_at, updated_at) 
-		VALUES($1, $2, $3, $4, $5) 
-		RETURNING id, user_id, description, created_at, updated_at`,
-		uuid.New().String(), p.UserId, p.Description, time.Now(), time.Now()).Scan(&p.ID, &p.UserId, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+// postColumns are the columns read by scan, in the same order.
+const postColumns = `posts.id, posts.user_id, posts.description, posts.status, posts.publish_at,
+	posts.edit_count, posts.kind, posts.repost_of_id, posts.sensitive, posts.content_warning,
+	posts.warning_forced_by IS NOT NULL, posts.comment_approval, posts.comments_locked,
+	COALESCE(posts.comments_locked_by <> posts.user_id, false), ` + shareCounts + `,
+	posts.created_at, posts.updated_at, posts.deleted_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan reads a row selected with postColumns, followed by any extra columns.
+func (p *Post) scan(row rowScanner, extra ...interface{}) error {
+	dest := []interface{}{&p.ID, &p.UserId, &p.Description, &p.Status, &p.PublishAt, &p.EditCount,
+		&p.Kind, &p.RepostOfId, &p.Sensitive, &p.ContentWarning, &p.WarningForced,
+		&p.CommentApproval, &p.CommentLockMode, &p.CommentLockForced, &p.RepostCount, &p.QuoteCount, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
 
+	p.Edited = p.EditCount > 0
+	p.CommentsLocked = p.CommentLockMode != nil
+	p.DescriptionHTML = markdownCache.Render(p.Description)
 	p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
 	p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
+	if p.PublishAt != nil {
+		publishAt := p.PublishAt.UTC().Add(time.Hour * 7)
+		p.PublishAt = &publishAt
+	}
+	if p.DeletedAt != nil {
+		deletedAt := p.DeletedAt.UTC().Add(time.Hour * 7)
+		p.DeletedAt = &deletedAt
+	}
+
+	return nil
+}
+
+// checkSchedule validates the requested status, defaulting to published.
+func (p *Post) checkSchedule() error {
+	if p.Status == "" {
+		p.Status = PostStatusPublished
+	}
+
+	if p.Status == PostStatusScheduled && (p.PublishAt == nil || !p.PublishAt.After(time.Now())) {
+		return ErrInvalidPublishAt
+	}
+
+	if p.Status != PostStatusScheduled {
+		p.PublishAt = nil
+	}
+
+	return nil
+}
+
+// CreatePost adds a post. When RepostOfId is set the post shares that post,
+// as a repost when Description is empty and as a quote otherwise; sql.ErrNoRows
+// is returned when the shared post is not published.
+func (p *Post) CreatePost(db *sql.DB) error {
+	if err := p.checkSchedule(); err != nil {
+		return err
+	}
+
+	if err := p.checkPoll(); err != nil {
+		return err
+	}
 
+	p.checkContentWarning()
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+
+	defer tx.Rollback()
+
+	p.Kind = PostKindPost
+	if p.RepostOfId != nil {
+		if err := p.resolveOriginal(tx); err != nil {
+			return err
+		}
+	}
+
+	err = p.scan(tx.QueryRow(`INSERT INTO posts(id, user_id, description, search_config, status, publish_at, kind, repost_of_id,
+			sensitive, content_warning, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, COALESCE($6, CASE WHEN $5 = 'published' THEN NOW() END), $7, $8, $9, $10, $11, $12)
+		RETURNING `+postColumns,
+		uuid.New().String(), p.UserId, p.Description, TextSearchConfig, p.Status, p.PublishAt, p.Kind, p.RepostOfId,
+		p.Sensitive, p.ContentWarning, time.Now(), time.Now()))
+	if isUniqueViolation(err) {
+		return ErrAlreadyReposted
+	}
+	if err != nil {
+		return err
+	}
+
+	if p.Kind != PostKindRepost {
+		_, err = tx.Exec(`INSERT INTO post_revisions(id, post_id, revision, description, editor_id, created_at)
+			VALUES($1, $2, 1, $3, $4, $5)`, uuid.New().String(), p.ID, p.Description, p.UserId, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := p.attachToPost(tx); err != nil {
+		return err
+	}
+
+	if err := p.createPoll(tx); err != nil {
+		return err
+	}
+
+	if err := p.syncEntities(tx); err != nil {
+		return err
+	}
+
+	if p.RepostOfId != nil {
+		var author string
+		if err := tx.QueryRow("SELECT user_id FROM posts WHERE id = $1", *p.RepostOfId).Scan(&author); err != nil {
+			return err
+		}
+
+		err := notify(tx, notification{userID: author, kind: NotificationRepost,
+			groupKey: "repost:" + *p.RepostOfId, actorID: p.UserId, postID: p.RepostOfId})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := p.emitPostWebhook(tx, false, p.Status == PostStatusPublished); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.loadRelations(db, p.UserId)
 }
 
+// UpdatePost changes the description and, when Status is set, the publishing
+// state. A post that is already published keeps its original publish time.
 func (p *Post) UpdatePost(db *sql.DB) error {
-	err := db.QueryRow(`UPDATE posts SET description=$1, updated_at=$2
+	if p.Status != "" {
+		if err := p.checkSchedule(); err != nil {
+			return err
+		}
+	}
+
+	p.checkContentWarning()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var current, kind, status string
+	err = tx.QueryRow("SELECT description, kind, status FROM posts WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL FOR UPDATE",
+		p.ID, p.UserId).Scan(&current, &kind, &status)
+	if err != nil {
+		return err
+	}
+
+	if kind == PostKindRepost {
+		return ErrRepostNotEditable
+	}
+
+	edits := 0
+	if current != p.Description {
+		edits = 1
+		if err := recordRevision(tx, p.ID, current, p.Description, p.UserId); err != nil {
+			return err
+		}
+	}
+
+	err = p.scan(tx.QueryRow(`UPDATE posts SET description=$1, updated_at=$2, edit_count = edit_count + $7,
+			status = COALESCE(NULLIF($5, ''), status),
+			sensitive = $8 OR warning_forced_by IS NOT NULL,
+			content_warning = CASE WHEN warning_forced_by IS NULL THEN $9 ELSE content_warning END,
+			publish_at = CASE
+				WHEN NULLIF($5, '') IS NULL THEN publish_at
+				WHEN $5 = 'published' AND status = 'published' THEN publish_at
+				WHEN $5 = 'published' THEN NOW()
+				ELSE $6
+			END
 		WHERE id=$3 AND user_id=$4
-		RETURNING id, user_id, description, created_at, updated_at`,
-		p.Description, time.Now(), p.ID, p.UserId,
-	).Scan(&p.ID, &p.UserId, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+		RETURNING `+postColumns,
+		p.Description, time.Now(), p.ID, p.UserId, p.Status, p.PublishAt, edits, p.Sensitive, p.ContentWarning,
+	))
+	if err != nil {
+		return err
+	}
 
-	p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
-	p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
+	if err := p.syncEntities(tx); err != nil {
+		return err
+	}
 
+	err = p.emitPostWebhook(tx, status == PostStatusPublished, p.Status == PostStatusPublished)
 	if err != nil {
 		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.loadRelations(db, p.UserId)
+}
+
+// loadRelations fills in the attachments, poll and shared post of a single
+// post.
+func (p *Post) loadRelations(db *sql.DB, viewerID string) error {
+	posts := []Post{*p}
+	if err := loadRelations(db, posts, viewerID); err != nil {
+		return err
+	}
+
+	*p = posts[0]
 	return nil
 }
 
+// loadRelations fills in the attachments, polls, link previews and shared
+// posts of each post, and marks sensitive ones, as seen by viewerID.
+func loadRelations(db *sql.DB, posts []Post, viewerID string) error {
+	if err := loadAttachments(db, posts); err != nil {
+		return err
+	}
+
+	if err := loadPolls(db, posts, viewerID); err != nil {
+		return err
+	}
+
+	if err := loadLinkPreviews(db, posts); err != nil {
+		return err
+	}
+
+	if err := loadOriginals(db, posts, viewerID); err != nil {
+		return err
+	}
+
+	return annotateSensitive(db, posts, viewerID)
+}
+
+// syncEntities stores the hashtags, mentions and links found in the
+// description.
+func (p *Post) syncEntities(tx *sql.Tx) error {
+	if err := syncPostTags(tx, p.ID, p.Description); err != nil {
+		return err
+	}
+
+	if err := syncPostLinks(tx, p.ID, p.Description); err != nil {
+		return err
+	}
+
+	return syncMentions(tx, p.UserId, p.ID, nil, p.Description, true)
+}
+
+// DeletePost moves the post to its author's trash.
 func (p *Post) DeletePost(db *sql.DB) error {
-	res, err := db.Exec("DELETE FROM posts WHERE id=$1 AND user_id=$2", p.ID, p.UserId)
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 
-	n, err := res.RowsAffected()
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("UPDATE posts SET deleted_at=$1 WHERE id=$2 AND user_id=$3 AND deleted_at IS NULL RETURNING status",
+		time.Now(), p.ID, p.UserId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Unauthorized request!")
+	}
 	if err != nil {
 		return err
 	}
 
-	if n == 0 {
-		return fmt.Errorf("Unauthorized request!")
+	if err := p.emitPostWebhook(tx, status == PostStatusPublished, false); err != nil {
+		return err
 	}
-	return nil
+
+	return tx.Commit()
 }
 
-func (p *Post) GetPost(db *sql.DB) error {
-	err := db.QueryRow("SELECT * FROM posts WHERE id=$1", p.ID).Scan(&p.ID, &p.UserId, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+// GetPost loads the post unless it is unpublished, or a repost of a post
+// that is no longer available, and viewerID is not its author, in which case
+// sql.ErrNoRows is returned.
+func (p *Post) GetPost(db *sql.DB, viewerID string) error {
+	err := p.scan(db.QueryRow(`SELECT `+postColumns+`,
+			EXISTS (SELECT 1 FROM bookmarks WHERE bookmarks.post_id = posts.id AND bookmarks.user_id = $2)
+		FROM posts
+		WHERE id=$1 AND deleted_at IS NULL AND (user_id = $2 OR (status = 'published' AND `+originalAvailable+`))`,
+		p.ID, viewerID), &p.BookmarkedByMe)
 	if err != nil {
 		return err
 	}
-	return nil
+	return p.loadRelations(db, viewerID)
 }
 
+// GetPosts lists the posts of p.UserId in every state, optionally filtered by
+// params.Status.
 func (p *Post) GetPosts(db *sql.DB, params Params) (PayloadPosts, error) {
+	if tsquery := BuildTsQuery(params.Search); tsquery != "" {
+		return p.searchPosts(db, params, tsquery)
+	}
+
 	var result PayloadPosts
+	limit, offset := params.Paging()
 	query := fmt.Sprintf(`
-		SELECT * From posts
-		WHERE user_id = '%s'
-		ORDER BY created_at %s LIMIT %v OFFSET ((%v - 1) * %v)
-	`, p.UserId, params.Order, params.Limit, params.Page, params.Limit)
+		SELECT %s FROM posts
+		WHERE user_id = $1 AND deleted_at IS NULL AND (NULLIF($2, '') IS NULL OR status = $2)
+		ORDER BY created_at %s LIMIT $3 OFFSET $4
+	`, postColumns, params.SortOrder())
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, p.UserId, params.Status, limit, offset)
 	if err != nil {
-		log.Fatal(err.Error())
 		return result, err
 	}
 
-	count := fmt.Sprintf(`
-	SELECT COUNT(*) FROM posts WHERE user_id = '%s'
-	`, p.UserId)
-	if err := db.QueryRow(count).Scan(&result.TotalData); err != nil {
+	defer rows.Close()
+
+	count := `SELECT COUNT(*) FROM posts
+		WHERE user_id = $1 AND deleted_at IS NULL AND (NULLIF($2, '') IS NULL OR status = $2)`
+	if err := db.QueryRow(count, p.UserId, params.Status).Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	var posts []Post
+	for rows.Next() {
+		var p Post
+		if err := p.scan(rows); err != nil {
+			return result, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	if err := loadRelations(db, posts, p.UserId); err != nil {
+		return result, err
+	}
+
+	result.Data = posts
+	return result, nil
+}
+
+// searchPosts returns the posts matching tsquery ordered by relevance, each
+// with a highlighted snippet of the matching text.
+func (p *Post) searchPosts(db *sql.DB, params Params, tsquery string) (PayloadPosts, error) {
+	var result PayloadPosts
+	limit, offset := params.Paging()
+
+	rows, err := db.Query(`
+		SELECT `+postColumns+`,
+			ts_rank_cd(search_vector, q) AS rank,
+			ts_headline($2::regconfig, description, q, $4)
+		FROM posts, to_tsquery($2::regconfig, $3) q
+		WHERE user_id = $1 AND search_vector @@ q AND deleted_at IS NULL
+			AND (NULLIF($7, '') IS NULL OR status = $7)
+		ORDER BY rank DESC, created_at DESC LIMIT $5 OFFSET $6
+	`, p.UserId, TextSearchConfig, tsquery, headlineOptions, limit, offset, params.Status)
+	if err != nil {
 		return result, err
 	}
 
 	defer rows.Close()
 
+	count := `SELECT COUNT(*) FROM posts
+		WHERE user_id = $1 AND search_vector @@ to_tsquery($2::regconfig, $3) AND deleted_at IS NULL
+			AND (NULLIF($4, '') IS NULL OR status = $4)`
+	if err := db.QueryRow(count, p.UserId, TextSearchConfig, tsquery, params.Status).Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
 	var posts []Post
 	for rows.Next() {
 		var p Post
-		if err := rows.Scan(&p.ID, &p.UserId, &p.Description, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := p.scan(rows, &p.Rank, &p.Snippet); err != nil {
 			return result, err
 		}
 
-		p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
-		p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
+		p.Snippet = formatHeadline(p.Snippet)
 		posts = append(posts, p)
 	}
 
+	if err := loadRelations(db, posts, p.UserId); err != nil {
+		return result, err
+	}
+
 	result.Data = posts
 	return result, nil
 }
+
+// PublishDuePosts publishes every scheduled post whose publish time has
+// passed and returns their ids. The transaction holds an advisory lock so only
+// one server instance publishes a given batch; the others get no ids back.
+func PublishDuePosts(db *sql.DB) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow("SELECT pg_try_advisory_xact_lock(hashtext('publish_due_posts'))").Scan(&locked); err != nil {
+		return nil, err
+	}
+
+	if !locked {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`UPDATE posts SET status = 'published'
+		WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+		RETURNING ` + postColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []Post
+	for rows.Next() {
+		var p Post
+		if err := p.scan(rows); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for i := range posts {
+		if err := posts[i].emitPostWebhook(tx, false, true); err != nil {
+			return nil, err
+		}
+		ids = append(ids, posts[i].ID)
+	}
+
+	return ids, tx.Commit()
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// How a user wants to see posts flagged as sensitive by someone else.
+const (
+	SensitiveShow = "show"
+	SensitiveBlur = "blur"
+	SensitiveHide = "hide"
+)
+
+// Preferences are the settings of a user. Users who never saved any get
+// the defaults of DefaultPreferences.
+type Preferences struct {
+	SensitiveContent string `json:"sensitive_content" validate:"required,oneof=show blur hide"`
+}
+
+func DefaultPreferences() Preferences {
+	return Preferences{SensitiveContent: SensitiveBlur}
+}
+
+// GetPreferences returns the preferences of the user. Anonymous viewers,
+// with an empty userID, get the defaults.
+func GetPreferences(db *sql.DB, userID string) (Preferences, error) {
+	prefs := DefaultPreferences()
+	if userID == "" {
+		return prefs, nil
+	}
+
+	err := db.QueryRow("SELECT sensitive_content FROM user_preferences WHERE user_id = $1", userID).
+		Scan(&prefs.SensitiveContent)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	return prefs, err
+}
+
+// SavePreferences stores the preferences of the user.
+func (p *Preferences) SavePreferences(db *sql.DB, userID string) error {
+	_, err := db.Exec(`INSERT INTO user_preferences(user_id, sensitive_content, updated_at) VALUES($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET sensitive_content = EXCLUDED.sensitive_content,
+			updated_at = EXCLUDED.updated_at`, userID, p.SensitiveContent, time.Now())
+	return err
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ProcessingLease is how long a claimed image may take before another
+// worker picks it up again, e.g. after a crash.
+var ProcessingLease = 10 * time.Minute
+
+// ClaimImages marks up to limit images as processing and returns them. Each
+// claim counts as an attempt; images still processing after their lease
+// with no attempts left are marked failed.
+func ClaimImages(db *sql.DB, limit, maxAttempts int) ([]Attachment, error) {
+	_, err := db.Exec(`UPDATE attachments SET processing_status = 'failed',
+			processing_error = COALESCE(processing_error, 'processing timed out')
+		WHERE processing_status = 'processing' AND next_attempt_at <= NOW() AND processing_attempts >= $1`,
+		maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`UPDATE attachments SET processing_status = 'processing',
+			processing_attempts = processing_attempts + 1,
+			next_attempt_at = NOW() + $3::float8 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM attachments
+			WHERE processing_status IN ('pending', 'processing') AND next_attempt_at <= NOW()
+				AND processing_attempts < $2
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+		RETURNING `+attachmentColumns, limit, maxAttempts, ProcessingLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var images []Attachment
+	for rows.Next() {
+		var a Attachment
+		if err := a.scan(rows); err != nil {
+			return nil, err
+		}
+		images = append(images, a)
+	}
+
+	return images, rows.Err()
+}
+
+// SaveVariants records the rendered variants and image metadata and marks
+// the attachment done. It returns sql.ErrNoRows when the attachment was
+// deleted while it was being processed.
+func (a *Attachment) SaveVariants(db *sql.DB, width, height int, blurhash string, variants map[string]AttachmentVariant) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = a.scan(tx.QueryRow(`UPDATE attachments SET width = $2, height = $3, blurhash = $4,
+			processing_status = 'done', processing_error = NULL
+		WHERE id = $1
+		RETURNING `+attachmentColumns, a.ID, width, height, blurhash))
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range variants {
+		names = append(names, name)
+	}
+
+	_, err = tx.Exec("DELETE FROM attachment_variants WHERE attachment_id = $1 AND NOT (name = ANY($2))", a.ID, pq.Array(names))
+	if err != nil {
+		return err
+	}
+
+	for name, v := range variants {
+		_, err = tx.Exec(`INSERT INTO attachment_variants(attachment_id, name, blob_key, content_type, width, height, size, created_at)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (attachment_id, name) DO UPDATE SET blob_key = EXCLUDED.blob_key,
+				content_type = EXCLUDED.content_type, width = EXCLUDED.width, height = EXCLUDED.height,
+				size = EXCLUDED.size, created_at = EXCLUDED.created_at`,
+			a.ID, name, v.BlobKey, v.ContentType, v.Width, v.Height, v.Size, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	a.Variants = variants
+	return tx.Commit()
+}
+
+// FailProcessing records a failed attempt. The image is retried after
+// backoff, doubled for every earlier attempt, until maxAttempts is reached
+// and it is marked failed; a maxAttempts of 0 marks it failed right away.
+func (a *Attachment) FailProcessing(db *sql.DB, reason string, maxAttempts int, backoff time.Duration) error {
+	_, err := db.Exec(`UPDATE attachments SET processing_error = $2,
+			processing_status = CASE WHEN processing_attempts >= $3 THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = NOW() + $4::float8 * POWER(2, processing_attempts - 1) * INTERVAL '1 second'
+		WHERE id = $1`, a.ID, reason, maxAttempts, backoff.Seconds())
+	return err
+}
+
+// GetFailedAttachments lists the images the pipeline gave up on. Only admins
+// may see them.
+func GetFailedAttachments(db *sql.DB, adminID string, params Params) (PayloadFailedAttachments, error) {
+	var result PayloadFailedAttachments
+
+	admin, err := IsAdmin(db, adminID)
+	if err != nil {
+		return result, err
+	}
+
+	if !admin {
+		return result, ErrUnauthorized
+	}
+
+	limit, offset := params.Paging()
+	rows, err := db.Query(`SELECT `+attachmentColumns+`, processing_attempts, COALESCE(processing_error, '')
+		FROM attachments WHERE processing_status = 'failed'
+		ORDER BY next_attempt_at DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM attachments WHERE processing_status = 'failed'").Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	result.Data = []FailedAttachment{}
+	for rows.Next() {
+		var f FailedAttachment
+		if err := f.scan(rows, &f.Attempts, &f.Error); err != nil {
+			return result, err
+		}
+		result.Data = append(result.Data, f)
+	}
+
+	return result, rows.Err()
+}
+
+// RetryProcessing queues a failed image again with a fresh set of attempts.
+// Only admins may retry; sql.ErrNoRows means the image has not failed.
+func (a *Attachment) RetryProcessing(db *sql.DB, adminID string) error {
+	admin, err := IsAdmin(db, adminID)
+	if err != nil {
+		return err
+	}
+
+	if !admin {
+		return ErrUnauthorized
+	}
+
+	return a.scan(db.QueryRow(`UPDATE attachments SET processing_status = 'pending',
+			processing_attempts = 0, processing_error = NULL, next_attempt_at = NOW()
+		WHERE id = $1 AND processing_status = 'failed'
+		RETURNING `+attachmentColumns, a.ID))
+}
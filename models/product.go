@@ -3,17 +3,20 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Product struct {
-	ID        string    `json:"id" validate:"omitempty"`
-	Name      string    `json:"name" validate:"required,min=10"`
-	Price     float64   `json:"price" validate:"required,number"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             string    `json:"id" validate:"omitempty"`
+	Name           string    `json:"name" validate:"required,min=10"`
+	Price          float64   `json:"price" validate:"required,number"`
+	BookmarkedByMe bool      `json:"bookmarked_by_me"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type Params struct {
@@ -22,6 +25,33 @@ type Params struct {
 	Order  string
 	By     string
 	Search string
+	Status string
+}
+
+// Paging converts the page and limit query values into a LIMIT/OFFSET pair,
+// falling back to the first page of 10 rows on bad input.
+func (p Params) Paging() (int, int) {
+	page, err := strconv.Atoi(p.Page)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(p.Limit)
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	return limit, (page - 1) * limit
+}
+
+func (p Params) SortOrder() string {
+	if strings.EqualFold(p.Order, "desc") {
+		return "DESC"
+	}
+	return "ASC"
 }
 
 type payloadProducts struct {
@@ -31,8 +61,11 @@ type payloadProducts struct {
 
 var TotalData int
 
-func (p *Product) GetProduct(db *sql.DB) error {
-	err := db.QueryRow("SELECT name, price, created_at, updated_at FROM products WHERE id=$1", p.ID).Scan(&p.Name, &p.Price, &p.CreatedAt, &p.UpdatedAt)
+// GetProduct loads the product, flagging whether viewerID bookmarked it.
+func (p *Product) GetProduct(db *sql.DB, viewerID string) error {
+	err := db.QueryRow(`SELECT name, price, created_at, updated_at,
+			EXISTS (SELECT 1 FROM bookmarks WHERE bookmarks.product_id = products.id AND bookmarks.user_id = $2)
+		FROM products WHERE id=$1`, p.ID, viewerID).Scan(&p.Name, &p.Price, &p.CreatedAt, &p.UpdatedAt, &p.BookmarkedByMe)
 	if err != nil {
 		return err
 	}
@@ -45,7 +78,14 @@ func (p *Product) GetProduct(db *sql.DB) error {
 }
 
 func (p *Product) UpdateProduct(db *sql.DB) error {
-	err := db.QueryRow("UPDATE products SET name=$1, price=$2, updated_at=$3 WHERE id=$4 RETURNING id, name, price, created_at, updated_at", p.Name, p.Price, time.Now(), p.ID).Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt, &p.UpdatedAt)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = tx.QueryRow("UPDATE products SET name=$1, price=$2, updated_at=$3 WHERE id=$4 RETURNING id, name, price, created_at, updated_at", p.Name, p.Price, time.Now(), p.ID).Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt, &p.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -53,17 +93,47 @@ func (p *Product) UpdateProduct(db *sql.DB) error {
 	p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
 	p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
 
-	return nil
+	if err := emitWebhook(tx, WebhookProductUpdated, nil, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (p *Product) DeleteProduct(db *sql.DB) error {
-	_, err := db.Exec("DELETE FROM products WHERE id=$1", p.ID)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM products WHERE id=$1", p.ID)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// Deleting a product that is already gone is not an event.
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if err := emitWebhook(tx, WebhookProductDeleted, nil, map[string]string{"id": p.ID}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (p *Product) CreateProduct(db *sql.DB) error {
-	err := db.QueryRow("INSERT INTO products(id, name, price, created_at, updated_at) VALUES($1, $2, $3, $4, $5) RETURNING id, name, price, created_at, updated_at", uuid.New().String(), p.Name, p.Price, time.Now(), time.Now()).Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt, &p.UpdatedAt)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = tx.QueryRow("INSERT INTO products(id, name, price, created_at, updated_at) VALUES($1, $2, $3, $4, $5) RETURNING id, name, price, created_at, updated_at", uuid.New().String(), p.Name, p.Price, time.Now(), time.Now()).Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt, &p.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -71,7 +141,11 @@ func (p *Product) CreateProduct(db *sql.DB) error {
 	p.CreatedAt = p.CreatedAt.UTC().Add(time.Hour * 7)
 	p.UpdatedAt = p.UpdatedAt.UTC().Add(time.Hour * 7)
 
-	return nil
+	if err := emitWebhook(tx, WebhookProductCreated, nil, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func GetProducts(db *sql.DB, param Params) (payloadProducts, error) {
@@ -0,0 +1,490 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+)
+
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// What resolving a report does to its target.
+const (
+	ReportActionNone = "none"
+	// ReportActionHide removes a reported post or hides a reported comment.
+	ReportActionHide = "hide"
+	// ReportActionSuspend suspends the reported user, or the author of the
+	// reported post or comment.
+	ReportActionSuspend = "suspend"
+)
+
+var (
+	ErrDuplicateReport = errors.New("You have already reported this")
+	ErrReportSelf      = errors.New("You cannot report yourself or your own content")
+	ErrReportClosed    = errors.New("Report is already closed")
+	ErrReportAction    = errors.New("That action does not apply to this report")
+	ErrInvalidAssignee = errors.New("Reports can only be assigned to admins")
+)
+
+type Report struct {
+	ID         string `json:"id"`
+	ReporterID string `json:"reporter_id"`
+	TargetType string `json:"target_type" validate:"required,oneof=post comment user"`
+	TargetID   string `json:"target_id" validate:"required"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate violence sexual misinformation impersonation other"`
+	Details    string `json:"details" validate:"max=1000"`
+	Status     string `json:"status"`
+	// The fields below are only shown to admins.
+	AssigneeID     *string    `json:"assignee_id,omitempty"`
+	Action         *string    `json:"action,omitempty"`
+	ResolutionNote *string    `json:"resolution_note,omitempty"`
+	ClosedBy       *string    `json:"closed_by,omitempty"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	// ActionTaken tells the reporter whether their report led to an action.
+	ActionTaken *bool `json:"action_taken,omitempty"`
+	// Events is the audit trail of the report, filled in by GetReport.
+	Events    []ReportEvent `json:"events,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// ReportEvent records who did what to a report.
+type ReportEvent struct {
+	ActorID   *string   `json:"actor_id"`
+	Event     string    `json:"event"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PayloadReports struct {
+	Data      []Report `json:"data"`
+	TotalData int      `json:"total_data"`
+	// UnseenOutcomes is the number of the reporter's reports closed since
+	// they last listed them.
+	UnseenOutcomes int `json:"unseen_outcomes,omitempty"`
+}
+
+const reportColumns = `reports.id, reports.reporter_id, reports.target_type, reports.target_id, reports.reason,
+	reports.details, reports.status, reports.assignee_id, reports.action, reports.resolution_note,
+	reports.closed_by, reports.closed_at, reports.created_at, reports.updated_at`
+
+func (r *Report) scan(row rowScanner, extra ...interface{}) error {
+	dest := append([]interface{}{&r.ID, &r.ReporterID, &r.TargetType, &r.TargetID, &r.Reason,
+		&r.Details, &r.Status, &r.AssigneeID, &r.Action, &r.ResolutionNote,
+		&r.ClosedBy, &r.ClosedAt, &r.CreatedAt, &r.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	r.CreatedAt = r.CreatedAt.UTC().Add(time.Hour * 7)
+	r.UpdatedAt = r.UpdatedAt.UTC().Add(time.Hour * 7)
+	if r.ClosedAt != nil {
+		closed := r.ClosedAt.UTC().Add(time.Hour * 7)
+		r.ClosedAt = &closed
+	}
+	return nil
+}
+
+// reportTargetAuthor returns the author of the reported post or comment, or
+// the reported user. Deleted targets are not found.
+func reportTargetAuthor(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, targetType, targetID string) (string, error) {
+	var query string
+	switch targetType {
+	case ReportTargetPost:
+		query = "SELECT user_id FROM posts WHERE id = $1 AND deleted_at IS NULL"
+	case ReportTargetComment:
+		query = "SELECT user_id FROM comments WHERE id = $1 AND deleted_at IS NULL"
+	default:
+		query = "SELECT id FROM users WHERE id = $1"
+	}
+
+	var author string
+	err := q.QueryRow(query, targetID).Scan(&author)
+	return author, err
+}
+
+func addReportEvent(tx *sql.Tx, reportID, actorID, event, detail string) error {
+	_, err := tx.Exec(`INSERT INTO report_events(report_id, actor_id, event, detail, created_at)
+		VALUES($1, $2, $3, $4, $5)`, reportID, actorID, event, detail, time.Now())
+	return err
+}
+
+// CreateReport files the report for ReporterID. sql.ErrNoRows means the
+// target does not exist, ErrDuplicateReport that the reporter already has
+// an open report on it and ErrReportSelf that it is the reporter's own.
+func (r *Report) CreateReport(db *sql.DB) error {
+	author, err := reportTargetAuthor(db, r.TargetType, r.TargetID)
+	if err != nil {
+		return err
+	}
+
+	if author == r.ReporterID {
+		return ErrReportSelf
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now()
+	err = r.scan(tx.QueryRow(`INSERT INTO reports(id, reporter_id, target_type, target_id, reason, details,
+			created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (reporter_id, target_type, target_id) WHERE status = 'open' DO NOTHING
+		RETURNING `+reportColumns, uuid.New().String(), r.ReporterID, r.TargetType, r.TargetID, r.Reason,
+		r.Details, now))
+	if err == sql.ErrNoRows {
+		return ErrDuplicateReport
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := addReportEvent(tx, r.ID, r.ReporterID, "created", r.Reason); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetReportsByReporter lists the reports the user filed, newest first,
+// with whether each closed one led to an action. Listing them marks their
+// outcomes as seen.
+func GetReportsByReporter(db *sql.DB, reporterID string, params Params) (PayloadReports, error) {
+	result := PayloadReports{Data: []Report{}}
+	limit, offset := params.Paging()
+
+	rows, err := db.Query(`SELECT `+reportColumns+` FROM reports WHERE reporter_id = $1
+		ORDER BY created_at DESC, id LIMIT $2 OFFSET $3`, reporterID, limit, offset)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	if err := db.QueryRow(`SELECT COUNT(*), COUNT(*) FILTER (WHERE status <> 'open' AND outcome_seen_at IS NULL)
+		FROM reports WHERE reporter_id = $1`, reporterID).Scan(&result.TotalData, &result.UnseenOutcomes); err != nil {
+		return result, err
+	}
+
+	for rows.Next() {
+		var r Report
+		if err := r.scan(rows); err != nil {
+			return result, err
+		}
+
+		if r.Status != ReportStatusOpen {
+			taken := r.Status == ReportStatusResolved && r.Action != nil && *r.Action != ReportActionNone
+			r.ActionTaken = &taken
+		}
+		r.AssigneeID, r.Action, r.ResolutionNote, r.ClosedBy = nil, nil, nil, nil
+		result.Data = append(result.Data, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	if result.UnseenOutcomes > 0 {
+		_, err = db.Exec(`UPDATE reports SET outcome_seen_at = $2
+			WHERE reporter_id = $1 AND status <> 'open' AND outcome_seen_at IS NULL`, reporterID, time.Now())
+	}
+	return result, err
+}
+
+func requireAdmin(db *sql.DB, userID string) error {
+	admin, err := IsAdmin(db, userID)
+	if err != nil {
+		return err
+	}
+
+	if !admin {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// GetReportQueue lists reports for admins, oldest first. params.Status
+// picks the status, open unless given; assigneeID, when set, keeps the
+// reports assigned to that admin.
+func GetReportQueue(db *sql.DB, adminID, assigneeID string, params Params) (PayloadReports, error) {
+	result := PayloadReports{Data: []Report{}}
+	if err := requireAdmin(db, adminID); err != nil {
+		return result, err
+	}
+
+	status := params.Status
+	if status == "" {
+		status = ReportStatusOpen
+	}
+
+	limit, offset := params.Paging()
+	where := `status = $1 AND (NULLIF($2, '') IS NULL OR assignee_id = $2)`
+
+	rows, err := db.Query(`SELECT `+reportColumns+` FROM reports WHERE `+where+`
+		ORDER BY created_at, id LIMIT $3 OFFSET $4`, status, assigneeID, limit, offset)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM reports WHERE `+where, status, assigneeID).
+		Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	for rows.Next() {
+		var r Report
+		if err := r.scan(rows); err != nil {
+			return result, err
+		}
+		result.Data = append(result.Data, r)
+	}
+
+	return result, rows.Err()
+}
+
+// GetReport loads the report with its audit trail, for admins.
+func (r *Report) GetReport(db *sql.DB, adminID string) error {
+	if err := requireAdmin(db, adminID); err != nil {
+		return err
+	}
+
+	if err := r.scan(db.QueryRow(`SELECT `+reportColumns+` FROM reports WHERE id = $1`, r.ID)); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT actor_id, event, detail, created_at FROM report_events
+		WHERE report_id = $1 ORDER BY id`, r.ID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	r.Events = []ReportEvent{}
+	for rows.Next() {
+		var e ReportEvent
+		if err := rows.Scan(&e.ActorID, &e.Event, &e.Detail, &e.CreatedAt); err != nil {
+			return err
+		}
+		e.CreatedAt = e.CreatedAt.UTC().Add(time.Hour * 7)
+		r.Events = append(r.Events, e)
+	}
+
+	return rows.Err()
+}
+
+// openReport locks the open report for an admin action. It returns
+// sql.ErrNoRows when the report does not exist and ErrReportClosed when it
+// is no longer open.
+func (r *Report) openReport(tx *sql.Tx) error {
+	if err := r.scan(tx.QueryRow(`SELECT `+reportColumns+` FROM reports WHERE id = $1 FOR UPDATE`, r.ID)); err != nil {
+		return err
+	}
+
+	if r.Status != ReportStatusOpen {
+		return ErrReportClosed
+	}
+	return nil
+}
+
+// AssignReport gives the open report to assigneeID, who must be an admin.
+func (r *Report) AssignReport(db *sql.DB, adminID, assigneeID string) error {
+	if err := requireAdmin(db, adminID); err != nil {
+		return err
+	}
+
+	admin, err := IsAdmin(db, assigneeID)
+	if err != nil {
+		return err
+	}
+
+	if !admin {
+		return ErrInvalidAssignee
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := r.openReport(tx); err != nil {
+		return err
+	}
+
+	err = r.scan(tx.QueryRow(`UPDATE reports SET assignee_id = $2, updated_at = $3 WHERE id = $1
+		RETURNING `+reportColumns, r.ID, assigneeID, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if err := addReportEvent(tx, r.ID, adminID, "assigned", assigneeID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResolveReport closes the open report and applies action to its target.
+// Hiding applies to posts and comments; suspending applies to the reported
+// user or the author of the reported content.
+func (r *Report) ResolveReport(db *sql.DB, adminID, action, note string) error {
+	if err := requireAdmin(db, adminID); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := r.openReport(tx); err != nil {
+		return err
+	}
+
+	detail := action
+	switch action {
+	case ReportActionNone:
+	case ReportActionHide:
+		err = hideReportTarget(tx, r.TargetType, r.TargetID, adminID)
+		detail = "hid " + r.TargetType + " " + r.TargetID
+	case ReportActionSuspend:
+		var author string
+		author, err = reportTargetAuthor(tx, r.TargetType, r.TargetID)
+		if err == nil {
+			err = suspendUser(tx, author, adminID)
+		}
+		detail = "suspended user " + author
+	default:
+		err = ErrReportAction
+	}
+
+	if err == sql.ErrNoRows {
+		// The target was deleted in the meantime.
+		err = ErrReportAction
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := r.close(tx, adminID, ReportStatusResolved, action, note, detail); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DismissReport closes the open report without acting on its target.
+func (r *Report) DismissReport(db *sql.DB, adminID, note string) error {
+	if err := requireAdmin(db, adminID); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := r.openReport(tx); err != nil {
+		return err
+	}
+
+	if err := r.close(tx, adminID, ReportStatusDismissed, ReportActionNone, note, note); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Report) close(tx *sql.Tx, adminID, status, action, note, detail string) error {
+	err := r.scan(tx.QueryRow(`UPDATE reports SET status = $2, action = $3, resolution_note = NULLIF($4, ''),
+			closed_by = $5, closed_at = $6, updated_at = $6
+		WHERE id = $1
+		RETURNING `+reportColumns, r.ID, status, action, note, adminID, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if err := addReportEvent(tx, r.ID, adminID, status, detail); err != nil {
+		return err
+	}
+
+	// The reporter is not told which admin reviewed the report.
+	return notify(tx, notification{userID: r.ReporterID, kind: NotificationReport, groupKey: "report:" + r.ID,
+		reportID: &r.ID})
+}
+
+// hideReportTarget removes a post, keeping it out of its author's trash, or
+// hides a comment.
+func hideReportTarget(tx *sql.Tx, targetType, targetID, adminID string) error {
+	switch targetType {
+	case ReportTargetPost:
+		p := Post{ID: targetID}
+		var status string
+		err := tx.QueryRow(`UPDATE posts SET deleted_at = $2, deleted_by = $3
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING user_id, status`, targetID, time.Now(), adminID).Scan(&p.UserId, &status)
+		if err != nil {
+			return err
+		}
+		return p.emitPostWebhook(tx, status == PostStatusPublished, false)
+	case ReportTargetComment:
+		var c Comment
+		var previous string
+		err := c.scan(tx.QueryRow(`UPDATE comments SET status = 'hidden', moderated_by = $2, moderated_at = $3
+			FROM (SELECT id, status FROM comments WHERE id = $1 FOR UPDATE) previous
+			WHERE comments.id = previous.id AND comments.deleted_at IS NULL
+			RETURNING `+commentColumns+`, previous.status`, targetID, adminID, time.Now()), &previous)
+		if err != nil {
+			return err
+		}
+
+		// Like moderation, the comment goes away for everyone else.
+		if previous == CommentStatusVisible {
+			if err := announceComment(tx, CommentDeleted, c.PostId, c.ID); err != nil {
+				return err
+			}
+		}
+		return c.emitCommentWebhook(tx, WebhookCommentUpdated, c.UserId, &c)
+	default:
+		return ErrReportAction
+	}
+}
+
+// suspendUser suspends the account and ends its sessions, so it can neither
+// sign in nor refresh its tokens. Suspending a suspended user is a no-op.
+func suspendUser(tx *sql.Tx, userID, adminID string) error {
+	_, err := tx.Exec(`UPDATE users SET suspended_at = $2, suspended_by = $3
+		WHERE id = $1 AND suspended_at IS NULL`, userID, time.Now(), adminID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM sessions WHERE username = (SELECT username FROM users WHERE id = $1)`, userID)
+	return err
+}
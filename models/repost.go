@@ -0,0 +1,171 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	PostKindPost   = "post"
+	PostKindRepost = "repost"
+	PostKindQuote  = "quote"
+)
+
+var (
+	ErrAlreadyReposted   = errors.New("You have already reposted this post")
+	ErrRepostNotEditable = errors.New("Reposts cannot be edited")
+)
+
+// shareCounts selects the number of live reposts and quotes of posts.
+const shareCounts = `(SELECT COUNT(*) FROM posts shares WHERE shares.repost_of_id = posts.id AND shares.kind = 'repost'
+		AND shares.status = 'published' AND shares.deleted_at IS NULL),
+	(SELECT COUNT(*) FROM posts shares WHERE shares.repost_of_id = posts.id AND shares.kind = 'quote'
+		AND shares.status = 'published' AND shares.deleted_at IS NULL)`
+
+// originalAvailable matches posts that are not reposts, or reposts whose
+// original is still published. A repost of a deleted or unpublished post has
+// nothing to show, so only its author sees it; it comes back when the
+// original does. Quotes stay visible with the original left out.
+const originalAvailable = `(posts.kind <> 'repost' OR EXISTS (
+	SELECT 1 FROM posts shared WHERE shared.id = posts.repost_of_id
+		AND shared.status = 'published' AND shared.deleted_at IS NULL))`
+
+// resolveOriginal checks that the post being shared is published and sets
+// Kind. Sharing a repost shares its original instead, so chains of reposts
+// cannot form and reposting your own repost hits the one repost per post
+// limit.
+func (p *Post) resolveOriginal(tx *sql.Tx) error {
+	var kind string
+	var originalID *string
+	err := tx.QueryRow(`SELECT kind, repost_of_id FROM posts WHERE id = $1 AND status = 'published' AND deleted_at IS NULL`,
+		*p.RepostOfId).Scan(&kind, &originalID)
+	if err != nil {
+		return err
+	}
+
+	if kind == PostKindRepost {
+		if originalID == nil {
+			return sql.ErrNoRows
+		}
+
+		var one int
+		err := tx.QueryRow(`SELECT 1 FROM posts WHERE id = $1 AND status = 'published' AND deleted_at IS NULL`,
+			*originalID).Scan(&one)
+		if err != nil {
+			return err
+		}
+		p.RepostOfId = originalID
+	}
+
+	p.Kind = PostKindQuote
+	if p.Description == "" {
+		p.Kind = PostKindRepost
+		p.Status = PostStatusPublished
+		p.PublishAt = nil
+	}
+	return nil
+}
+
+// loadOriginals embeds the post each repost or quote shares, when viewerID
+// may still see it.
+func loadOriginals(db *sql.DB, posts []Post, viewerID string) error {
+	var ids []string
+	for _, p := range posts {
+		if p.RepostOfId != nil {
+			ids = append(ids, *p.RepostOfId)
+		}
+	}
+
+	originals := map[string]Post{}
+	if len(ids) > 0 {
+		rows, err := db.Query(`SELECT `+postColumns+` FROM posts
+			WHERE id = ANY($1) AND deleted_at IS NULL AND (status = 'published' OR user_id = $2)`,
+			pq.Array(ids), viewerID)
+		if err != nil {
+			return err
+		}
+
+		var found []Post
+		for rows.Next() {
+			var o Post
+			if err := o.scan(rows); err != nil {
+				rows.Close()
+				return err
+			}
+			found = append(found, o)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if err := loadAttachments(db, found); err != nil {
+			return err
+		}
+
+		if err := loadPolls(db, found, viewerID); err != nil {
+			return err
+		}
+
+		if err := loadLinkPreviews(db, found); err != nil {
+			return err
+		}
+
+		for _, o := range found {
+			originals[o.ID] = o
+		}
+	}
+
+	for i := range posts {
+		posts[i].Original = nil
+		posts[i].OriginalUnavailable = false
+		if posts[i].Kind == PostKindPost {
+			continue
+		}
+
+		if posts[i].RepostOfId == nil {
+			posts[i].OriginalUnavailable = true
+			continue
+		}
+
+		original, ok := originals[*posts[i].RepostOfId]
+		if !ok {
+			posts[i].OriginalUnavailable = true
+			continue
+		}
+		posts[i].Original = &original
+	}
+
+	return nil
+}
+
+// UndoRepost moves the user's repost of originalID to their trash. It
+// returns sql.ErrNoRows when there is no such repost.
+func UndoRepost(db *sql.DB, userID, originalID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	p := Post{UserId: userID}
+	var status string
+	err = tx.QueryRow(`UPDATE posts SET deleted_at = $1
+		WHERE user_id = $2 AND repost_of_id = $3 AND kind = 'repost' AND deleted_at IS NULL
+		RETURNING id, status`,
+		time.Now(), userID, originalID).Scan(&p.ID, &status)
+	if err != nil {
+		return err
+	}
+
+	if err := p.emitPostWebhook(tx, status == PostStatusPublished, false); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bayudha2/go-test-0/utils/diff"
+	"github.com/google/uuid"
+)
+
+var ErrRevisionNotFound = errors.New("Revision not found")
+
+type PostRevision struct {
+	Revision    int       `json:"revision"`
+	Description string    `json:"description"`
+	EditorId    string    `json:"editor_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type RevisionDiff struct {
+	From    int       `json:"from"`
+	To      int       `json:"to"`
+	Changes []diff.Op `json:"changes"`
+}
+
+// recordRevision stores next as the newest revision of the post. Posts
+// created before revisions were tracked first get their previous text stored
+// as revision 1. The caller must hold a row lock on the post.
+func recordRevision(tx *sql.Tx, postID, previous, next, editorID string) error {
+	_, err := tx.Exec(`INSERT INTO post_revisions(id, post_id, revision, description, editor_id, created_at)
+		SELECT $1, id, 1, $2, user_id, created_at FROM posts
+		WHERE id = $3 AND NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_id = $3)`,
+		uuid.New().String(), previous, postID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO post_revisions(id, post_id, revision, description, editor_id, created_at)
+		SELECT $1, $2, COALESCE(MAX(revision), 0) + 1, $3, $4, $5 FROM post_revisions
+		WHERE post_id = $2`,
+		uuid.New().String(), postID, next, editorID, time.Now())
+	return err
+}
+
+// canReviewRevisions reports whether viewerID may read the history of a post
+// written by ownerID: its author, moderators and admins.
+func canReviewRevisions(db *sql.DB, ownerID, viewerID string) (bool, error) {
+	if ownerID == viewerID {
+		return true, nil
+	}
+	return IsModerator(db, viewerID)
+}
+
+// GetRevisions lists every revision of the post, oldest first. A post that
+// was never edited has a single revision made from its current text.
+func (p *Post) GetRevisions(db *sql.DB, viewerID string) ([]PostRevision, error) {
+	if err := p.GetPost(db, viewerID); err != nil {
+		return nil, err
+	}
+
+	allowed, err := canReviewRevisions(db, p.UserId, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrUnauthorized
+	}
+
+	rows, err := db.Query(`SELECT revision, description, editor_id, created_at FROM post_revisions
+		WHERE post_id = $1 ORDER BY revision`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var r PostRevision
+		if err := rows.Scan(&r.Revision, &r.Description, &r.EditorId, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		r.CreatedAt = r.CreatedAt.UTC().Add(time.Hour * 7)
+		revisions = append(revisions, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		revisions = append(revisions, PostRevision{
+			Revision:    1,
+			Description: p.Description,
+			EditorId:    p.UserId,
+			CreatedAt:   p.CreatedAt,
+		})
+	}
+
+	return revisions, nil
+}
+
+// DiffRevisions returns the word-level changes between two revisions.
+func (p *Post) DiffRevisions(db *sql.DB, viewerID string, from, to int) (RevisionDiff, error) {
+	result := RevisionDiff{From: from, To: to}
+
+	revisions, err := p.GetRevisions(db, viewerID)
+	if err != nil {
+		return result, err
+	}
+
+	var before, after *PostRevision
+	for i := range revisions {
+		if revisions[i].Revision == from {
+			before = &revisions[i]
+		}
+		if revisions[i].Revision == to {
+			after = &revisions[i]
+		}
+	}
+
+	if before == nil || after == nil {
+		return result, ErrRevisionNotFound
+	}
+
+	result.Changes = diff.Words(before.Description, after.Description)
+	return result, nil
+}
+
+// RestoreRevision makes an older revision the current text of the post. The
+// restore is recorded as a new revision so no history is lost. Only the
+// author and admins may restore.
+func (p *Post) RestoreRevision(db *sql.DB, revision int, actorID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var ownerID, current string
+	err = tx.QueryRow("SELECT user_id, description FROM posts WHERE id=$1 AND deleted_at IS NULL FOR UPDATE", p.ID).Scan(&ownerID, &current)
+	if err != nil {
+		return err
+	}
+
+	if ownerID != actorID {
+		admin, err := IsAdmin(db, actorID)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return ErrUnauthorized
+		}
+	}
+
+	var restored string
+	err = tx.QueryRow("SELECT description FROM post_revisions WHERE post_id=$1 AND revision=$2", p.ID, revision).Scan(&restored)
+	if err == sql.ErrNoRows && revision == 1 {
+		restored = current
+	} else if err == sql.ErrNoRows {
+		return ErrRevisionNotFound
+	} else if err != nil {
+		return err
+	}
+
+	edits := 0
+	if restored != current {
+		edits = 1
+		if err := recordRevision(tx, p.ID, current, restored, actorID); err != nil {
+			return err
+		}
+	}
+
+	err = p.scan(tx.QueryRow(`UPDATE posts SET description=$1, updated_at=$2, edit_count = edit_count + $3
+		WHERE id=$4
+		RETURNING `+postColumns,
+		restored, time.Now(), edits, p.ID))
+	if err != nil {
+		return err
+	}
+
+	if err := p.syncEntities(tx); err != nil {
+		return err
+	}
+
+	published := p.Status == PostStatusPublished
+	if err := p.emitPostWebhook(tx, published, published); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Text search configurations that posts can be indexed with.
+var searchConfigs = map[string]bool{
+	"indonesian": true,
+	"simple":     true,
+	"english":    true,
+}
+
+// TextSearchConfig is the Postgres text search configuration used to index
+// and query post descriptions.
+var TextSearchConfig = "simple"
+
+// ts_headline wraps matches with these markers, they are swapped for <mark>
+// tags once the rest of the snippet has been escaped.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+var headlineOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MaxWords=20, MinWords=5`, headlineStart, headlineStop)
+
+func SetTextSearchConfig(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+
+	if !searchConfigs[name] {
+		return fmt.Errorf("unsupported text search config: %s", name)
+	}
+
+	TextSearchConfig = name
+	return nil
+}
+
+// ResyncSearchConfig re-indexes posts that were stored with a different text
+// search configuration than the one currently in use.
+func ResyncSearchConfig(db *sql.DB) error {
+	_, err := db.Exec(`UPDATE posts SET search_config = $1::regconfig
+		WHERE search_config <> $1::regconfig`, TextSearchConfig)
+	return err
+}
+
+// BuildTsQuery turns user input into a to_tsquery expression. Quoted text is
+// matched as a phrase, a trailing * makes a prefix match and a leading -
+// excludes the term. Everything that is not a letter or a digit is dropped so
+// the result is always a valid tsquery.
+func BuildTsQuery(search string) string {
+	var terms []string
+
+	for _, token := range splitSearch(search) {
+		negate := false
+		if strings.HasPrefix(token.text, "-") && !token.phrase {
+			negate = true
+			token.text = strings.TrimLeft(token.text, "-")
+		}
+
+		prefix := false
+		if strings.HasSuffix(token.text, "*") && !token.phrase {
+			prefix = true
+			token.text = strings.TrimRight(token.text, "*")
+		}
+
+		words := strings.FieldsFunc(token.text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+
+		lexemes := make([]string, len(words))
+		for i, word := range words {
+			lexemes[i] = "'" + strings.ToLower(word) + "'"
+		}
+		if prefix {
+			lexemes[len(lexemes)-1] += ":*"
+		}
+
+		term := strings.Join(lexemes, " <-> ")
+		if len(lexemes) > 1 {
+			term = "(" + term + ")"
+		}
+		if negate {
+			term = "!" + term
+		}
+
+		terms = append(terms, term)
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+type searchToken struct {
+	text   string
+	phrase bool
+}
+
+func splitSearch(search string) []searchToken {
+	var tokens []searchToken
+	var current strings.Builder
+	inQuote := false
+
+	flush := func(phrase bool) {
+		if current.Len() > 0 {
+			tokens = append(tokens, searchToken{text: current.String(), phrase: phrase})
+			current.Reset()
+		}
+	}
+
+	for _, r := range search {
+		switch {
+		case r == '"':
+			flush(inQuote)
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			flush(false)
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush(inQuote)
+
+	return tokens
+}
+
+func formatHeadline(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, headlineStart, "<mark>")
+	return strings.ReplaceAll(escaped, headlineStop, "</mark>")
+}
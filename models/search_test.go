@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestBuildTsQuery(t *testing.T) {
+	cases := []struct {
+		search   string
+		expected string
+	}{
+		{"", ""},
+		{"kopi", "'kopi'"},
+		{"Kopi Susu", "'kopi' & 'susu'"},
+		{`"kopi susu" gula`, "('kopi' <-> 'susu') & 'gula'"},
+		{"kop*", "'kop':*"},
+		{"kopi -teh", "'kopi' & !'teh'"},
+		{"it's", "('it' <-> 's')"},
+		{"'); DROP TABLE posts; --", "'drop' & 'table' & 'posts'"},
+		{`"unterminated phrase`, "('unterminated' <-> 'phrase')"},
+		{"*** --- !!!", ""},
+	}
+
+	for _, c := range cases {
+		if got := BuildTsQuery(c.search); got != c.expected {
+			t.Errorf("BuildTsQuery(%q): expected %q. Got %q", c.search, c.expected, got)
+		}
+	}
+}
+
+func TestFormatHeadline(t *testing.T) {
+	got := formatHeadline("<b>" + headlineStart + "kopi" + headlineStop + "</b>")
+	expected := "&lt;b&gt;<mark>kopi</mark>&lt;/b&gt;"
+
+	if got != expected {
+		t.Errorf("Expected headline to be %q. Got %q", expected, got)
+	}
+}
+
+func TestSetTextSearchConfig(t *testing.T) {
+	defer func() { TextSearchConfig = "simple" }()
+
+	if err := SetTextSearchConfig("English"); err != nil || TextSearchConfig != "english" {
+		t.Errorf("Expected config to be english. Got %s (%v)", TextSearchConfig, err)
+	}
+
+	if err := SetTextSearchConfig("klingon"); err == nil {
+		t.Errorf("Expected an error for an unsupported config")
+	}
+}
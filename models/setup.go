@@ -8,11 +8,15 @@ import (
 
 var DB *sql.DB
 
+// ConnectionString is what DB was opened with, for connections kept out of
+// the pool such as the ones that LISTEN.
+var ConnectionString string
+
 func ConnectDatabase(user, password, dbname string) {
-	connectionString := fmt.Sprintf("dbname=%s user=%s password=%s host=localhost sslmode=disable", dbname, user, password)
+	ConnectionString = fmt.Sprintf("dbname=%s user=%s password=%s host=localhost sslmode=disable", dbname, user, password)
 
 	var err error
-	DB, err = sql.Open("postgres", connectionString)
+	DB, err = sql.Open("postgres", ConnectionString)
 	if err != nil {
 		log.Fatal(err)
 	}
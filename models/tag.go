@@ -0,0 +1,194 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Tag struct {
+	Name  string  `json:"name"`
+	Uses  int     `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([A-Za-z0-9_.]+)`)
+)
+
+// ParseHashtags returns the distinct #tags in text, lower-cased and in order
+// of first appearance. Tags made only of digits are ignored.
+func ParseHashtags(text string) []string {
+	var tags []string
+	seen := map[string]bool{}
+
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToLower(match[1])
+		if len(tag) > 50 || strings.Trim(tag, "0123456789") == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// ParseMentions returns the distinct @usernames in text in order of first
+// appearance.
+func ParseMentions(text string) []string {
+	var usernames []string
+	seen := map[string]bool{}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.TrimRight(match[1], ".")
+		if username == "" || len(username) > 50 || seen[username] {
+			continue
+		}
+
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames
+}
+
+// GetPostsByTag lists the published posts with the tag, leaving out the
+// sensitive posts of others when viewerID hides them.
+func GetPostsByTag(db *sql.DB, tag, viewerID string, params Params) (PayloadPosts, error) {
+	var result PayloadPosts
+	prefs, err := GetPreferences(db, viewerID)
+	if err != nil {
+		return result, err
+	}
+
+	limit, offset := params.Paging()
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+
+	rows, err := db.Query(`
+		SELECT `+postColumns+`
+		FROM posts
+		JOIN post_tags pt ON pt.post_id = posts.id
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE t.name = $1 AND posts.status = 'published' AND posts.deleted_at IS NULL
+			AND NOT (posts.sensitive AND posts.user_id <> $4 AND $5 = 'hide')
+		ORDER BY posts.created_at DESC LIMIT $2 OFFSET $3
+	`, tag, limit, offset, viewerID, prefs.SensitiveContent)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	count := `SELECT COUNT(*) FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		JOIN posts ON posts.id = pt.post_id
+		WHERE t.name = $1 AND posts.status = 'published' AND posts.deleted_at IS NULL
+			AND NOT (posts.sensitive AND posts.user_id <> $2 AND $3 = 'hide')`
+	if err := db.QueryRow(count, tag, viewerID, prefs.SensitiveContent).Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	var posts []Post
+	for rows.Next() {
+		var p Post
+		if err := p.scan(rows); err != nil {
+			return result, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	if err := loadRelations(db, posts, viewerID); err != nil {
+		return result, err
+	}
+
+	result.Data = posts
+	return result, nil
+}
+
+// GetTrendingTags ranks the tags used by posts and comments within window.
+// Every use adds a weight that halves each halfLife, so recent activity counts
+// more than activity near the start of the window. Tags of a post written
+// before it was published count from when it was published.
+func GetTrendingTags(db *sql.DB, window, halfLife time.Duration, limit int) ([]Tag, error) {
+	rows, err := db.Query(`
+		SELECT t.name, COUNT(*) AS uses,
+			SUM(EXP(-LN(2) * EXTRACT(EPOCH FROM (NOW() - u.created_at)) / $2::float8)) AS score
+		FROM (
+			SELECT pt.tag_id, GREATEST(pt.created_at, posts.publish_at) AS created_at FROM post_tags pt
+			JOIN posts ON posts.id = pt.post_id
+			WHERE GREATEST(pt.created_at, posts.publish_at) > NOW() - $1::float8 * INTERVAL '1 second'
+				AND posts.status = 'published' AND posts.deleted_at IS NULL
+			UNION ALL
+			SELECT ct.tag_id, GREATEST(ct.created_at, posts.publish_at) AS created_at FROM comment_tags ct
+			JOIN comments c ON c.id = ct.comment_id
+			JOIN posts ON posts.id = c.post_id
+			WHERE GREATEST(ct.created_at, posts.publish_at) > NOW() - $1::float8 * INTERVAL '1 second'
+				AND posts.status = 'published' AND posts.deleted_at IS NULL
+				AND c.deleted_at IS NULL AND c.status = 'visible'
+		) u
+		JOIN tags t ON t.id = u.tag_id
+		GROUP BY t.name
+		ORDER BY score DESC, uses DESC, t.name LIMIT $3
+	`, window.Seconds(), halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.Name, &t.Uses, &t.Score); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+func syncPostTags(tx *sql.Tx, postID string, text string) error {
+	return syncTags(tx, "post_tags", "post_id", postID, ParseHashtags(text))
+}
+
+func syncCommentTags(tx *sql.Tx, commentID string, text string) error {
+	return syncTags(tx, "comment_tags", "comment_id", commentID, ParseHashtags(text))
+}
+
+// syncTags makes the rows of table owned by id match names, keeping the
+// original created_at of tags that are still present.
+func syncTags(tx *sql.Tx, table, column, id string, names []string) error {
+	remove := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1
+		AND tag_id NOT IN (SELECT id FROM tags WHERE name = ANY($2))`, table, column)
+	if _, err := tx.Exec(remove, id, pq.Array(names)); err != nil {
+		return err
+	}
+
+	insert := fmt.Sprintf(`INSERT INTO %s(%s, tag_id, created_at) VALUES($1, $2, $3)
+		ON CONFLICT DO NOTHING`, table, column)
+	for _, name := range names {
+		var tagID string
+		err := tx.QueryRow(`INSERT INTO tags(id, name, created_at) VALUES($1, $2, $3)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id`, uuid.New().String(), name, time.Now()).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(insert, id, tagID, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHashtags(t *testing.T) {
+	got := ParseHashtags("#Kopi pagi #kopi #senja_2023 a#b ##double #123 &#39; #kopi.")
+	expected := []string{"kopi", "senja_2023"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected hashtags %v. Got %v", expected, got)
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	got := ParseMentions("halo @budi, @ani.s. dan @budi lagi. email@domain.com @")
+	expected := []string{"budi", "ani.s"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected mentions %v. Got %v", expected, got)
+	}
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+)
+
+const (
+	CommentSortNewest = "newest"
+	CommentSortOldest = "oldest"
+	// CommentSortTop puts the comments with the most replies first.
+	CommentSortTop = "top"
+)
+
+const (
+	DefaultThreadDepth = 5
+	MaxThreadDepth     = 20
+	// maxThreadComments bounds how many comments one tree holds. Shallow
+	// comments are kept first, then the oldest; the rest can be read as
+	// replies.
+	maxThreadComments = 1000
+)
+
+var (
+	ErrInvalidSort  = errors.New("sort must be newest, oldest or top")
+	ErrInvalidDepth = errors.New("max_depth must be between 0 and 20")
+)
+
+// ParseCommentSorts reads a comma separated list of sort modes, one per
+// level of a thread starting at the top. The last mode also applies to the
+// levels below it. An empty spec sorts every level oldest first.
+func ParseCommentSorts(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return []string{CommentSortOldest}, nil
+	}
+
+	var sorts []string
+	for _, mode := range strings.Split(spec, ",") {
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		switch mode {
+		case CommentSortNewest, CommentSortOldest, CommentSortTop:
+			sorts = append(sorts, mode)
+		default:
+			return nil, ErrInvalidSort
+		}
+	}
+
+	return sorts, nil
+}
+
+// sortComments orders comments by mode. Ties are broken by age and id so
+// the order is stable across requests.
+func sortComments(comments []Comment, mode string) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+		if mode == CommentSortTop && a.ReplyCount != b.ReplyCount {
+			return a.ReplyCount > b.ReplyCount
+		}
+
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			if mode == CommentSortOldest {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// GetCommentTree returns the comments of the post that viewerID may see as
+// a thread: top-level comments with their replies nested in Replies, down
+// to maxDepth levels of replies. Comments at the deepest level still report
+// their ReplyCount. Deleted comments that still have replies are kept as
+// tombstones. Each level is sorted by the matching entry of sorts.
+// TotalData is the number of comments in the tree. When the tree reaches
+// its size limit, comments missing some of their replies have
+// RepliesTruncated set. It returns sql.ErrNoRows when viewerID may not see
+// the post.
+func GetCommentTree(db *sql.DB, postID, viewerID string, maxDepth int, sorts []string) (PayloadComments, error) {
+	var result PayloadComments
+	if maxDepth < 0 || maxDepth > MaxThreadDepth {
+		return result, ErrInvalidDepth
+	}
+
+	if err := checkPostShown(db, postID, viewerID); err != nil {
+		return result, err
+	}
+
+	moderator, err := IsModerator(db, viewerID)
+	if err != nil {
+		return result, err
+	}
+
+	if len(sorts) == 0 {
+		sorts = []string{CommentSortOldest}
+	}
+
+	rows, err := db.Query(`WITH RECURSIVE tree AS (
+			SELECT comments.*, 0 AS depth FROM comments
+			WHERE comments.post_id = $1 AND comments.parent_id IS NULL AND `+shownComment("$4")+`
+				AND `+commentVisibleTo("$4", "$5")+`
+			UNION ALL
+			SELECT comments.*, tree.depth + 1 FROM comments
+			JOIN tree ON comments.parent_id = tree.id
+			WHERE tree.depth < $2 AND `+commentVisibleTo("$4", "$5")+`
+				AND (comments.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments child WHERE child.parent_id = comments.id))
+		)
+		SELECT `+commentColumns+`, comments.depth FROM tree comments
+		ORDER BY comments.depth, comments.created_at, comments.id LIMIT $3`,
+		postID, maxDepth, maxThreadComments, viewerID, moderator)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	var top []Comment
+	children := map[string][]Comment{}
+	depths := map[string]int{}
+	lastDepth := 0
+	for rows.Next() {
+		var c Comment
+		if err := c.scan(rows, &lastDepth); err != nil {
+			return result, err
+		}
+
+		c.tombstone()
+		if c.CommentId == nil {
+			top = append(top, c)
+		} else {
+			children[*c.CommentId] = append(children[*c.CommentId], c)
+		}
+		depths[c.ID] = lastDepth
+		result.TotalData++
+	}
+
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	// At the limit the oldest comments of the last level read are kept;
+	// their parents, and the comments of that level whose replies were not
+	// read at all, are marked.
+	var truncated func(c *Comment) bool
+	if result.TotalData == maxThreadComments {
+		truncated = func(c *Comment) bool {
+			switch depths[c.ID] {
+			case lastDepth - 1:
+				return c.ReplyCount > len(children[c.ID])
+			case lastDepth:
+				return lastDepth < maxDepth && c.ReplyCount > 0
+			}
+			return false
+		}
+	}
+
+	result.Data = nestComments(top, children, sorts, 0, truncated)
+	return result, nil
+}
+
+// nestComments sorts the comments of one level and fills in their replies.
+// truncated, when set, reports which comments are missing replies.
+func nestComments(level []Comment, children map[string][]Comment, sorts []string, depth int,
+	truncated func(c *Comment) bool) []Comment {
+	mode := sorts[len(sorts)-1]
+	if depth < len(sorts) {
+		mode = sorts[depth]
+	}
+
+	sortComments(level, mode)
+	for i := range level {
+		if truncated != nil {
+			level[i].RepliesTruncated = truncated(&level[i])
+		}
+		if replies, ok := children[level[i].ID]; ok {
+			level[i].Replies = nestComments(replies, children, sorts, depth+1, truncated)
+		}
+	}
+
+	return level
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommentSorts(t *testing.T) {
+	got, err := ParseCommentSorts(" Top, oldest")
+	if err != nil || !reflect.DeepEqual(got, []string{"top", "oldest"}) {
+		t.Errorf("Expected [top oldest]. Got %v, %v", got, err)
+	}
+
+	if got, _ := ParseCommentSorts(""); !reflect.DeepEqual(got, []string{"oldest"}) {
+		t.Errorf("Expected oldest by default. Got %v", got)
+	}
+
+	if _, err := ParseCommentSorts("top,,newest"); err != ErrInvalidSort {
+		t.Errorf("Expected an empty mode to be rejected. Got %v", err)
+	}
+}
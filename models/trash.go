@@ -0,0 +1,189 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TrashRetention is how long deleted posts and comments can be restored
+// before the purge job removes them for good.
+var TrashRetention = 30 * 24 * time.Hour
+
+var ErrNotInTrash = errors.New("Not found in trash")
+
+type Trash struct {
+	Posts     []Post    `json:"posts"`
+	Comments  []Comment `json:"comments"`
+	Retention string    `json:"retention"`
+}
+
+// retentionCutoff is the oldest deletion time that can still be restored.
+func retentionCutoff() time.Time {
+	return time.Now().Add(-TrashRetention)
+}
+
+// GetTrash lists the posts and comments the user deleted within the
+// retention window, most recently deleted first. Content removed by someone
+// else is left out. Deleted comments only stay here as tombstones; those
+// without replies are removed right away.
+func GetTrash(db *sql.DB, userID string) (Trash, error) {
+	trash := Trash{Posts: []Post{}, Comments: []Comment{}, Retention: TrashRetention.String()}
+
+	rows, err := db.Query(`SELECT `+postColumns+` FROM posts
+		WHERE user_id = $1 AND deleted_at > $2 AND (deleted_by IS NULL OR deleted_by = user_id)
+		ORDER BY deleted_at DESC`, userID, retentionCutoff())
+	if err != nil {
+		return trash, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Post
+		if err := p.scan(rows); err != nil {
+			return trash, err
+		}
+		trash.Posts = append(trash.Posts, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return trash, err
+	}
+
+	commentRows, err := db.Query(`SELECT `+commentColumns+` FROM comments
+		WHERE user_id = $1 AND deleted_at > $2 AND (deleted_by IS NULL OR deleted_by = user_id)
+		ORDER BY deleted_at DESC`, userID, retentionCutoff())
+	if err != nil {
+		return trash, err
+	}
+
+	defer commentRows.Close()
+
+	for commentRows.Next() {
+		var c Comment
+		if err := c.scan(commentRows); err != nil {
+			return trash, err
+		}
+		trash.Comments = append(trash.Comments, c)
+	}
+
+	return trash, commentRows.Err()
+}
+
+// RestorePost takes the post out of its author's trash. Posts removed by a
+// moderator are not in the trash.
+func (p *Post) RestorePost(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow(`UPDATE posts SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at > $3 AND (deleted_by IS NULL OR deleted_by = user_id)
+		RETURNING `+postColumns, p.ID, p.UserId, retentionCutoff()))
+	if err == sql.ErrNoRows {
+		return ErrNotInTrash
+	}
+	if isUniqueViolation(err) {
+		return ErrAlreadyReposted
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := p.emitPostWebhook(tx, false, p.Status == PostStatusPublished); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return p.loadRelations(db, p.UserId)
+}
+
+// RestoreComment takes the comment out of its author's trash. It stays
+// hidden while its post is deleted. Comments deleted by the post's author
+// or a moderator are not in the trash.
+func (p *Comment) RestoreComment(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow(`UPDATE comments SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at > $3 AND (deleted_by IS NULL OR deleted_by = user_id)
+		RETURNING `+commentColumns, p.ID, p.UserId, retentionCutoff()))
+	if err == sql.ErrNoRows {
+		return ErrNotInTrash
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := p.emitCommentWebhook(tx, WebhookCommentCreated, p.UserId, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeTrash hard-deletes posts whose retention window has passed, with
+// their comments, then the tombstones left without replies from the leaves
+// of each thread upwards so no parent_id is left dangling. A tombstone that
+// still has replies is kept, past its retention too.
+func PurgeTrash(db *sql.DB) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow("SELECT pg_try_advisory_xact_lock(hashtext('purge_trash'))").Scan(&locked); err != nil {
+		return 0, err
+	}
+
+	if !locked {
+		return 0, nil
+	}
+
+	cutoff := retentionCutoff()
+	var purged int64
+
+	res, err := tx.Exec(`DELETE FROM comments WHERE post_id IN (
+		SELECT id FROM posts WHERE deleted_at <= $1)`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	purged += n
+
+	res, err = tx.Exec("DELETE FROM posts WHERE deleted_at <= $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, _ = res.RowsAffected()
+	purged += n
+
+	for {
+		res, err = tx.Exec(`DELETE FROM comments c WHERE c.deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM comments child WHERE child.parent_id = c.id)`)
+		if err != nil {
+			return 0, err
+		}
+
+		n, _ = res.RowsAffected()
+		if n == 0 {
+			break
+		}
+		purged += n
+	}
+
+	return purged, tx.Commit()
+}
@@ -13,9 +13,18 @@ type User struct {
 	Fullname  string    `json:"fullname" validate:"required"`
 	Email     string    `json:"email" validate:"required,email"`
 	Password  string    `json:"password" validate:"required,min=8"`
+	Role      string    `json:"role,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+	// SuspendedAt is set while an admin has suspended the account.
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
 }
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 func (p *User) CreateUser(db *sql.DB) error {
 	_, err := db.Exec("INSERT INTO users(id, fullname, username, password, email, created_at) VALUES($1, $2, $3, $4, $5, $6) RETURNING id", uuid.New().String(), p.Fullname, p.Username, p.Password, p.Email, time.Now())
 
@@ -26,5 +35,28 @@ func (p *User) CreateUser(db *sql.DB) error {
 }
 
 func (p *User) GetUser(db *sql.DB) error {
-	return db.QueryRow("SELECT * FROM users WHERE username=$1", p.Username).Scan(&p.ID, &p.Fullname, &p.Username, &p.Password, &p.Email, &p.CreatedAt)
+	return db.QueryRow("SELECT id, fullname, username, password, email, role, created_at, suspended_at FROM users WHERE username=$1", p.Username).Scan(&p.ID, &p.Fullname, &p.Username, &p.Password, &p.Email, &p.Role, &p.CreatedAt, &p.SuspendedAt)
+}
+
+// GetUserRole returns the role of the user, or RoleUser when the user does
+// not exist.
+func GetUserRole(db *sql.DB, userID string) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM users WHERE id=$1", userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return RoleUser, nil
+	}
+	return role, err
+}
+
+func IsAdmin(db *sql.DB, userID string) (bool, error) {
+	role, err := GetUserRole(db, userID)
+	return role == RoleAdmin, err
+}
+
+// IsModerator reports whether the user can moderate content, which admins
+// can too.
+func IsModerator(db *sql.DB, userID string) (bool, error) {
+	role, err := GetUserRole(db, userID)
+	return role == RoleModerator || role == RoleAdmin, err
 }
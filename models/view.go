@@ -0,0 +1,228 @@
+package models
+
+import (
+	"container/list"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// maxTrackedViewers bounds the memory used to remember recent viewers.
+	// Once reached, the viewers seen longest ago are forgotten, so they may
+	// be counted again within the window.
+	maxTrackedViewers = 100000
+	viewFlushBatch    = 1000
+)
+
+// Views counts the views of posts for the whole process.
+var Views = NewViewCounter(30 * time.Minute)
+
+type viewKey struct {
+	postID string
+	day    string
+}
+
+// seenView is when a viewer of a post was last counted.
+type seenView struct {
+	key string
+	at  time.Time
+}
+
+// ViewCounter adds up post views in memory until they are flushed to the
+// post_views table, so reading a post does not write to the database. A
+// viewer is counted once per post within Window.
+type ViewCounter struct {
+	Window time.Duration
+
+	mu         sync.Mutex
+	maxViewers int
+	// seen indexes the seenView elements of order, which is oldest first.
+	seen    map[string]*list.Element
+	order   *list.List
+	pending map[viewKey]int64
+}
+
+func NewViewCounter(window time.Duration) *ViewCounter {
+	return &ViewCounter{
+		Window:     window,
+		maxViewers: maxTrackedViewers,
+		seen:       map[string]*list.Element{},
+		order:      list.New(),
+		pending:    map[viewKey]int64{},
+	}
+}
+
+// viewDay is the day a view at t is counted on, in the same UTC+7 the API
+// shows times in.
+func viewDay(t time.Time) string {
+	return t.UTC().Add(time.Hour * 7).Format("2006-01-02")
+}
+
+// Record counts a view of the post by viewerID at now, unless the same
+// viewer was counted within the window. It reports whether it counted.
+func (c *ViewCounter) Record(postID, viewerID string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := postID + "/" + viewerID
+	if el, ok := c.seen[key]; ok {
+		seen := el.Value.(*seenView)
+		if now.Sub(seen.at) < c.Window {
+			return false
+		}
+
+		seen.at = now
+		c.order.MoveToBack(el)
+	} else {
+		c.prune(now)
+		for c.order.Len() >= c.maxViewers {
+			c.forget(c.order.Front())
+		}
+
+		c.seen[key] = c.order.PushBack(&seenView{key: key, at: now})
+	}
+
+	c.pending[viewKey{postID, viewDay(now)}]++
+	return true
+}
+
+// prune forgets the viewers counted longer than the window ago.
+func (c *ViewCounter) prune(now time.Time) {
+	for el := c.order.Front(); el != nil && now.Sub(el.Value.(*seenView).at) >= c.Window; el = c.order.Front() {
+		c.forget(el)
+	}
+}
+
+func (c *ViewCounter) forget(el *list.Element) {
+	delete(c.seen, el.Value.(*seenView).key)
+	c.order.Remove(el)
+}
+
+// pendingFor returns the views of the post that are not flushed yet, by day.
+func (c *ViewCounter) pendingFor(postID string) map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	views := map[string]int64{}
+	for key, n := range c.pending {
+		if key.postID == postID {
+			views[key.day] += n
+		}
+	}
+	return views
+}
+
+// Flush adds the pending views to post_views in batches. Views that could
+// not be written are kept for the next flush. Views of posts that were
+// purged in the meantime are dropped.
+func (c *ViewCounter) Flush(db *sql.DB) error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[viewKey]int64{}
+	c.prune(time.Now())
+	c.mu.Unlock()
+
+	var postIDs, days []string
+	var counts []int64
+	var err error
+	for key, n := range pending {
+		postIDs = append(postIDs, key.postID)
+		days = append(days, key.day)
+		counts = append(counts, n)
+	}
+
+	for start := 0; start < len(postIDs); start += viewFlushBatch {
+		end := start + viewFlushBatch
+		if end > len(postIDs) {
+			end = len(postIDs)
+		}
+
+		_, err = db.Exec(`INSERT INTO post_views(post_id, day, views)
+			SELECT v.post_id, v.day, v.views
+			FROM unnest($1::varchar[], $2::date[], $3::bigint[]) AS v(post_id, day, views)
+			WHERE EXISTS (SELECT 1 FROM posts WHERE posts.id = v.post_id)
+			ON CONFLICT (post_id, day) DO UPDATE SET views = post_views.views + EXCLUDED.views`,
+			pq.Array(postIDs[start:end]), pq.Array(days[start:end]), pq.Array(counts[start:end]))
+		if err != nil {
+			c.restore(postIDs[start:], days[start:], counts[start:])
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ViewCounter) restore(postIDs, days []string, counts []int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range postIDs {
+		c.pending[viewKey{postIDs[i], days[i]}] += counts[i]
+	}
+}
+
+// DailyViews is the number of views of a post on one day.
+type DailyViews struct {
+	Date  string `json:"date"`
+	Views int64  `json:"views"`
+}
+
+type PostViewStats struct {
+	Total int64        `json:"total"`
+	Daily []DailyViews `json:"daily"`
+}
+
+// GetViewStats returns the total views of the post and its views on each of
+// the last days days, oldest first. Only the author may see them.
+func (p *Post) GetViewStats(db *sql.DB, viewerID string, days int) (PostViewStats, error) {
+	var stats PostViewStats
+	if err := p.GetPost(db, viewerID); err != nil {
+		return stats, err
+	}
+
+	if p.UserId != viewerID {
+		return stats, ErrUnauthorized
+	}
+
+	byDay := Views.pendingFor(p.ID)
+	for _, n := range byDay {
+		stats.Total += n
+	}
+
+	now := time.Now()
+	from := viewDay(now.AddDate(0, 0, 1-days))
+	rows, err := db.Query(`SELECT to_char(day, 'YYYY-MM-DD'), views FROM post_views WHERE post_id = $1`, p.ID)
+	if err != nil {
+		return stats, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var n int64
+		if err := rows.Scan(&day, &n); err != nil {
+			return stats, err
+		}
+
+		stats.Total += n
+		if day >= from {
+			byDay[day] += n
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	stats.Daily = make([]DailyViews, days)
+	for i := range stats.Daily {
+		day := viewDay(now.AddDate(0, 0, i+1-days))
+		stats.Daily[i] = DailyViews{Date: day, Views: byDay[day]}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestViewCounterDeduplicates(t *testing.T) {
+	c := NewViewCounter(time.Minute)
+	now := time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)
+
+	if !c.Record("post", "a", now) {
+		t.Errorf("Expected the first view to be counted")
+	}
+
+	if c.Record("post", "a", now.Add(30*time.Second)) {
+		t.Errorf("Expected a view within the window not to be counted")
+	}
+
+	c.Record("post", "b", now)
+	c.Record("other", "a", now)
+	c.Record("post", "a", now.Add(time.Minute))
+
+	// 20:00 UTC is already the next day in UTC+7.
+	got := c.pendingFor("post")
+	if len(got) != 1 || got["2026-10-20"] != 3 {
+		t.Errorf("Expected 3 views of the post on 2026-10-20. Got %v", got)
+	}
+}
+
+func TestViewCounterForgetsOldestViewers(t *testing.T) {
+	c := NewViewCounter(time.Minute)
+	c.maxViewers = 3
+	now := time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)
+
+	for i, viewer := range []string{"a", "b", "c", "d"} {
+		c.Record("post", viewer, now.Add(time.Duration(i)*time.Second))
+	}
+
+	if len(c.seen) != 3 || c.order.Len() != 3 {
+		t.Fatalf("Expected 3 tracked viewers. Got %d", len(c.seen))
+	}
+
+	if c.Record("post", "d", now.Add(10*time.Second)) {
+		t.Errorf("Expected a recent viewer to still be deduplicated")
+	}
+
+	if !c.Record("post", "a", now.Add(10*time.Second)) {
+		t.Errorf("Expected the oldest viewer to be forgotten")
+	}
+
+	if _, ok := c.seen["post/b"]; ok || len(c.seen) != 3 {
+		t.Errorf("Expected b to make room for a. Got %d viewers", len(c.seen))
+	}
+
+	c.Record("post", "e", now.Add(2*time.Minute))
+	if len(c.seen) != 1 {
+		t.Errorf("Expected expired viewers to be pruned. Got %d", len(c.seen))
+	}
+}
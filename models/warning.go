@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// checkContentWarning tidies the warning label. A post with a label is
+// sensitive even when Sensitive was not set.
+func (p *Post) checkContentWarning() {
+	if p.ContentWarning != nil {
+		label := strings.TrimSpace(*p.ContentWarning)
+		p.ContentWarning = &label
+		if label == "" {
+			p.ContentWarning = nil
+		}
+	}
+
+	if p.ContentWarning != nil {
+		p.Sensitive = true
+	}
+}
+
+// annotateSensitive sets ContentFilter on the sensitive posts, and shared
+// originals, that viewerID did not write, following the viewer's
+// preference. Listings that can leave posts out do so in their query; this
+// covers single posts, bookmarks and shared posts.
+func annotateSensitive(db *sql.DB, posts []Post, viewerID string) error {
+	var flagged []*Post
+	for i := range posts {
+		if posts[i].Sensitive && posts[i].UserId != viewerID {
+			flagged = append(flagged, &posts[i])
+		}
+		if o := posts[i].Original; o != nil && o.Sensitive && o.UserId != viewerID {
+			flagged = append(flagged, o)
+		}
+	}
+
+	if len(flagged) == 0 {
+		return nil
+	}
+
+	prefs, err := GetPreferences(db, viewerID)
+	if err != nil {
+		return err
+	}
+
+	if prefs.SensitiveContent == SensitiveShow {
+		return nil
+	}
+
+	for _, p := range flagged {
+		p.ContentFilter = prefs.SensitiveContent
+	}
+	return nil
+}
+
+// ForceContentWarning flags the post as sensitive on behalf of a moderator.
+// Its author cannot remove a forced warning.
+func (p *Post) ForceContentWarning(db *sql.DB, moderatorID string) error {
+	moderator, err := IsModerator(db, moderatorID)
+	if err != nil {
+		return err
+	}
+
+	if !moderator {
+		return ErrUnauthorized
+	}
+
+	p.checkContentWarning()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow(`UPDATE posts SET sensitive = true, content_warning = $2, warning_forced_by = $3, updated_at = $4
+		WHERE id = $1 AND status = 'published' AND deleted_at IS NULL
+		RETURNING `+postColumns, p.ID, p.ContentWarning, moderatorID, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if err := p.emitPostWebhook(tx, true, true); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.GetPost(db, moderatorID)
+}
+
+// LiftContentWarning removes the warning of the post, forced or not, on
+// behalf of a moderator.
+func (p *Post) LiftContentWarning(db *sql.DB, moderatorID string) error {
+	moderator, err := IsModerator(db, moderatorID)
+	if err != nil {
+		return err
+	}
+
+	if !moderator {
+		return ErrUnauthorized
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = p.scan(tx.QueryRow(`UPDATE posts SET sensitive = false, content_warning = NULL, warning_forced_by = NULL,
+			updated_at = $2
+		WHERE id = $1 AND status = 'published' AND deleted_at IS NULL
+		RETURNING `+postColumns, p.ID, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if err := p.emitPostWebhook(tx, true, true); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return p.GetPost(db, moderatorID)
+}
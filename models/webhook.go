@@ -0,0 +1,479 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// Posts are announced once published: drafts and scheduled posts send
+	// post.created when they go live.
+	WebhookPostCreated    = "post.created"
+	WebhookPostUpdated    = "post.updated"
+	WebhookPostDeleted    = "post.deleted"
+	WebhookCommentCreated = "comment.created"
+	WebhookCommentUpdated = "comment.updated"
+	WebhookCommentDeleted = "comment.deleted"
+	// Products are shared by every user, so their events go to every
+	// webhook subscribed to them.
+	WebhookProductCreated = "product.created"
+	WebhookProductUpdated = "product.updated"
+	WebhookProductDeleted = "product.deleted"
+)
+
+// WebhookEvents lists the events webhooks can subscribe to.
+var WebhookEvents = []string{
+	WebhookPostCreated, WebhookPostUpdated, WebhookPostDeleted,
+	WebhookCommentCreated, WebhookCommentUpdated, WebhookCommentDeleted,
+	WebhookProductCreated, WebhookProductUpdated, WebhookProductDeleted,
+}
+
+const (
+	WebhookDeliveryPending    = "pending"
+	WebhookDeliveryProcessing = "processing"
+	WebhookDeliverySucceeded  = "succeeded"
+	WebhookDeliveryFailed     = "failed"
+)
+
+var ErrWebhookEvent = errors.New("Unknown webhook event")
+
+// Webhook sends the events it subscribes to, about the posts and comments
+// of its user and about products, to URL.
+type Webhook struct {
+	ID     string   `json:"id"`
+	UserId string   `json:"user_id"`
+	URL    string   `json:"url" validate:"required,url,startswith=http,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,required"`
+	// Secret signs the deliveries. It is generated unless given and only
+	// shown when set.
+	Secret string `json:"secret,omitempty" validate:"omitempty,min=16,max=64"`
+	Active bool   `json:"active"`
+	// FailureCount is the number of failed attempts since the last
+	// success. The webhook is disabled once it reaches the limit, and
+	// enabled again by setting Active.
+	FailureCount   int        `json:"failure_count"`
+	DisabledAt     *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason *string    `json:"disabled_reason,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook.
+// Redeliveries are new deliveries of the same event.
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookId      string          `json:"webhook_id"`
+	EventId        string          `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	Error          *string         `json:"error,omitempty"`
+	RedeliveryOf   *string         `json:"redelivery_of,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	// URL and Secret are those of the webhook, for sending.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type PayloadWebhookDeliveries struct {
+	Data      []WebhookDelivery `json:"data"`
+	TotalData int               `json:"total_data"`
+}
+
+// webhookPayload is the body of a delivery.
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+const webhookColumns = `webhooks.id, webhooks.user_id, webhooks.url, webhooks.events, webhooks.active,
+	webhooks.failure_count, webhooks.disabled_at, webhooks.disabled_reason, webhooks.created_at, webhooks.updated_at`
+
+func (w *Webhook) scan(row rowScanner) error {
+	var events pq.StringArray
+	err := row.Scan(&w.ID, &w.UserId, &w.URL, &events, &w.Active, &w.FailureCount, &w.DisabledAt,
+		&w.DisabledReason, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	w.Events = []string(events)
+	w.CreatedAt = w.CreatedAt.UTC().Add(time.Hour * 7)
+	w.UpdatedAt = w.UpdatedAt.UTC().Add(time.Hour * 7)
+	if w.DisabledAt != nil {
+		disabled := w.DisabledAt.UTC().Add(time.Hour * 7)
+		w.DisabledAt = &disabled
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = `webhook_deliveries.id, webhook_deliveries.webhook_id, webhook_deliveries.event_id,
+	webhook_deliveries.event, webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts,
+	webhook_deliveries.response_status, webhook_deliveries.error, webhook_deliveries.redelivery_of,
+	webhook_deliveries.next_attempt_at, webhook_deliveries.delivered_at, webhook_deliveries.created_at`
+
+func (d *WebhookDelivery) scan(row rowScanner, extra ...interface{}) error {
+	var payload string
+	var next time.Time
+	dest := append([]interface{}{&d.ID, &d.WebhookId, &d.EventId, &d.Event, &payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.Error, &d.RedeliveryOf, &next, &d.DeliveredAt, &d.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	d.Payload = json.RawMessage(payload)
+	d.CreatedAt = d.CreatedAt.UTC().Add(time.Hour * 7)
+	if d.Status == WebhookDeliveryPending {
+		next = next.UTC().Add(time.Hour * 7)
+		d.NextAttemptAt = &next
+	}
+	if d.DeliveredAt != nil {
+		delivered := d.DeliveredAt.UTC().Add(time.Hour * 7)
+		d.DeliveredAt = &delivered
+	}
+	return nil
+}
+
+func (w *Webhook) checkEvents() error {
+	for _, event := range w.Events {
+		valid := false
+		for _, known := range WebhookEvents {
+			valid = valid || event == known
+		}
+		if !valid {
+			return ErrWebhookEvent
+		}
+	}
+	return nil
+}
+
+// CreateWebhook adds the webhook for UserId, generating its secret unless
+// one was given.
+func (w *Webhook) CreateWebhook(db *sql.DB) error {
+	if err := w.checkEvents(); err != nil {
+		return err
+	}
+
+	if w.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		w.Secret = hex.EncodeToString(secret)
+	}
+
+	secret := w.Secret
+	err := w.scan(db.QueryRow(`INSERT INTO webhooks(id, user_id, url, secret, events, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, $6)
+		RETURNING `+webhookColumns,
+		uuid.New().String(), w.UserId, w.URL, w.Secret, pq.Array(w.Events), time.Now()))
+	w.Secret = secret
+	return err
+}
+
+// GetWebhooks lists the webhooks of the user, oldest first.
+func GetWebhooks(db *sql.DB, userID string) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	rows, err := db.Query(`SELECT `+webhookColumns+` FROM webhooks WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return webhooks, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var w Webhook
+		if err := w.scan(rows); err != nil {
+			return webhooks, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// GetWebhook loads the webhook when it belongs to UserId.
+func (w *Webhook) GetWebhook(db *sql.DB) error {
+	return w.scan(db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 AND user_id = $2`, w.ID, w.UserId))
+}
+
+// UpdateWebhook changes the URL, events and, when given, the secret of the
+// webhook. Setting Active on a disabled webhook enables it again, and it
+// sends the deliveries that waited meanwhile.
+func (w *Webhook) UpdateWebhook(db *sql.DB) error {
+	if err := w.checkEvents(); err != nil {
+		return err
+	}
+
+	secret := w.Secret
+	err := w.scan(db.QueryRow(`UPDATE webhooks SET url = $3, events = $4, secret = COALESCE(NULLIF($5, ''), secret),
+			active = $6,
+			failure_count = CASE WHEN $6 AND NOT active THEN 0 ELSE failure_count END,
+			disabled_at = CASE WHEN $6 THEN NULL ELSE COALESCE(disabled_at, $7) END,
+			disabled_reason = CASE WHEN $6 THEN NULL ELSE disabled_reason END,
+			updated_at = $7
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+webhookColumns,
+		w.ID, w.UserId, w.URL, pq.Array(w.Events), w.Secret, w.Active, time.Now()))
+	w.Secret = secret
+	return err
+}
+
+// DeleteWebhook removes the webhook with its deliveries.
+func (w *Webhook) DeleteWebhook(db *sql.DB) error {
+	res, err := db.Exec("DELETE FROM webhooks WHERE id = $1 AND user_id = $2", w.ID, w.UserId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetWebhookDeliveries lists the deliveries of the user's webhook, newest
+// first.
+func GetWebhookDeliveries(db *sql.DB, userID, webhookID string, params Params) (PayloadWebhookDeliveries, error) {
+	result := PayloadWebhookDeliveries{Data: []WebhookDelivery{}}
+
+	w := Webhook{ID: webhookID, UserId: userID}
+	if err := w.GetWebhook(db); err != nil {
+		return result, err
+	}
+
+	limit, offset := params.Paging()
+	rows, err := db.Query(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE webhook_id = $1
+		ORDER BY created_at DESC, id LIMIT $2 OFFSET $3`, webhookID, limit, offset)
+	if err != nil {
+		return result, err
+	}
+
+	defer rows.Close()
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1", webhookID).
+		Scan(&result.TotalData); err != nil {
+		return result, err
+	}
+
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := d.scan(rows); err != nil {
+			return result, err
+		}
+		result.Data = append(result.Data, d)
+	}
+
+	return result, rows.Err()
+}
+
+// RedeliverWebhookDelivery queues the event of the user's delivery ID to
+// be sent again, as a new delivery.
+func (d *WebhookDelivery) RedeliverWebhookDelivery(db *sql.DB, userID string) error {
+	return d.scan(db.QueryRow(`INSERT INTO webhook_deliveries(id, webhook_id, event_id, event, payload, redelivery_of, created_at)
+		SELECT $3, webhook_deliveries.webhook_id, webhook_deliveries.event_id, webhook_deliveries.event,
+			webhook_deliveries.payload, webhook_deliveries.id, $4
+		FROM webhook_deliveries JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+		WHERE webhook_deliveries.id = $1 AND webhooks.user_id = $2
+		RETURNING `+webhookDeliveryColumns, d.ID, userID, uuid.New().String(), time.Now()))
+}
+
+// ClaimWebhookDeliveries marks up to limit due deliveries of active
+// webhooks as being sent and returns them, like ClaimLinkPreviews does for
+// links.
+func ClaimWebhookDeliveries(db *sql.DB, limit, maxAttempts int) ([]WebhookDelivery, error) {
+	_, err := db.Exec(`UPDATE webhook_deliveries SET status = 'failed', error = COALESCE(error, 'delivery timed out')
+		WHERE status = 'processing' AND next_attempt_at <= NOW() AND attempts >= $1`, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`UPDATE webhook_deliveries SET status = 'processing', attempts = attempts + 1,
+			next_attempt_at = NOW() + $3::float8 * INTERVAL '1 second'
+		FROM webhooks
+		WHERE webhooks.id = webhook_deliveries.webhook_id AND webhook_deliveries.id IN (
+			SELECT webhook_deliveries.id FROM webhook_deliveries
+				JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id AND webhooks.active
+			WHERE webhook_deliveries.status IN ('pending', 'processing')
+				AND webhook_deliveries.next_attempt_at <= NOW() AND webhook_deliveries.attempts < $2
+			ORDER BY webhook_deliveries.next_attempt_at LIMIT $1 FOR UPDATE OF webhook_deliveries SKIP LOCKED)
+		RETURNING `+webhookDeliveryColumns+`, webhooks.url, webhooks.secret`,
+		limit, maxAttempts, ProcessingLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := d.scan(rows, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// SucceedWebhookDelivery records the delivery as accepted with the
+// response status, clearing the webhook's failures.
+func (d *WebhookDelivery) SucceedWebhookDelivery(db *sql.DB, responseStatus int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE webhook_deliveries SET status = 'succeeded', response_status = $2, error = NULL,
+			delivered_at = NOW()
+		WHERE id = $1`, d.ID, responseStatus)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE webhooks SET failure_count = 0 WHERE id = $1", d.WebhookId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FailWebhookDelivery records a failed attempt, with the response status
+// when there was a response. It is retried after backoff, doubled for every
+// earlier attempt, until maxAttempts is reached. The webhook is disabled
+// after disableAfter failed attempts in a row, or never when it is 0.
+func (d *WebhookDelivery) FailWebhookDelivery(db *sql.DB, reason string, responseStatus *int, maxAttempts int,
+	backoff time.Duration, disableAfter int) error {
+	if disableAfter <= 0 {
+		disableAfter = math.MaxInt32
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE webhook_deliveries SET error = $2, response_status = $3,
+			status = CASE WHEN attempts >= $4 THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = NOW() + $5::float8 * POWER(2, attempts - 1) * INTERVAL '1 second'
+		WHERE id = $1`, d.ID, reason, responseStatus, maxAttempts, backoff.Seconds())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE webhooks SET failure_count = failure_count + 1,
+			active = active AND failure_count + 1 < $2,
+			disabled_at = CASE WHEN active AND failure_count + 1 >= $2 THEN NOW() ELSE disabled_at END,
+			disabled_reason = CASE WHEN active AND failure_count + 1 >= $2 THEN $3 ELSE disabled_reason END
+		WHERE id = $1`, d.WebhookId, disableAfter, "Disabled after repeated failures: "+reason)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// emitWebhook queues a delivery of event, carrying data, to each active
+// webhook subscribed to it that belongs to one of ownerIDs, or to any user
+// when ownerIDs is nil. Queued within a transaction, the deliveries are
+// only sent once it commits.
+func emitWebhook(q interface {
+	execer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, event string, ownerIDs []string, data interface{}) error {
+	rows, err := q.Query(`SELECT id FROM webhooks
+		WHERE active AND $1 = ANY(events) AND ($2::varchar[] IS NULL OR user_id = ANY($2))`,
+		event, pq.Array(ownerIDs))
+	if err != nil {
+		return err
+	}
+
+	var webhookIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		webhookIDs = append(webhookIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(webhookIDs) == 0 {
+		return nil
+	}
+
+	eventID := uuid.New().String()
+	payload, err := json.Marshal(webhookPayload{ID: eventID, Event: event, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range webhookIDs {
+		_, err := q.Exec(`INSERT INTO webhook_deliveries(id, webhook_id, event_id, event, payload, created_at)
+			VALUES($1, $2, $3, $4, $5, $6)`, uuid.New().String(), id, eventID, event, string(payload), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitPostWebhook queues the event for a change that took the post from
+// published or not, as in wasPublished, to its current status. Drafts and
+// scheduled posts are not announced: to webhooks a post is created when it
+// is published and deleted when it stops being published or is deleted.
+func (p *Post) emitPostWebhook(tx *sql.Tx, wasPublished, isPublished bool) error {
+	switch {
+	case !wasPublished && isPublished:
+		return emitWebhook(tx, WebhookPostCreated, []string{p.UserId}, p)
+	case wasPublished && isPublished:
+		return emitWebhook(tx, WebhookPostUpdated, []string{p.UserId}, p)
+	case wasPublished:
+		return emitWebhook(tx, WebhookPostDeleted, []string{p.UserId}, map[string]string{"id": p.ID})
+	}
+	return nil
+}
+
+// emitCommentWebhook queues event for the comment's author and the author
+// of its post.
+func (p *Comment) emitCommentWebhook(tx *sql.Tx, event, authorID string, data interface{}) error {
+	var owner string
+	if err := tx.QueryRow("SELECT user_id FROM posts WHERE id = $1", p.PostId).Scan(&owner); err != nil {
+		return err
+	}
+
+	owners := []string{owner}
+	if authorID != "" && authorID != owner {
+		owners = append(owners, authorID)
+	}
+	return emitWebhook(tx, event, owners, data)
+}
@@ -0,0 +1,125 @@
+// Package realtime passes events on to the clients subscribed to them.
+package realtime
+
+import "sync"
+
+// Hub fans messages out to the subscribers of a topic, such as the comments
+// of a post. Publishing never waits for a subscriber: one whose buffer is
+// full has fallen behind and is dropped, closing its channel.
+type Hub struct {
+	// Buffer is how many messages a subscriber may fall behind.
+	Buffer int
+
+	mu     sync.Mutex
+	topics map[string]map[*Subscriber]struct{}
+}
+
+// Subscriber receives the messages of the topics it was added to.
+type Subscriber struct {
+	hub    *Hub
+	send   chan []byte
+	topics map[string]struct{}
+	closed bool
+}
+
+// Comments carries the comment events of each post, with the post id as
+// topic.
+var Comments = NewHub(64)
+
+func NewHub(buffer int) *Hub {
+	return &Hub{Buffer: buffer, topics: map[string]map[*Subscriber]struct{}{}}
+}
+
+// Subscribe returns a subscriber without topics.
+func (h *Hub) Subscribe() *Subscriber {
+	return &Subscriber{hub: h, send: make(chan []byte, h.Buffer), topics: map[string]struct{}{}}
+}
+
+// Publish sends msg to the subscribers of topic.
+func (h *Hub) Publish(topic string, msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.topics[topic] {
+		select {
+		case s.send <- msg:
+		default:
+			s.close()
+		}
+	}
+}
+
+// C receives the messages. It is closed when the subscriber is closed or
+// dropped for falling behind.
+func (s *Subscriber) C() <-chan []byte {
+	return s.send
+}
+
+// Add subscribes to the topics. It does nothing once the subscriber is
+// closed.
+func (s *Subscriber) Add(topics ...string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	for _, topic := range topics {
+		subs, ok := s.hub.topics[topic]
+		if !ok {
+			subs = map[*Subscriber]struct{}{}
+			s.hub.topics[topic] = subs
+		}
+		subs[s] = struct{}{}
+		s.topics[topic] = struct{}{}
+	}
+}
+
+// Remove unsubscribes from the topics.
+func (s *Subscriber) Remove(topics ...string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	for _, topic := range topics {
+		s.leave(topic)
+	}
+}
+
+// Topics returns the number of topics subscribed to.
+func (s *Subscriber) Topics() int {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	return len(s.topics)
+}
+
+// Close unsubscribes from every topic and closes C.
+func (s *Subscriber) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.close()
+}
+
+func (s *Subscriber) close() {
+	if s.closed {
+		return
+	}
+
+	for topic := range s.topics {
+		s.leave(topic)
+	}
+	s.closed = true
+	close(s.send)
+}
+
+func (s *Subscriber) leave(topic string) {
+	delete(s.topics, topic)
+	if subs, ok := s.hub.topics[topic]; ok {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(s.hub.topics, topic)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package realtime
+
+import "testing"
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub(4)
+	a := hub.Subscribe()
+	b := hub.Subscribe()
+	a.Add("post1", "post2")
+	b.Add("post2")
+
+	hub.Publish("post1", []byte("one"))
+	hub.Publish("post2", []byte("two"))
+
+	if got := len(a.C()); got != 2 {
+		t.Errorf("Expected both messages for the first subscriber. Got %d", got)
+	}
+	if msg := <-b.C(); string(msg) != "two" {
+		t.Errorf("Expected only the message of post2. Got %q", msg)
+	}
+
+	b.Remove("post2")
+	hub.Publish("post2", []byte("three"))
+	if got := len(b.C()); got != 0 {
+		t.Errorf("Expected no messages after unsubscribing. Got %d", got)
+	}
+
+	a.Close()
+	a.Close()
+	if _, ok := <-a.C(); !ok {
+		t.Errorf("Expected the buffered messages to stay readable after closing")
+	}
+	if len(hub.topics) != 0 {
+		t.Errorf("Expected empty topics to be removed. Got %v", hub.topics)
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(2)
+	slow := hub.Subscribe()
+	fast := hub.Subscribe()
+	slow.Add("post1")
+	fast.Add("post1")
+
+	for i := 0; i < 3; i++ {
+		hub.Publish("post1", []byte("msg"))
+		<-fast.C()
+	}
+
+	n := 0
+	for range slow.C() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("Expected the slow subscriber to get its buffer and be closed. Got %d messages", n)
+	}
+
+	slow.Add("post2")
+	if slow.Topics() != 0 {
+		t.Errorf("Expected a dropped subscriber not to subscribe again")
+	}
+
+	hub.Publish("post1", []byte("msg"))
+	if _, ok := <-fast.C(); !ok {
+		t.Errorf("Expected the other subscriber to keep receiving")
+	}
+}
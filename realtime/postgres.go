@@ -0,0 +1,154 @@
+package realtime
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/bayudha2/go-test-0/models"
+	"github.com/lib/pq"
+)
+
+// CommentMessage is what subscribers of a post receive about its comments.
+type CommentMessage struct {
+	Type      string `json:"type"`
+	PostID    string `json:"post_id"`
+	CommentID string `json:"comment_id"`
+	// Comment is the comment as anyone sees it. Deleted comments come
+	// without one, unless they stay as a tombstone.
+	Comment *models.Comment `json:"comment,omitempty"`
+}
+
+// ListenComments passes the comment events announced by every server
+// instance on to the subscribers in hub, until ctx is done. Events sent
+// while the connection is being reestablished are lost.
+func ListenComments(ctx context.Context, db *sql.DB, connectionString string, hub *Hub) error {
+	return listen(ctx, connectionString, models.CommentEventChannel, nil, func(payload string) error {
+		var event models.CommentEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return err
+		}
+
+		msg, err := commentMessage(db, event)
+		if err != nil {
+			return err
+		}
+		if msg != nil {
+			hub.Publish(event.PostID, msg)
+		}
+		return nil
+	})
+}
+
+// ListenActivity passes the activity events announced by every server
+// instance on to stream, until ctx is done. Each is sent as a Server-Sent
+// Event whose id is the event's.
+func ListenActivity(ctx context.Context, db *sql.DB, connectionString string, stream *Stream) error {
+	// Events sent before the listener started, or while it reconnected,
+	// are not in the stream.
+	reset := func() error {
+		id, err := models.LastActivityEventID(db)
+		if err != nil {
+			return err
+		}
+		stream.Start(id)
+		return nil
+	}
+
+	return listen(ctx, connectionString, models.ActivityEventChannel, reset, func(payload string) error {
+		var event models.ActivityEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return err
+		}
+
+		// Read as the user it is for, who may see comments others cannot,
+		// such as ones waiting for their approval.
+		comment := models.Comment{ID: event.CommentID}
+		err := comment.GetComment(db, event.UserID)
+		if err == sql.ErrNoRows {
+			// Gone by the time the event arrived.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(CommentMessage{Type: event.Type, PostID: event.PostID,
+			CommentID: event.CommentID, Comment: &comment})
+		if err != nil {
+			return err
+		}
+
+		stream.Publish(Entry{ID: event.ID, Topic: event.UserID, Data: FormatEvent(event.ID, event.Type, data)})
+		return nil
+	})
+}
+
+// listen calls handle with the payload of every notification on channel
+// until ctx is done. reset, when set, runs once listening has begun and
+// again whenever notifications may have been missed while reconnecting.
+func listen(ctx context.Context, connectionString, channel string, reset func() error, handle func(string) error) error {
+	listener := pq.NewListener(connectionString, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("%s listener: %v", channel, err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return err
+	}
+
+	if reset != nil {
+		if err := reset(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// nil after the connection was reestablished.
+			if n == nil {
+				if reset != nil {
+					if err := reset(); err != nil {
+						log.Printf("%s listener: %v", channel, err)
+					}
+				}
+				continue
+			}
+
+			if err := handle(n.Extra); err != nil {
+				log.Printf("%s listener: %v", channel, err)
+			}
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+// commentMessage reads the comment of the event once for every subscriber.
+// A comment that can no longer be seen by the time its event arrives is
+// sent as deleted; nil means there is nothing to send.
+func commentMessage(db *sql.DB, event models.CommentEvent) ([]byte, error) {
+	msg := CommentMessage{Type: event.Type, PostID: event.PostID, CommentID: event.CommentID}
+
+	comment := models.Comment{ID: event.CommentID}
+	err := comment.GetComment(db, "")
+	switch {
+	case err == sql.ErrNoRows && event.Type == models.CommentCreated:
+		return nil, nil
+	case err == sql.ErrNoRows:
+		msg.Type = models.CommentDeleted
+	case err != nil:
+		return nil, err
+	default:
+		msg.Comment = &comment
+	}
+
+	return json.Marshal(msg)
+}
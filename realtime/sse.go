@@ -0,0 +1,21 @@
+package realtime
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// FormatEvent encodes a Server-Sent Event. Newlines in data are split over
+// several data lines, as the format requires.
+func FormatEvent(id int64, event string, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("id: " + strconv.FormatInt(id, 10) + "\n")
+	b.WriteString("event: " + event + "\n")
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
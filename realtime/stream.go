@@ -0,0 +1,117 @@
+package realtime
+
+import "sync"
+
+// Entry is a message published to a Stream.
+type Entry struct {
+	ID    int64
+	Topic string
+	Data  []byte
+
+	// late is set when the entry arrived after one with a higher id.
+	late bool
+}
+
+// Stream is a hub that keeps its last messages, so clients that reconnect
+// can catch up on what they missed.
+//
+// IDs are handed out before the messages are sent, so messages may arrive
+// out of order. A client that saw a higher id before a late message arrived
+// cannot tell it missed that message, so resuming past a late message is
+// reported as incomplete.
+type Stream struct {
+	hub  *Hub
+	size int
+
+	mu sync.Mutex
+	// entries are kept sorted by id.
+	entries []Entry
+	// floor is the id up to which messages may be missing: the highest one
+	// dropped from entries, or where the stream started.
+	floor int64
+	// last is the highest id published.
+	last int64
+}
+
+// Activity carries the events for each user, with the user id as topic.
+var Activity = NewStream(64, 1000)
+
+// NewStream returns a stream whose subscribers may fall buffer messages
+// behind and which keeps the last size messages.
+func NewStream(buffer, size int) *Stream {
+	return &Stream{hub: NewHub(buffer), size: size}
+}
+
+// Start marks the messages up to id as missed, for when the stream starts
+// receiving messages after others were already sent.
+func (s *Stream) Start(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id > s.floor {
+		s.floor = id
+	}
+}
+
+// Publish keeps the entry and sends its data to the subscribers of its
+// topic.
+func (s *Stream) Publish(e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.late = e.ID < s.last || e.ID <= s.floor
+	if e.ID > s.last {
+		s.last = e.ID
+	}
+
+	i := len(s.entries)
+	for i > 0 && s.entries[i-1].ID > e.ID {
+		i--
+	}
+	s.entries = append(s.entries, Entry{})
+	copy(s.entries[i+1:], s.entries[i:])
+	s.entries[i] = e
+
+	if len(s.entries) > s.size {
+		if s.entries[0].ID > s.floor {
+			s.floor = s.entries[0].ID
+		}
+		s.entries = append(s.entries[:0], s.entries[1:]...)
+	}
+	s.hub.Publish(e.Topic, e.Data)
+}
+
+// Subscribe subscribes to topic for a client that has nothing to resume.
+func (s *Stream) Subscribe(topic string) *Subscriber {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.hub.Subscribe()
+	sub.Add(topic)
+	return sub
+}
+
+// Resume subscribes to topic and returns the entries kept for it after
+// lastID, in id order. Nothing is missed or sent twice between the two.
+// complete is false when entries after lastID may already be gone, or when
+// a late entry up to lastID may not have been seen.
+func (s *Stream) Resume(topic string, lastID int64) (sub *Subscriber, missed []Entry, complete bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub = s.hub.Subscribe()
+	sub.Add(topic)
+
+	complete = lastID >= s.floor
+	for _, e := range s.entries {
+		if e.Topic != topic {
+			continue
+		}
+		if e.ID > lastID {
+			missed = append(missed, e)
+		} else if e.late {
+			complete = false
+		}
+	}
+	return sub, missed, complete
+}
@@ -0,0 +1,72 @@
+package realtime
+
+import "testing"
+
+func TestStreamResume(t *testing.T) {
+	stream := NewStream(4, 3)
+	stream.Start(10)
+
+	_, _, complete := stream.Resume("user1", 9)
+	if complete {
+		t.Errorf("Expected events from before the start to be missing")
+	}
+
+	for id := int64(11); id <= 14; id++ {
+		topic := "user1"
+		if id == 12 {
+			topic = "user2"
+		}
+		stream.Publish(Entry{ID: id, Topic: topic, Data: []byte("event")})
+	}
+
+	sub, missed, complete := stream.Resume("user1", 12)
+	if !complete || len(missed) != 2 || missed[0].ID != 13 || missed[1].ID != 14 {
+		t.Errorf("Expected events 13 and 14 to be replayed. Got %v %v", complete, missed)
+	}
+
+	if _, _, complete := stream.Resume("user1", 10); complete {
+		t.Errorf("Expected event 11 to be reported as dropped")
+	}
+
+	stream.Publish(Entry{ID: 15, Topic: "user1", Data: []byte("live")})
+	if msg := <-sub.C(); string(msg) != "live" {
+		t.Errorf("Expected new events after resuming. Got %q", msg)
+	}
+}
+
+func TestFormatEvent(t *testing.T) {
+	got := string(FormatEvent(7, "reply", []byte("{\"a\":1}\n{\"b\":2}")))
+	want := "id: 7\nevent: reply\ndata: {\"a\":1}\ndata: {\"b\":2}\n\n"
+	if got != want {
+		t.Errorf("Expected %q. Got %q", want, got)
+	}
+}
+
+func TestStreamLateEntries(t *testing.T) {
+	stream := NewStream(4, 3)
+	for _, id := range []int64{11, 13, 12} {
+		stream.Publish(Entry{ID: id, Topic: "user1", Data: []byte("event")})
+	}
+
+	if _, _, complete := stream.Resume("user1", 13); complete {
+		t.Errorf("Expected event 12, which arrived after 13, to make resuming from 13 incomplete")
+	}
+
+	_, missed, complete := stream.Resume("user1", 11)
+	if !complete || len(missed) != 2 || missed[0].ID != 12 || missed[1].ID != 13 {
+		t.Errorf("Expected events 12 and 13 in id order. Got %v %v", complete, missed)
+	}
+
+	stream.Publish(Entry{ID: 14, Topic: "user1", Data: []byte("event")})
+	if _, missed, complete := stream.Resume("user1", 10); complete || missed[0].ID != 12 {
+		t.Errorf("Expected the lowest id to be dropped first. Got %v", missed)
+	}
+
+	// Without a Last-Event-ID there is nothing to resume, so late entries
+	// do not matter.
+	sub := stream.Subscribe("user1")
+	stream.Publish(Entry{ID: 15, Topic: "user1", Data: []byte("live")})
+	if msg := <-sub.C(); string(msg) != "live" {
+		t.Errorf("Expected a fresh subscriber to get new events. Got %q", msg)
+	}
+}
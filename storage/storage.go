@@ -0,0 +1,79 @@
+// Package storage keeps uploaded files in a blob store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Default is the store used by the HTTP handlers and background jobs.
+var Default BlobStore
+
+// Object is an open blob. It can seek so it can be served with Range
+// requests.
+type Object interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (Object, ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_.-]+)*$`)
+
+// validKey accepts slash separated keys made of safe characters, which
+// rules out absolute paths and parent directory segments.
+func validKey(key string) bool {
+	if !keyPattern.MatchString(key) {
+		return false
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// FromEnv builds the store selected by APP_BLOB_STORE, "local" (the default)
+// or "s3".
+func FromEnv() (BlobStore, error) {
+	switch os.Getenv("APP_BLOB_STORE") {
+	case "", "local":
+		dir := os.Getenv("APP_BLOB_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return &S3Store{
+			Endpoint:  os.Getenv("APP_S3_ENDPOINT"),
+			Bucket:    os.Getenv("APP_S3_BUCKET"),
+			Region:    os.Getenv("APP_S3_REGION"),
+			AccessKey: os.Getenv("APP_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("APP_S3_SECRET_KEY"),
+		}, nil
+	default:
+		return nil, errors.New("unsupported APP_BLOB_STORE: " + os.Getenv("APP_BLOB_STORE"))
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	content := []byte("ini isi berkas yang diunggah")
+
+	if err := store.Put(ctx, "attachments/abc", bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, info, err := store.Open(ctx, "attachments/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size != int64(len(content)) {
+		t.Errorf("Expected size %d. Got %d", len(content), info.Size)
+	}
+
+	if _, err := obj.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	part := make([]byte, 3)
+	if _, err := io.ReadFull(obj, part); err != nil || string(part) != "isi" {
+		t.Errorf("Expected to read 'isi' after seeking. Got %q (%v)", part, err)
+	}
+
+	if end, _ := obj.Seek(0, io.SeekEnd); end != int64(len(content)) {
+		t.Errorf("Expected to seek to %d. Got %d", len(content), end)
+	}
+	obj.Close()
+
+	if err := store.Delete(ctx, "attachments/abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := store.Open(ctx, "attachments/abc"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete. Got %v", err)
+	}
+
+	if err := store.Put(ctx, "../escape", bytes.NewReader(nil), 0, "text/plain"); err != ErrInvalidKey {
+		t.Errorf("Expected ErrInvalidKey. Got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, store)
+}
+
+// fakeS3 is a minimal stand-in for an S3-compatible server.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != unsignedPayload {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.objects[r.URL.Path]
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead, http.MethodGet:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		start := 0
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)-start))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if start > 0 {
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data[start:])
+		}
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	testStore(t, &S3Store{
+		Endpoint:  server.URL,
+		Bucket:    "media",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+}
+
+func TestSigningKey(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation.
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	expected := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+
+	if got := hex.EncodeToString(key); got != expected {
+		t.Errorf("Expected signing key %s. Got %s", expected, got)
+	}
+}
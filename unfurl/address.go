@@ -0,0 +1,61 @@
+package unfurl
+
+import "net"
+
+// reservedNets are the ranges, beyond what net.IP classifies, that are not
+// reachable on the public internet or that embed an IPv4 address which could
+// be private.
+var reservedNets = parseCIDRs(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+	"64:ff9b:1::/48",
+	"100::/64",
+	"2001::/32",
+	"2001:db8::/32",
+	"2002::/16",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// PublicIP reports whether ip is a public unicast address: not private,
+// loopback, link-local, multicast, unspecified or otherwise reserved.
+// IPv4-mapped IPv6 addresses are judged by their IPv4 address.
+func PublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		return false
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
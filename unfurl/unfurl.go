@@ -0,0 +1,304 @@
+// Package unfurl fetches the OpenGraph and Twitter card metadata of web pages
+// for link previews. It only connects to public addresses: every address a
+// host name resolves to is checked before it is dialed, on the first request
+// and on every redirect, so neither a redirect nor a DNS answer that changes
+// between the check and the connection can reach a private network.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrBlockedAddress   = errors.New("unfurl: address is not public")
+	ErrUnsupportedURL   = errors.New("unfurl: only http and https URLs can be unfurled")
+	ErrNotHTML          = errors.New("unfurl: response is not an HTML page")
+	ErrTooManyRedirects = errors.New("unfurl: too many redirects")
+)
+
+const (
+	maxTitle       = 300
+	maxDescription = 1000
+)
+
+// Preview is the card shown for a link.
+type Preview struct {
+	// URL is the page the metadata was read from, after redirects.
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Fetcher reads previews over HTTP. The zero value is not usable; use
+// NewFetcher.
+type Fetcher struct {
+	// Timeout bounds a whole fetch, redirects included.
+	Timeout time.Duration
+	// MaxBytes is how much of a page is read looking for metadata.
+	MaxBytes     int64
+	MaxRedirects int
+	UserAgent    string
+	// Allow reports whether an address may be connected to. It is
+	// PublicIP unless replaced, e.g. to reach httptest servers in tests.
+	Allow func(ip net.IP) bool
+	// LookupIP resolves host names.
+	LookupIP func(ctx context.Context, host string) ([]net.IP, error)
+
+	once   sync.Once
+	client *http.Client
+}
+
+// NewFetcher returns a Fetcher with strict defaults: 5 seconds per fetch,
+// 512KiB per page and 5 redirects.
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		Timeout:      5 * time.Second,
+		MaxBytes:     512 << 10,
+		MaxRedirects: 5,
+		UserAgent:    "Mozilla/5.0 (compatible; link-preview/1.0)",
+		Allow:        PublicIP,
+		LookupIP:     lookupIP,
+	}
+}
+
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+func (f *Fetcher) init() {
+	dialer := &net.Dialer{
+		Timeout: f.Timeout,
+		// Control sees the address actually being connected to, a last
+		// check should anything dial without going through dial.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !f.Allow(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// Proxies from the environment would connect for us, past the
+		// address checks.
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return f.dial(ctx, dialer, network, addr)
+		},
+		TLSHandshakeTimeout:    f.Timeout,
+		ResponseHeaderTimeout:  f.Timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		DisableKeepAlives:      true,
+	}
+
+	f.client = &http.Client{
+		Transport: transport,
+		Timeout:   f.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			return checkURL(req.URL)
+		},
+	}
+}
+
+// dial resolves addr once and connects only to the resolved addresses that
+// are allowed, so the address checked is the address used.
+func (f *Fetcher) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = f.LookupIP(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	lastErr := error(ErrBlockedAddress)
+	for _, ip := range ips {
+		if !f.Allow(ip) {
+			continue
+		}
+
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+func checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrUnsupportedURL
+	}
+	return nil
+}
+
+// Fetch reads the preview of the page at rawURL.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	f.once.Do(f.init)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, ErrUnsupportedURL
+	}
+
+	if err := checkURL(u); err != nil {
+		return Preview{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Preview{}, fmt.Errorf("unfurl: %s returned %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, ErrNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.MaxBytes), contentType)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	return parse(body, resp.Request.URL), nil
+}
+
+// parse reads the metadata in the head of a page. The OpenGraph tags win
+// over the Twitter card ones, which win over the title and description.
+func parse(r io.Reader, base *url.URL) Preview {
+	meta := map[string]string{}
+	var title strings.Builder
+	inTitle := false
+
+	z := html.NewTokenizer(r)
+parsing:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			break parsing
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "meta":
+				var key, content string
+				for _, attr := range tok.Attr {
+					switch attr.Key {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(strings.TrimSpace(attr.Val))
+						}
+					case "content":
+						content = attr.Val
+					}
+				}
+				if key != "" && meta[key] == "" {
+					meta[key] = content
+				}
+			case "title":
+				inTitle = true
+			case "body":
+				break parsing
+			}
+		case html.TextToken:
+			if inTitle && title.Len() < maxTitle*4 {
+				title.Write(z.Text())
+			}
+		case html.EndTagToken:
+			switch tok, _ := z.TagName(); string(tok) {
+			case "title":
+				inTitle = false
+			case "head":
+				break parsing
+			}
+		}
+	}
+
+	p := Preview{
+		URL:         base.String(),
+		Title:       clean(first(meta["og:title"], meta["twitter:title"], title.String()), maxTitle),
+		Description: clean(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescription),
+		SiteName:    clean(first(meta["og:site_name"], base.Hostname()), maxTitle),
+	}
+
+	image := first(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"])
+	if ref, err := url.Parse(strings.TrimSpace(image)); err == nil && image != "" {
+		if abs := base.ResolveReference(ref); checkURL(abs) == nil {
+			p.ImageURL = abs.String()
+		}
+	}
+
+	return p
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean collapses whitespace and cuts s to at most max runes.
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
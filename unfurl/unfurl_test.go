@@ -0,0 +1,225 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loopbackOnly lets tests reach httptest servers while every other address,
+// public or not, stays blocked.
+func loopbackOnly(ip net.IP) bool {
+	return ip.IsLoopback()
+}
+
+func newTestFetcher() *Fetcher {
+	f := NewFetcher()
+	f.Allow = loopbackOnly
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!doctype html><html><head>
+			<title>Judul halaman</title>
+			<meta property="og:title" content="Judul  OpenGraph">
+			<meta name="twitter:description" content="Deskripsi &amp; kartu">
+			<meta property="og:image" content="/gambar.png">
+			<meta property="og:site_name" content="Contoh">
+			</head><body><meta property="og:title" content="ignored"></body></html>`))
+	}))
+	defer ts.Close()
+
+	p, err := newTestFetcher().Fetch(context.Background(), ts.URL+"/halaman")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Preview{
+		URL:         ts.URL + "/halaman",
+		Title:       "Judul OpenGraph",
+		Description: "Deskripsi & kartu",
+		ImageURL:    ts.URL + "/gambar.png",
+		SiteName:    "Contoh",
+	}
+	if p != expected {
+		t.Errorf("Expected %+v. Got %+v", expected, p)
+	}
+}
+
+func TestFetchTitleFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<title>Caf\xe9</title><meta name=\"description\" content=\"biasa\">"))
+	}))
+	defer ts.Close()
+
+	p, err := newTestFetcher().Fetch(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Title != "Café" || p.Description != "biasa" || p.SiteName != "127.0.0.1" {
+		t.Errorf("Expected the title and description tags to be used. Got %+v", p)
+	}
+}
+
+func TestFetchBlocksLoopbackByDefault(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to reach a loopback server")
+	}))
+	defer ts.Close()
+
+	_, err := NewFetcher().Fetch(context.Background(), ts.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Expected ErrBlockedAddress. Got %v", err)
+	}
+
+	_, err = NewFetcher().Fetch(context.Background(), strings.Replace(ts.URL, "127.0.0.1", "localhost", 1))
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Expected ErrBlockedAddress for localhost. Got %v", err)
+	}
+}
+
+func TestFetchBlocksRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/internal":
+			http.Redirect(w, r, "http://internal.test/", http.StatusFound)
+		case "/scheme":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			http.Redirect(w, r, r.URL.Path, http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	f := newTestFetcher()
+	f.LookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.0.0.8")}, nil
+	}
+
+	cases := map[string]error{
+		"/metadata": ErrBlockedAddress,
+		"/internal": ErrBlockedAddress,
+		"/scheme":   ErrUnsupportedURL,
+		"/loop":     ErrTooManyRedirects,
+	}
+	for path, expected := range cases {
+		if _, err := f.Fetch(context.Background(), ts.URL+path); !errors.Is(err, expected) {
+			t.Errorf("%s: Expected %v. Got %v", path, expected, err)
+		}
+	}
+}
+
+func TestFetchDialsOnlyCheckedAddresses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>ok</title>"))
+	}))
+	defer ts.Close()
+
+	// A resolver that answers differently on every lookup, as a rebinding
+	// attack would. Each answer is checked as it is dialed.
+	lookups := 0
+	f := newTestFetcher()
+	f.LookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+		lookups++
+		if lookups == 1 {
+			return []net.IP{net.ParseIP("10.0.0.8"), net.ParseIP("127.0.0.1")}, nil
+		}
+		return []net.IP{net.ParseIP("10.0.0.8")}, nil
+	}
+
+	url := strings.Replace(ts.URL, "127.0.0.1", "rebind.test", 1)
+	if p, err := f.Fetch(context.Background(), url); err != nil || p.Title != "ok" {
+		t.Errorf("Expected only the allowed address to be dialed. Got %+v, %v", p, err)
+	}
+
+	if _, err := f.Fetch(context.Background(), url); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Expected the rebound address to be blocked. Got %v", err)
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/big":
+			w.Write([]byte("<title>awal</title>"))
+			w.Write([]byte(strings.Repeat("<meta name=x content=y>", 10000)))
+			w.Write([]byte(`<meta property="og:title" content="terlambat">`))
+		case "/slow":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	f := newTestFetcher()
+	f.MaxBytes = 4096
+	f.Timeout = 200 * time.Millisecond
+
+	p, err := f.Fetch(context.Background(), ts.URL+"/big")
+	if err != nil || p.Title != "awal" {
+		t.Errorf("Expected reading to stop after MaxBytes. Got %+v, %v", p, err)
+	}
+
+	start := time.Now()
+	if _, err := f.Fetch(context.Background(), ts.URL+"/slow"); err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("Expected a slow page to time out. Got %v after %v", err, time.Since(start))
+	}
+
+	if _, err := f.Fetch(context.Background(), ts.URL+"/image"); err != ErrNotHTML {
+		t.Errorf("Expected ErrNotHTML. Got %v", err)
+	}
+
+	if _, err := f.Fetch(context.Background(), "ftp://example.com/"); err != ErrUnsupportedURL {
+		t.Errorf("Expected ErrUnsupportedURL. Got %v", err)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":          true,
+		"2606:4700:4700::1111":   true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"100.64.0.1":             false,
+		"0.0.0.0":                false,
+		"255.255.255.255":        false,
+		"::1":                    false,
+		"::":                     false,
+		"fe80::1":                false,
+		"fd00::1":                false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"64:ff9b::a00:1":         false,
+		"2002:a00:1::":           false,
+	}
+
+	for addr, expected := range cases {
+		if got := PublicIP(net.ParseIP(addr)); got != expected {
+			t.Errorf("PublicIP(%s): Expected %v. Got %v", addr, expected, got)
+		}
+	}
+}
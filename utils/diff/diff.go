@@ -0,0 +1,178 @@
+// Package diff computes word-level differences between two texts.
+package diff
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// maxEdits bounds the work done by Words. Texts that need more edits
+// than this are reported as a full replacement.
+const maxEdits = 1000
+
+type Op struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Words returns the word-level changes that turn a into b. Whitespace is
+// kept in the output so joining the equal and insert texts gives back b.
+func Words(a, b string) []Op {
+	x, y := splitWords(a), splitWords(b)
+
+	var prefix, suffix []Op
+	for len(x) > 0 && len(y) > 0 && x[0] == y[0] {
+		prefix = append(prefix, Op{Op: Equal, Text: x[0]})
+		x, y = x[1:], y[1:]
+	}
+	for len(x) > 0 && len(y) > 0 && x[len(x)-1] == y[len(y)-1] {
+		suffix = append([]Op{{Op: Equal, Text: x[len(x)-1]}}, suffix...)
+		x, y = x[:len(x)-1], y[:len(y)-1]
+	}
+
+	ops := append(prefix, myers(x, y)...)
+	return mergeOps(append(ops, suffix...))
+}
+
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != isSpaceAt(s, start) {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+func isSpaceAt(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsSpace(r)
+}
+
+// myers implements the greedy O(ND) diff from Eugene Myers' paper, keeping a
+// snapshot of the furthest reaching paths for every edit distance so the
+// edit script can be rebuilt by walking back from the end.
+func myers(x, y []string) []Op {
+	n, m := len(x), len(y)
+	if n == 0 || m == 0 {
+		return replaceAll(x, y)
+	}
+
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+
+			if i >= n && j >= m {
+				return backtrack(trace, x, y)
+			}
+		}
+	}
+
+	return replaceAll(x, y)
+}
+
+func backtrack(trace [][]int, x, y []string) []Op {
+	var reversed []Op
+	i, j := len(x), len(y)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := i - j
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevI := v[prevK+d]
+		prevJ := prevI - prevK
+
+		for i > prevI && j > prevJ {
+			reversed = append(reversed, Op{Op: Equal, Text: x[i-1]})
+			i--
+			j--
+		}
+
+		if i == prevI {
+			reversed = append(reversed, Op{Op: Insert, Text: y[prevJ]})
+		} else {
+			reversed = append(reversed, Op{Op: Delete, Text: x[prevI]})
+		}
+
+		i, j = prevI, prevJ
+	}
+
+	for i > 0 && j > 0 {
+		reversed = append(reversed, Op{Op: Equal, Text: x[i-1]})
+		i--
+		j--
+	}
+
+	ops := make([]Op, len(reversed))
+	for idx, op := range reversed {
+		ops[len(reversed)-1-idx] = op
+	}
+	return ops
+}
+
+func replaceAll(x, y []string) []Op {
+	var ops []Op
+	for _, word := range x {
+		ops = append(ops, Op{Op: Delete, Text: word})
+	}
+	for _, word := range y {
+		ops = append(ops, Op{Op: Insert, Text: word})
+	}
+	return ops
+}
+
+// mergeOps joins neighbouring operations of the same kind.
+func mergeOps(ops []Op) []Op {
+	merged := []Op{}
+	for _, op := range ops {
+		if last := len(merged) - 1; last >= 0 && merged[last].Op == op.Op {
+			merged[last].Text += op.Text
+			continue
+		}
+		merged = append(merged, op)
+	}
+	return merged
+}
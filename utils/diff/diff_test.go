@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	got := Words("the quick brown fox", "the slow brown dog")
+	expected := []Op{
+		{Op: Equal, Text: "the "},
+		{Op: Delete, Text: "quick"},
+		{Op: Insert, Text: "slow"},
+		{Op: Equal, Text: " brown "},
+		{Op: Delete, Text: "fox"},
+		{Op: Insert, Text: "dog"},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected diff %v. Got %v", expected, got)
+	}
+}
+
+func TestWordsIdentical(t *testing.T) {
+	got := Words("sama  saja", "sama  saja")
+	expected := []Op{{Op: Equal, Text: "sama  saja"}}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected diff %v. Got %v", expected, got)
+	}
+
+	if got := Words("", ""); len(got) != 0 {
+		t.Errorf("Expected an empty diff. Got %v", got)
+	}
+}
+
+func TestWordsRebuildsBothSides(t *testing.T) {
+	words := []string{"a", "b", "c", "kopi", "teh", " ", "  ", "\n"}
+	random := rand.New(rand.NewSource(1))
+	text := func() string {
+		var sb strings.Builder
+		for i := random.Intn(40); i > 0; i-- {
+			sb.WriteString(words[random.Intn(len(words))])
+		}
+		return sb.String()
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := text(), text()
+
+		var before, after strings.Builder
+		for _, op := range Words(a, b) {
+			if op.Op != Insert {
+				before.WriteString(op.Text)
+			}
+			if op.Op != Delete {
+				after.WriteString(op.Text)
+			}
+		}
+
+		if before.String() != a || after.String() != b {
+			t.Fatalf("Diff of %q and %q does not rebuild both texts", a, b)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package imaging decodes uploaded images and renders the resized variants
+// served to clients.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Original is the variant holding the full size image with its metadata
+// removed.
+const Original = "original"
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// Variant is a named size. The image is scaled down, never up, so that
+// neither side exceeds MaxSize.
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+var DefaultVariants = []Variant{{Name: "thumbnail", MaxSize: 320}, {Name: "medium", MaxSize: 1280}}
+
+// ParseVariants reads a list such as "thumbnail:320,medium:1280".
+func ParseVariants(s string) ([]Variant, error) {
+	var variants []Variant
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, size, ok := strings.Cut(item, ":")
+		maxSize, err := strconv.Atoi(size)
+		if !ok || err != nil || maxSize <= 0 || name == "" || name == Original {
+			return nil, fmt.Errorf("invalid image variant %q", item)
+		}
+
+		variants = append(variants, Variant{Name: name, MaxSize: maxSize})
+	}
+
+	return variants, nil
+}
+
+// Output is one encoded variant.
+type Output struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+type Result struct {
+	Width    int
+	Height   int
+	Blurhash string
+	Outputs  []Output
+}
+
+// Process decodes a JPEG, PNG or GIF image, applies its EXIF orientation and
+// renders the original plus every variant. Re-encoding drops EXIF, GPS and
+// any other metadata. Animated GIFs keep their frames in the original;
+// the other variants use the first frame. Images over the size limits are
+// rejected with ErrImageTooLarge before they are decoded.
+func Process(data []byte, variants []Variant) (Result, error) {
+	var result Result
+
+	if err := checkSize(data); err != nil {
+		return result, err
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return result, err
+	}
+
+	var img *image.RGBA
+	switch format {
+	case "jpeg":
+		img = orient(toRGBA(src), exifOrientation(data))
+	case "png", "gif":
+		img = toRGBA(src)
+	default:
+		return result, ErrUnsupportedFormat
+	}
+
+	result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	result.Blurhash = Blurhash(Fit(img, 32), 4, 3)
+
+	original := Output{Name: Original, Width: result.Width, Height: result.Height}
+	if format == "gif" {
+		all, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return result, err
+		}
+
+		original.ContentType = "image/gif"
+		original.Data, err = encodeGIF(all)
+		if err != nil {
+			return result, err
+		}
+	} else {
+		original.ContentType, original.Data, err = encode(img, format)
+		if err != nil {
+			return result, err
+		}
+	}
+	result.Outputs = append(result.Outputs, original)
+
+	for _, v := range variants {
+		resized := Fit(img, v.MaxSize)
+		out := Output{Name: v.Name, Width: resized.Bounds().Dx(), Height: resized.Bounds().Dy()}
+
+		out.ContentType, out.Data, err = encode(resized, format)
+		if err != nil {
+			return result, err
+		}
+		result.Outputs = append(result.Outputs, out)
+	}
+
+	return result, nil
+}
+
+// encode writes JPEG sources as JPEG and everything else as PNG, which
+// keeps transparency.
+func encode(img image.Image, format string) (string, []byte, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		return "image/jpeg", buf.Bytes(), err
+	}
+
+	err := png.Encode(&buf, img)
+	return "image/png", buf.Bytes(), err
+}
+
+func encodeGIF(g *gif.GIF) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, g)
+	return buf.Bytes(), err
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// Fit scales img down with an area average so that neither side exceeds
+// maxSize. Smaller images are returned unchanged.
+func Fit(img *image.RGBA, maxSize int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+
+	dw, dh := maxSize, maxSize
+	if w > h {
+		dh = h * maxSize / w
+	} else {
+		dw = w * maxSize / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, (y+1)*h/dh
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, (x+1)*w/dw
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := img.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(img.Pix[i])
+					g += int(img.Pix[i+1])
+					b += int(img.Pix[i+2])
+					a += int(img.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,190 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF segment with the orientation tag and a GPS
+// IFD pointer right after the SOI marker of a JPEG.
+func withOrientation(data []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 2,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0,
+		0x88, 0x25, 0, 4, 0, 0, 0, 1, 0, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(segment) + 2
+
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte(length >> 8), byte(length)}
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestBlurhashSolidBlack(t *testing.T) {
+	hash := Blurhash(solid(8, 8, color.RGBA{A: 255}), 4, 3)
+	if hash != "L00000fQfQfQfQfQfQfQfQfQfQfQ" {
+		t.Errorf("Expected the blurhash of a black image. Got %s", hash)
+	}
+}
+
+func TestFit(t *testing.T) {
+	img := Fit(solid(1000, 500, color.RGBA{R: 200, A: 255}), 320)
+	if img.Bounds().Dx() != 320 || img.Bounds().Dy() != 160 {
+		t.Errorf("Expected 320x160. Got %v", img.Bounds())
+	}
+
+	if c := img.RGBAAt(10, 10); c.R != 200 || c.A != 255 {
+		t.Errorf("Expected the color to survive resizing. Got %v", c)
+	}
+
+	small := solid(10, 20, color.RGBA{A: 255})
+	if Fit(small, 320) != small {
+		t.Errorf("Expected small images not to be scaled up")
+	}
+}
+
+func TestProcessAppliesOrientationAndStripsExif(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, solid(40, 20, color.RGBA{G: 180, A: 255}), nil)
+	data := withOrientation(buf.Bytes(), 6)
+
+	if exifOrientation(data) != 6 {
+		t.Fatalf("Expected orientation 6. Got %d", exifOrientation(data))
+	}
+
+	result, err := Process(data, []Variant{{Name: "thumbnail", MaxSize: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Width != 20 || result.Height != 40 {
+		t.Errorf("Expected a rotated 20x40 image. Got %dx%d", result.Width, result.Height)
+	}
+
+	if len(result.Outputs) != 2 {
+		t.Fatalf("Expected the original and one variant. Got %d", len(result.Outputs))
+	}
+
+	for _, out := range result.Outputs {
+		if bytes.Contains(out.Data, []byte("Exif")) {
+			t.Errorf("Expected %s to have no EXIF data", out.Name)
+		}
+		if out.ContentType != "image/jpeg" {
+			t.Errorf("Expected %s to be a jpeg. Got %s", out.Name, out.ContentType)
+		}
+	}
+
+	thumbnail := result.Outputs[1]
+	if thumbnail.Width != 5 || thumbnail.Height != 10 {
+		t.Errorf("Expected a 5x10 thumbnail. Got %dx%d", thumbnail.Width, thumbnail.Height)
+	}
+}
+
+func TestProcessKeepsGIFAnimation(t *testing.T) {
+	frame := func(c uint8) *image.Paletted {
+		return image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.Gray{Y: c}})
+	}
+
+	var buf bytes.Buffer
+	gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame(0), frame(255)}, Delay: []int{10, 10}})
+
+	result, err := Process(buf.Bytes(), DefaultVariants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := gif.DecodeAll(bytes.NewReader(result.Outputs[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(original.Image) != 2 {
+		t.Errorf("Expected 2 frames. Got %d", len(original.Image))
+	}
+
+	if _, err := png.Decode(bytes.NewReader(result.Outputs[1].Data)); err != nil {
+		t.Errorf("Expected the thumbnail to be a png. Got %v", err)
+	}
+}
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants("thumbnail:320, medium:1280")
+	if err != nil || len(variants) != 2 || variants[1] != (Variant{Name: "medium", MaxSize: 1280}) {
+		t.Errorf("Expected two variants. Got %v (%v)", variants, err)
+	}
+
+	for _, invalid := range []string{"thumbnail", "thumbnail:0", "original:100", ":100"} {
+		if _, err := ParseVariants(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// withSize rewrites the dimensions in the IHDR chunk of a PNG, leaving its
+// pixel data alone.
+func withSize(data []byte, w, h uint32) []byte {
+	out := append([]byte(nil), data...)
+	binary.BigEndian.PutUint32(out[16:], w)
+	binary.BigEndian.PutUint32(out[20:], h)
+	binary.BigEndian.PutUint32(out[29:], crc32.ChecksumIEEE(out[12:29]))
+	return out
+}
+
+func TestProcessRejectsLargeImages(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, solid(1, 1, color.RGBA{R: 255, A: 255}))
+
+	huge := withSize(buf.Bytes(), 100000, 100000)
+	if len(huge) > 1024 {
+		t.Fatalf("Expected a tiny file. Got %d bytes", len(huge))
+	}
+
+	if _, err := Process(huge, DefaultVariants); err != ErrImageTooLarge {
+		t.Errorf("Expected a 10 gigapixel PNG to be rejected. Got %v", err)
+	}
+
+	frame := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+	anim := &gif.GIF{}
+	for i := 0; i <= MaxFrames; i++ {
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 1)
+	}
+	buf.Reset()
+	gif.EncodeAll(&buf, anim)
+
+	if _, err := Process(buf.Bytes(), DefaultVariants); err != ErrImageTooLarge {
+		t.Errorf("Expected a GIF with too many frames to be rejected. Got %v", err)
+	}
+
+	anim.Image, anim.Delay = anim.Image[:3], anim.Delay[:3]
+	buf.Reset()
+	gif.EncodeAll(&buf, anim)
+
+	if frames, err := gifFrames(buf.Bytes()); err != nil || frames != 3 {
+		t.Errorf("Expected 3 frames. Got %d %v", frames, err)
+	}
+
+	if _, err := Process(buf.Bytes(), DefaultVariants); err != nil {
+		t.Errorf("Expected a small animation to be processed. Got %v", err)
+	}
+}
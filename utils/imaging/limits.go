@@ -0,0 +1,101 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+)
+
+// Limits on what Process decodes, checked against the image's header
+// before any pixels are read, so that a small, highly compressed upload
+// cannot make it allocate gigabytes.
+var (
+	// MaxPixels is the largest width × height accepted.
+	MaxPixels int64 = 50000000
+	// MaxFrames is the largest number of frames of an animated GIF.
+	MaxFrames = 500
+	// MaxAnimationPixels is the largest width × height × frames of an
+	// animated GIF.
+	MaxAnimationPixels int64 = 200000000
+)
+
+var ErrImageTooLarge = errors.New("image is too large")
+
+// checkSize returns ErrImageTooLarge when decoding the image would exceed
+// the limits.
+func checkSize(data []byte) error {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+	if pixels > MaxPixels {
+		return ErrImageTooLarge
+	}
+
+	if format == "gif" {
+		// Frames lie within the logical screen, so it bounds each of them.
+		frames, err := gifFrames(data)
+		if err != nil {
+			return err
+		}
+		if frames > MaxFrames || pixels*int64(frames) > MaxAnimationPixels {
+			return ErrImageTooLarge
+		}
+	}
+
+	return nil
+}
+
+var errMalformedGIF = errors.New("gif: malformed data")
+
+// gifFrames counts the image descriptors of a GIF by walking its blocks,
+// skipping the pixel data without decompressing it.
+func gifFrames(data []byte) (int, error) {
+	// Header and logical screen descriptor.
+	if len(data) < 13 {
+		return 0, errMalformedGIF
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	frames := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // Extension: label, then data sub-blocks.
+			i += 2
+		case 0x2C: // Image descriptor, local color table, LZW code size.
+			if i+10 > len(data) {
+				return 0, errMalformedGIF
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i++
+			frames++
+		case 0x3B: // Trailer.
+			return frames, nil
+		default:
+			return 0, errMalformedGIF
+		}
+
+		for {
+			if i >= len(data) {
+				return 0, errMalformedGIF
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+	}
+
+	// Truncated data is left for the decoder to reject.
+	return frames, nil
+}
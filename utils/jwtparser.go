@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -20,3 +21,31 @@ func ParseToken(jwtclaim *config.JWTClaim, r *http.Request) {
 	jwtclaim.Username = claims["Username"].(string)
 	jwtclaim.Userid = claims["Userid"].(string)
 }
+
+// ViewerID returns the id of the user signed in with r on routes that do not
+// require signing in, or an empty string when the request has no valid
+// access token.
+func ViewerID(r *http.Request) string {
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if bearer == "" {
+		return ""
+	}
+
+	token, err := jwt.Parse(bearer, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return config.JWT_KEY, nil
+	})
+	if err != nil || !token.Valid {
+		return ""
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["sub"] == "refresh_token" {
+		return ""
+	}
+
+	userID, _ := claims["Userid"].(string)
+	return userID
+}
@@ -0,0 +1,62 @@
+package markdown
+
+import (
+	"container/list"
+	"sync"
+)
+
+// maxCachedSource is the longest source a Cache keeps; longer texts are
+// rendered on every call.
+const maxCachedSource = 64 << 10
+
+// Cache remembers the output of Render for the most recently rendered
+// sources.
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	src, html string
+}
+
+// NewCache returns a Cache holding up to size rendered texts.
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// Render returns Render(src), from the cache when src was rendered recently.
+func (c *Cache) Render(src string) string {
+	if src == "" || len(src) > maxCachedSource {
+		return Render(src)
+	}
+
+	c.mu.Lock()
+	if e, ok := c.items[src]; ok {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cacheEntry).html
+	}
+	c.mu.Unlock()
+
+	out := Render(src)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[src]; !ok {
+		c.items[src] = c.order.PushFront(&cacheEntry{src: src, html: out})
+		if c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheEntry).src)
+		}
+	}
+
+	return out
+}
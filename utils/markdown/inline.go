@@ -0,0 +1,550 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on link destinations and titles keep a run of unfinished links from
+// scanning the rest of the text again and again.
+const (
+	maxDestination = 2048
+	maxTitle       = 1024
+)
+
+// node is a piece of inline output: escaped text, finished HTML, or a run of
+// "*" or "_" that may become emphasis.
+type node struct {
+	html  string
+	delim *delimiter
+}
+
+type delimiter struct {
+	char     byte
+	count    int
+	orig     int
+	canOpen  bool
+	canClose bool
+	// opens and closes are the emphasis tags placed after and before the
+	// characters left in the run.
+	opens  []string
+	closes []string
+
+	prev, next *delimiter
+}
+
+type inlineParser struct {
+	src   string
+	nodes []node
+	text  strings.Builder
+	// closers maps the index of each "[" to its matching "]".
+	closers map[int]int
+	// codeEnd maps the start of each code span to its end.
+	codeEnd map[int]int
+	noLinks bool
+
+	first, last *delimiter
+}
+
+// renderInline renders the inline content of a paragraph. Link text is
+// rendered with noLinks set, as links cannot nest.
+func renderInline(src string, noLinks bool) string {
+	p := &inlineParser{src: src, noLinks: noLinks}
+	p.scanCode()
+	p.scanBrackets()
+	p.parse()
+	p.processEmphasis()
+
+	var b strings.Builder
+	for _, n := range p.nodes {
+		if n.delim == nil {
+			b.WriteString(n.html)
+			continue
+		}
+
+		d := n.delim
+		for _, tag := range d.closes {
+			b.WriteString(tag)
+		}
+		b.WriteString(strings.Repeat(string(d.char), d.count))
+		for i := len(d.opens) - 1; i >= 0; i-- {
+			b.WriteString(d.opens[i])
+		}
+	}
+
+	return b.String()
+}
+
+// scanCode finds the code spans: a run of backticks closed by the next run of
+// the same length.
+func (p *inlineParser) scanCode() {
+	p.codeEnd = map[int]int{}
+
+	type run struct{ start, end int }
+	var runs []run
+	for i := 0; i < len(p.src); i++ {
+		if p.src[i] == '\\' {
+			i++
+			continue
+		}
+		if p.src[i] != '`' {
+			continue
+		}
+
+		j := i
+		for j < len(p.src) && p.src[j] == '`' {
+			j++
+		}
+		runs = append(runs, run{i, j})
+		i = j - 1
+	}
+
+	// Runs are matched left to right; a run inside a span is not an opener.
+	byLength := map[int][]int{}
+	for k, r := range runs {
+		byLength[r.end-r.start] = append(byLength[r.end-r.start], k)
+	}
+
+	used := map[int]int{}
+	for k := 0; k < len(runs); k++ {
+		n := runs[k].end - runs[k].start
+		candidates := byLength[n]
+		for used[n] < len(candidates) && candidates[used[n]] <= k {
+			used[n]++
+		}
+		if used[n] == len(candidates) {
+			continue
+		}
+
+		closer := candidates[used[n]]
+		p.codeEnd[runs[k].start] = runs[closer].end
+		k = closer
+	}
+}
+
+// scanBrackets pairs each "[" with its "]", skipping escapes and code spans.
+func (p *inlineParser) scanBrackets() {
+	p.closers = map[int]int{}
+	var stack []int
+	for i := 0; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '`':
+			if end, ok := p.codeEnd[i]; ok {
+				i = end - 1
+			}
+		case '[':
+			stack = append(stack, i)
+		case ']':
+			if len(stack) > 0 {
+				p.closers[stack[len(stack)-1]] = i
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+func (p *inlineParser) parse() {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			p.writeHTML("<br />\n")
+			i += 2
+		case c == '\\' && i+1 < len(src) && isPunct(src[i+1]):
+			p.text.WriteByte(src[i+1])
+			i += 2
+		case c == '\n':
+			text := p.text.String()
+			trimmed := strings.TrimRight(text, " ")
+			p.text.Reset()
+			p.text.WriteString(trimmed)
+			if len(text)-len(trimmed) >= 2 {
+				p.writeHTML("<br />\n")
+			} else {
+				p.text.WriteByte('\n')
+			}
+			i++
+			for i < len(src) && src[i] == ' ' {
+				i++
+			}
+		case c == '`':
+			end, ok := p.codeEnd[i]
+			if !ok {
+				j := i
+				for j < len(src) && src[j] == '`' {
+					j++
+				}
+				p.text.WriteString(src[i:j])
+				i = j
+				continue
+			}
+			n := 0
+			for src[i+n] == '`' {
+				n++
+			}
+			p.writeHTML("<code>" + escape(codeContent(src[i+n:end-n])) + "</code>")
+			i = end
+		case c == '*' || c == '_':
+			i = p.pushDelimiter(i)
+		case c == '[' && !p.noLinks:
+			if next, ok := p.parseLink(i); ok {
+				i = next
+				continue
+			}
+			p.text.WriteByte(c)
+			i++
+		case c == '<':
+			if next, ok := p.parseAutolink(i); ok {
+				i = next
+				continue
+			}
+			p.text.WriteByte(c)
+			i++
+		default:
+			p.text.WriteByte(c)
+			i++
+		}
+	}
+
+	p.flush()
+}
+
+// flush turns the pending text into an escaped node.
+func (p *inlineParser) flush() {
+	if p.text.Len() > 0 {
+		p.nodes = append(p.nodes, node{html: escape(p.text.String())})
+		p.text.Reset()
+	}
+}
+
+func (p *inlineParser) writeHTML(html string) {
+	p.flush()
+	p.nodes = append(p.nodes, node{html: html})
+}
+
+// codeContent normalizes the text of a code span: line endings become spaces
+// and one space is stripped from each end when both ends have one.
+func codeContent(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) >= 2 && s[0] == ' ' && s[len(s)-1] == ' ' && strings.Trim(s, " ") != "" {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+// pushDelimiter adds the run of "*" or "_" at i, working out from its
+// neighbours whether it can open or close emphasis.
+func (p *inlineParser) pushDelimiter(i int) int {
+	c := p.src[i]
+	j := i
+	for j < len(p.src) && p.src[j] == c {
+		j++
+	}
+
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:i])
+	}
+	if j < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[j:])
+	}
+
+	beforeSpace, afterSpace := unicode.IsSpace(before), unicode.IsSpace(after)
+	beforePunct, afterPunct := isPunctRune(before), isPunctRune(after)
+
+	left := !afterSpace && (!afterPunct || beforeSpace || beforePunct)
+	right := !beforeSpace && (!beforePunct || afterSpace || afterPunct)
+
+	d := &delimiter{char: c, count: j - i, orig: j - i}
+	if c == '*' {
+		d.canOpen, d.canClose = left, right
+	} else {
+		d.canOpen = left && (!right || beforePunct)
+		d.canClose = right && (!left || afterPunct)
+	}
+
+	p.flush()
+	p.nodes = append(p.nodes, node{delim: d})
+
+	d.prev = p.last
+	if p.last != nil {
+		p.last.next = d
+	} else {
+		p.first = d
+	}
+	p.last = d
+
+	return j
+}
+
+// processEmphasis matches openers and closers as CommonMark does, using the
+// bottoms of the earlier failed searches so the work stays linear.
+func (p *inlineParser) processEmphasis() {
+	type key struct {
+		char    byte
+		canOpen bool
+		mod     int
+	}
+	bottom := map[key]*delimiter{}
+
+	for closer := p.first; closer != nil; {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		k := key{closer.char, closer.canOpen, closer.orig % 3}
+		stop, hasStop := bottom[k]
+		var opener *delimiter
+		for o := closer.prev; o != nil && !(hasStop && o == stop); o = o.prev {
+			if o.char != closer.char || !o.canOpen {
+				continue
+			}
+			if (o.canClose || closer.canOpen) && (o.orig+closer.orig)%3 == 0 && !(o.orig%3 == 0 && closer.orig%3 == 0) {
+				continue
+			}
+			opener = o
+			break
+		}
+
+		if opener == nil {
+			bottom[k] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				p.remove(closer)
+			}
+			closer = next
+			continue
+		}
+
+		use := 1
+		tag := "em"
+		if opener.count >= 2 && closer.count >= 2 {
+			use, tag = 2, "strong"
+		}
+		opener.count -= use
+		closer.count -= use
+		opener.opens = append(opener.opens, "<"+tag+">")
+		closer.closes = append(closer.closes, "</"+tag+">")
+
+		for d := opener.next; d != closer; {
+			next := d.next
+			p.remove(d)
+			d = next
+		}
+
+		if opener.count == 0 {
+			p.remove(opener)
+		}
+		if closer.count == 0 {
+			next := closer.next
+			p.remove(closer)
+			closer = next
+		}
+	}
+}
+
+// remove takes d off the delimiter list; what is left of its run stays in
+// the output as text.
+func (p *inlineParser) remove(d *delimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	} else {
+		p.first = d.next
+	}
+	if d.next != nil {
+		d.next.prev = d.prev
+	} else {
+		p.last = d.prev
+	}
+}
+
+// parseLink parses an inline link, [text](destination "title"), at i.
+func (p *inlineParser) parseLink(i int) (int, bool) {
+	end, ok := p.closers[i]
+	if !ok || end+1 >= len(p.src) || p.src[end+1] != '(' {
+		return 0, false
+	}
+
+	j := skipSpace(p.src, end+2)
+	dest, j, ok := parseDestination(p.src, j)
+	if !ok {
+		return 0, false
+	}
+
+	title := ""
+	if k := skipSpace(p.src, j); k > j && k < len(p.src) && strings.IndexByte(`"'(`, p.src[k]) >= 0 {
+		title, j, ok = parseTitle(p.src, k)
+		if !ok {
+			return 0, false
+		}
+	}
+
+	j = skipSpace(p.src, j)
+	if j >= len(p.src) || p.src[j] != ')' {
+		return 0, false
+	}
+
+	text := renderInline(p.src[i+1:end], true)
+	href, safe := safeURL(unescape(dest))
+	if !safe {
+		p.writeHTML(text)
+		return j + 1, true
+	}
+
+	link := `<a href="` + escape(href) + `"`
+	if title != "" {
+		link += ` title="` + escape(unescape(title)) + `"`
+	}
+	p.writeHTML(link + ">" + text + "</a>")
+	return j + 1, true
+}
+
+// parseAutolink parses <scheme:address> or <user@example.com> at i.
+func (p *inlineParser) parseAutolink(i int) (int, bool) {
+	end := -1
+	for j := i + 1; j < len(p.src) && j-i <= maxDestination; j++ {
+		c := p.src[j]
+		if c == '>' {
+			end = j
+			break
+		}
+		if c == '<' || c <= ' ' {
+			return 0, false
+		}
+	}
+
+	if end <= i+1 {
+		return 0, false
+	}
+
+	address := p.src[i+1 : end]
+	href := address
+	if !strings.Contains(address, ":") {
+		if !isEmail(address) {
+			return 0, false
+		}
+		href = "mailto:" + address
+	}
+
+	href, safe := safeURL(href)
+	if !safe {
+		return 0, false
+	}
+
+	p.writeHTML(`<a href="` + escape(href) + `">` + escape(address) + "</a>")
+	return end + 1, true
+}
+
+func parseDestination(s string, i int) (string, int, bool) {
+	if i < len(s) && s[i] == '<' {
+		for j := i + 1; j < len(s) && j-i <= maxDestination; j++ {
+			switch s[j] {
+			case '\\':
+				j++
+			case '\n', '<':
+				return "", 0, false
+			case '>':
+				return s[i+1 : j], j + 1, true
+			}
+		}
+		return "", 0, false
+	}
+
+	depth := 0
+	j := i
+	for ; j < len(s) && j-i <= maxDestination; j++ {
+		c := s[j]
+		if c == '\\' && j+1 < len(s) && isPunct(s[j+1]) {
+			j++
+			continue
+		}
+		if c <= ' ' {
+			break
+		}
+		if c == '(' {
+			depth++
+		}
+		if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+	}
+
+	if depth != 0 || j-i > maxDestination {
+		return "", 0, false
+	}
+	return s[i:j], j, true
+}
+
+func parseTitle(s string, i int) (string, int, bool) {
+	closing := s[i]
+	if closing == '(' {
+		closing = ')'
+	}
+
+	for j := i + 1; j < len(s) && j-i <= maxTitle; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case closing:
+			return s[i+1 : j], j + 1, true
+		}
+	}
+
+	return "", 0, false
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// unescape removes the backslashes before punctuation.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isEmail(s string) bool {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" || domain == "" || !strings.Contains(domain, ".") {
+		return false
+	}
+
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune(".!#$%&'*+/=?^_`{|}~-@", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isPunctRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return isPunct(byte(r))
+	}
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
@@ -0,0 +1,393 @@
+// Package markdown renders the subset of CommonMark used in posts and
+// comments to sanitized HTML.
+//
+// Supported are paragraphs, hard line breaks, emphasis, strong emphasis,
+// code spans, fenced code blocks, inline links, autolinks and bullet and
+// ordered lists, which may nest. Headings are left out because a line
+// starting with "#" is a hashtag here, and raw HTML is shown as text.
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Render converts src to HTML and runs it through Sanitize.
+func Render(src string) string {
+	if strings.TrimSpace(src) == "" {
+		return ""
+	}
+
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\r", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = expandIndent(line)
+	}
+
+	var b strings.Builder
+	renderBlocks(&b, parseBlocks(lines, 0), false)
+	return Sanitize(b.String())
+}
+
+const (
+	blockParagraph = iota
+	blockCode
+	blockList
+)
+
+type block struct {
+	kind int
+	// lines of a paragraph or code block.
+	lines []string
+	// info is the language of a code block.
+	info string
+	// ordered, start and loose describe a list of items.
+	ordered bool
+	start   int
+	loose   bool
+	items   [][]block
+}
+
+// listMarker is the start of a list item line.
+type listMarker struct {
+	ordered bool
+	// delim is the bullet character, or the "." or ")" after the number.
+	delim byte
+	start int
+	// indent is the column the item's content starts at.
+	indent int
+	empty  bool
+}
+
+// maxNesting bounds how deep lists nest; deeper markers are read as text.
+const maxNesting = 16
+
+func parseBlocks(lines []string, depth int) []block {
+	var blocks []block
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			i++
+			continue
+		}
+
+		if fence, indent, ok := openFence(line); ok {
+			b, next := parseCode(lines, i, fence, indent)
+			blocks = append(blocks, b)
+			i = next
+			continue
+		}
+
+		if m, ok := parseMarker(line); ok && depth < maxNesting {
+			b, next := parseList(lines, i, m, depth)
+			blocks = append(blocks, b)
+			i = next
+			continue
+		}
+
+		b := block{kind: blockParagraph}
+		for i < len(lines) && !isBlank(lines[i]) {
+			if len(b.lines) > 0 && interruptsParagraph(lines[i]) {
+				break
+			}
+			b.lines = append(b.lines, strings.TrimLeft(lines[i], " "))
+			i++
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks
+}
+
+// interruptsParagraph reports whether line starts a new block even without a
+// blank line before it. Like CommonMark, only lists starting at 1 may
+// interrupt a paragraph, so a line such as "2023. Was a good year" does not.
+func interruptsParagraph(line string) bool {
+	if _, _, ok := openFence(line); ok {
+		return true
+	}
+
+	m, ok := parseMarker(line)
+	return ok && !m.empty && (!m.ordered || m.start == 1)
+}
+
+func parseCode(lines []string, i int, fence string, indent int) (block, int) {
+	b := block{kind: blockCode}
+	info := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(lines[i]), fence[:1]))
+	if fields := strings.Fields(info); len(fields) > 0 {
+		b.info = fields[0]
+	}
+
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if closesFence(line, fence) {
+			return b, i + 1
+		}
+
+		n := 0
+		for n < indent && n < len(line) && line[n] == ' ' {
+			n++
+		}
+		b.lines = append(b.lines, line[n:])
+	}
+
+	return b, i
+}
+
+// openFence reports whether line opens a fenced code block, returning the
+// fence and its indentation.
+func openFence(line string) (string, int, bool) {
+	indent := leadingSpaces(line)
+	if indent > 3 {
+		return "", 0, false
+	}
+
+	rest := line[indent:]
+	if len(rest) < 3 || (rest[0] != '`' && rest[0] != '~') {
+		return "", 0, false
+	}
+
+	n := 0
+	for n < len(rest) && rest[n] == rest[0] {
+		n++
+	}
+
+	if n < 3 || (rest[0] == '`' && strings.Contains(rest[n:], "`")) {
+		return "", 0, false
+	}
+
+	return rest[:n], indent, true
+}
+
+func closesFence(line, fence string) bool {
+	indent := leadingSpaces(line)
+	if indent > 3 {
+		return false
+	}
+
+	rest := strings.TrimRight(line[indent:], " ")
+	return len(rest) >= len(fence) && strings.Trim(rest, fence[:1]) == ""
+}
+
+// parseMarker reports whether line starts a list item.
+func parseMarker(line string) (listMarker, bool) {
+	var m listMarker
+	indent := leadingSpaces(line)
+	if indent > 3 {
+		return m, false
+	}
+
+	rest := line[indent:]
+	width := 0
+	switch {
+	case rest == "":
+		return m, false
+	case rest[0] == '-' || rest[0] == '*' || rest[0] == '+':
+		m.delim = rest[0]
+		width = 1
+	default:
+		for width < len(rest) && width < 9 && rest[width] >= '0' && rest[width] <= '9' {
+			width++
+		}
+		if width == 0 || width == len(rest) || (rest[width] != '.' && rest[width] != ')') {
+			return m, false
+		}
+		m.ordered = true
+		m.start, _ = strconv.Atoi(rest[:width])
+		m.delim = rest[width]
+		width++
+	}
+
+	after := rest[width:]
+	if after != "" && after[0] != ' ' {
+		return m, false
+	}
+
+	spaces := leadingSpaces(after)
+	m.empty = spaces == len(after)
+	if m.empty || spaces > 4 {
+		spaces = 1
+	}
+
+	m.indent = indent + width + spaces
+	return m, true
+}
+
+// parseList reads the items of the list starting at lines[i]. The lines of
+// each item, without their indentation, are parsed as blocks of their own,
+// which is how lists nest.
+func parseList(lines []string, i int, first listMarker, depth int) (block, int) {
+	b := block{kind: blockList, ordered: first.ordered, start: first.start}
+	m := first
+	for {
+		item := []string{contentOf(lines[i], m.indent)}
+		blankBefore := false
+		j := i + 1
+		for ; j < len(lines); j++ {
+			line := lines[j]
+			if isBlank(line) {
+				item = append(item, "")
+				blankBefore = true
+				continue
+			}
+
+			if leadingSpaces(line) >= m.indent {
+				if blankBefore && len(trimBlank(item)) > 0 {
+					b.loose = true
+				}
+				item = append(item, line[m.indent:])
+				blankBefore = false
+				continue
+			}
+
+			if _, ok := parseMarker(line); ok {
+				break
+			}
+
+			if _, _, ok := openFence(line); ok || blankBefore {
+				break
+			}
+
+			// A lazy continuation of the item's last paragraph.
+			item = append(item, strings.TrimLeft(line, " "))
+		}
+
+		b.items = append(b.items, parseBlocks(trimBlank(item), depth+1))
+
+		if j == len(lines) {
+			return b, j
+		}
+
+		next, ok := parseMarker(lines[j])
+		if !ok || next.ordered != first.ordered || next.delim != first.delim {
+			return b, j
+		}
+
+		if blankBefore {
+			b.loose = true
+		}
+		i, m = j, next
+	}
+}
+
+func renderBlocks(b *strings.Builder, blocks []block, tight bool) {
+	for i, bl := range blocks {
+		switch bl.kind {
+		case blockParagraph:
+			text := strings.TrimRight(strings.Join(bl.lines, "\n"), " ")
+			if tight {
+				b.WriteString(renderInline(text, false))
+				if i < len(blocks)-1 {
+					b.WriteString("\n")
+				}
+				continue
+			}
+			b.WriteString("<p>")
+			b.WriteString(renderInline(text, false))
+			b.WriteString("</p>\n")
+		case blockCode:
+			b.WriteString("<pre><code")
+			if lang := languageOf(bl.info); lang != "" {
+				b.WriteString(` class="language-` + lang + `"`)
+			}
+			b.WriteString(">")
+			for _, line := range bl.lines {
+				b.WriteString(escape(line))
+				b.WriteString("\n")
+			}
+			b.WriteString("</code></pre>\n")
+		case blockList:
+			tag := "ul"
+			if bl.ordered {
+				tag = "ol"
+			}
+			b.WriteString("<" + tag)
+			if bl.ordered && bl.start != 1 {
+				b.WriteString(` start="` + strconv.Itoa(bl.start) + `"`)
+			}
+			b.WriteString(">\n")
+			for _, item := range bl.items {
+				b.WriteString("<li>")
+				if len(item) > 0 && (bl.loose || item[0].kind != blockParagraph) {
+					b.WriteString("\n")
+				}
+				renderBlocks(b, item, !bl.loose)
+				b.WriteString("</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+		}
+	}
+}
+
+// languageOf keeps the info string of a code block only when it is a plain
+// language name.
+func languageOf(info string) string {
+	if info == "" || len(info) > 32 {
+		return ""
+	}
+
+	for _, c := range info {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '+') {
+			return ""
+		}
+	}
+
+	return info
+}
+
+func contentOf(line string, indent int) string {
+	if indent >= len(line) {
+		return ""
+	}
+	return line[indent:]
+}
+
+// trimBlank drops the blank lines at both ends of lines.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func leadingSpaces(s string) int {
+	n := 0
+	for n < len(s) && s[n] == ' ' {
+		n++
+	}
+	return n
+}
+
+// expandIndent replaces the tabs in the indentation of line with spaces,
+// to the next multiple of 4 columns.
+func expandIndent(line string) string {
+	if !strings.HasPrefix(line, "\t") && !strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+		return line
+	}
+
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			b.WriteByte(' ')
+			col++
+		case '\t':
+			for n := 4 - col%4; n > 0; n-- {
+				b.WriteByte(' ')
+				col++
+			}
+		default:
+			b.WriteString(line[i:])
+			return b.String()
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		src, expected string
+	}{
+		{"hello *world* and **bold**", "<p>hello <em>world</em> and <strong>bold</strong></p>\n"},
+		{"***both*** snake_case_name", "<p><em><strong>both</strong></em> snake_case_name</p>\n"},
+		{"*foo**bar**baz*", "<p><em>foo<strong>bar</strong>baz</em></p>\n"},
+		{"`a <b>` and ``x ` y``", "<p><code>a &lt;b&gt;</code> and <code>x ` y</code></p>\n"},
+		{`\*not emphasis\*`, "<p>*not emphasis*</p>\n"},
+		{"line  \nbreak\nsoft", "<p>line<br />\nbreak\nsoft</p>\n"},
+		{"#tag di awal\n\nparagraf kedua", "<p>#tag di awal</p>\n<p>paragraf kedua</p>\n"},
+		{"Harga naik\n2023. tahun baik", "<p>Harga naik\n2023. tahun baik</p>\n"},
+		{
+			`[link](https://example.com "Judul") dan [relatif](/post/1)`,
+			`<p><a href="https://example.com" title="Judul" rel="nofollow ugc">link</a> dan <a href="/post/1" rel="nofollow ugc">relatif</a></p>` + "\n",
+		},
+		{
+			"<https://example.com> <a@b.co>",
+			`<p><a href="https://example.com" rel="nofollow ugc">https://example.com</a> <a href="mailto:a@b.co" rel="nofollow ugc">a@b.co</a></p>` + "\n",
+		},
+		{"- satu\n- dua\n  - tiga\n- empat", "<ul>\n<li>satu</li>\n<li>dua\n<ul>\n<li>tiga</li>\n</ul>\n</li>\n<li>empat</li>\n</ul>\n"},
+		{"3. a\n4. b", "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{"- a\n\n- b", "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ul>\n"},
+		{"```go\nfunc main() {}\n<b>\n```", "<pre><code class=\"language-go\">func main() {}\n&lt;b&gt;\n</code></pre>\n"},
+	}
+
+	for _, c := range cases {
+		if got := Render(c.src); got != c.expected {
+			t.Errorf("Render(%q)\nExpected %q\nGot      %q", c.src, c.expected, got)
+		}
+	}
+}
+
+func TestRenderUnsafe(t *testing.T) {
+	cases := []string{
+		"<script>alert(1)</script>",
+		"[x](javascript:alert(1))",
+		"[x](JaVaScRiPt:alert(1))",
+		"[x](<java\tscript:alert(1)>)",
+		"[x](data:text/html;base64,PHNjcmlwdD4=)",
+		"<javascript:alert(1)>",
+		`<img src=x onerror="alert(1)">`,
+		"```\"><script>alert(1)</script>\n```",
+	}
+
+	for _, src := range cases {
+		got := Render(src)
+		lower := strings.ToLower(got)
+		if strings.Contains(lower, "<script") || strings.Contains(lower, "<img") || strings.Contains(lower, "href") {
+			t.Errorf("Expected Render(%q) to be safe. Got %q", src, got)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	cases := []struct {
+		src, expected string
+	}{
+		{`<a href="javascript:alert(1)" onclick="x()">x</a>`, `<a rel="nofollow ugc">x</a>`},
+		{`<a href="  JaVa&#09;ScRiPt:alert(1)">x</a>`, `<a rel="nofollow ugc">x</a>`},
+		{`<img src=x onerror=alert(1)><p style="color:red">hi</p>`, `<p>hi</p>`},
+		{`<script/>alert(1)</script>ok`, `ok`},
+		{`<svg><a href="/x">y</a></svg>z<b>bold</b>`, `zbold`},
+		{`<code class="x onclick">a</code><code class="language-go">b</code>`, `<code>a</code><code class="language-go">b</code>`},
+		{`<p><em>unclosed`, `<p><em>unclosed</em></p>`},
+		{`<p>a &amp; b &lt;c&gt;</p>`, `<p>a &amp; b &lt;c&gt;</p>`},
+	}
+
+	for _, c := range cases {
+		if got := Sanitize(c.src); got != c.expected {
+			t.Errorf("Sanitize(%q)\nExpected %q\nGot      %q", c.src, c.expected, got)
+		}
+	}
+}
+
+func TestRenderPathological(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("*a ", 20000),
+		strings.Repeat("[", 20000),
+		strings.Repeat("[a](", 20000),
+		strings.Repeat("` ``", 20000),
+		strings.Repeat("- ", 20000),
+	}
+
+	for _, src := range inputs {
+		if got := Render(src); got == "" {
+			t.Errorf("Expected output for %q...", src[:8])
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+	c.Render("*a*")
+	c.Render("*b*")
+	c.Render("*c*")
+
+	if _, ok := c.items["*a*"]; ok {
+		t.Errorf("Expected the oldest entry to be evicted")
+	}
+
+	if got := c.Render("*c*"); got != "<p><em>c</em></p>\n" {
+		t.Errorf("Expected the cached rendering. Got %q", got)
+	}
+}
@@ -0,0 +1,172 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags are the elements Sanitize keeps, with the attributes each may
+// have. Every other attribute, including event handlers and style, is
+// dropped.
+var allowedTags = map[string]map[string]bool{
+	"p":      {},
+	"br":     {},
+	"em":     {},
+	"strong": {},
+	"code":   {"class": true},
+	"pre":    {},
+	"ul":     {},
+	"ol":     {"start": true},
+	"li":     {},
+	"a":      {"href": true, "title": true},
+}
+
+// droppedTags are removed along with everything inside them.
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "template": true,
+	"noscript": true, "noembed": true, "noframes": true, "textarea": true, "title": true, "xmp": true,
+	"plaintext": true, "svg": true, "math": true, "select": true,
+}
+
+// rawTextTags are read by the tokenizer up to their end tag even when
+// written as self-closing.
+var rawTextTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "noembed": true, "noframes": true, "textarea": true,
+	"title": true, "xmp": true, "plaintext": true,
+}
+
+var (
+	languageClass = regexp.MustCompile(`^language-[A-Za-z0-9_+-]{1,32}$`)
+	digits        = regexp.MustCompile(`^[0-9]{1,9}$`)
+)
+
+// safeSchemes are the URL schemes links may use. URLs without a scheme are
+// relative and allowed.
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Sanitize keeps only the allowed elements and attributes of s. Scripts and
+// similar elements are removed with their content, links get
+// rel="nofollow ugc" and a href with any scheme but http, https or mailto is
+// dropped. Unclosed elements are closed at the end.
+func Sanitize(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	var b strings.Builder
+	var open []string
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				b.WriteString("</" + open[i] + ">")
+			}
+			return b.String()
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(escape(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if droppedTags[tok.Data] {
+				if tt == html.StartTagToken || rawTextTags[tok.Data] {
+					skip++
+				}
+				continue
+			}
+
+			attrs, ok := allowedTags[tok.Data]
+			if skip > 0 || !ok {
+				continue
+			}
+
+			b.WriteString("<" + tok.Data)
+			for _, attr := range tok.Attr {
+				if attr.Namespace != "" || !attrs[attr.Key] {
+					continue
+				}
+				value, ok := sanitizeAttr(tok.Data, attr.Key, attr.Val)
+				if !ok {
+					continue
+				}
+				b.WriteString(" " + attr.Key + `="` + escape(value) + `"`)
+			}
+			if tok.Data == "a" {
+				b.WriteString(` rel="nofollow ugc"`)
+			}
+
+			if tok.Data == "br" {
+				b.WriteString(" />")
+				continue
+			}
+			b.WriteString(">")
+			if tt == html.SelfClosingTagToken {
+				b.WriteString("</" + tok.Data + ">")
+				continue
+			}
+			open = append(open, tok.Data)
+		case html.EndTagToken:
+			tok := z.Token()
+			if droppedTags[tok.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+
+			if skip > 0 {
+				continue
+			}
+
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tok.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+}
+
+func sanitizeAttr(tag, key, value string) (string, bool) {
+	switch key {
+	case "href":
+		return safeURL(value)
+	case "class":
+		return value, languageClass.MatchString(value)
+	case "start":
+		return value, digits.MatchString(value)
+	}
+	return value, true
+}
+
+// safeURL reports whether u is a relative URL or uses one of the safe
+// schemes. Browsers ignore control characters and whitespace inside a
+// scheme, so "java\tscript:" is checked as "javascript:"; they are removed
+// from the returned URL.
+func safeURL(u string) (string, bool) {
+	u = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(u))
+	u = strings.ReplaceAll(u, " ", "%20")
+
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return u, true
+	}
+
+	return u, safeSchemes[strings.ToLower(u[:i])]
+}
+
+func escape(s string) string {
+	return html.EscapeString(s)
+}